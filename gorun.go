@@ -3,25 +3,91 @@ package gorun
 import (
 	"context"
 	"database/sql"
+	"fmt"
 	"time"
 
+	"github.com/jackc/pgx/v5/pgxpool"
 	"github.com/jswidler/gorun/gorundb"
+	"github.com/jswidler/gorun/gorundb/memstore"
+	"github.com/jswidler/gorun/gorundb/sqlstore"
 	"github.com/jswidler/gorun/triggers"
 )
 
 type GoRunService interface {
 	ScheduleImmediately(ctx context.Context, job JobData) (jobId string, err error)
 	ScheduleAfter(ctx context.Context, delay time.Duration, job JobData) (jobId string, err error)
-	ScheduleCron(ctx context.Context, cronExpr string, loc *time.Location, job JobData) (triggerId string, err error)
-	ScheduleRepeated(ctx context.Context, interval time.Duration, job JobData) (triggerId string, err error)
+	// ScheduleMany schedules each job to run its delay from now, and returns the ids of the jobs in the same order.  If
+	// any job is invalid or can not be saved, none of the jobs are scheduled, unless BestEffort is given, see
+	// ScheduleManyError.
+	ScheduleMany(ctx context.Context, jobs []ScheduledJob, opts ...ScheduleManyOption) (jobIds []string, err error)
+	// ScheduleDebounced schedules the job to run delay from now, unless a job of the same type and key has not started
+	// yet, in which case that job is moved to run delay from now with the arguments of job.  It returns the id of the job
+	// that will run.
+	ScheduleDebounced(ctx context.Context, key string, delay time.Duration, job JobData) (jobId string, err error)
+	// ScheduleThrottled schedules the job to run at most once per window for its type and key.  The job runs now, or when
+	// the window after the last job ends, unless a job of the same type and key has not started yet, in which case that
+	// job is given the arguments of job.  It returns the id of the job that will run.
+	ScheduleThrottled(ctx context.Context, key string, window time.Duration, job JobData) (jobId string, err error)
+	ScheduleCron(ctx context.Context, cronExpr string, loc *time.Location, job JobData, opts ...TriggerOption) (triggerId string, err error)
+	ScheduleRepeated(ctx context.Context, interval time.Duration, job JobData, opts ...TriggerOption) (triggerId string, err error)
+	ScheduleRRule(ctx context.Context, recurrence string, loc *time.Location, job JobData, opts ...TriggerOption) (triggerId string, err error)
 
-	ScheduleCronWithKey(ctx context.Context, triggerId string, cronExpr string, loc *time.Location, job JobData) error
-	ScheduleRepeatedWithKey(ctx context.Context, triggerId string, interval time.Duration, job JobData) error
+	ScheduleCronWithKey(ctx context.Context, triggerId string, cronExpr string, loc *time.Location, job JobData, opts ...TriggerOption) error
+	ScheduleRepeatedWithKey(ctx context.Context, triggerId string, interval time.Duration, job JobData, opts ...TriggerOption) error
+	ScheduleRRuleWithKey(ctx context.Context, triggerId string, recurrence string, loc *time.Location, job JobData, opts ...TriggerOption) error
+
+	// ScheduleOnEvent schedules the job to run each time the event is fired with FireEvent.  WithEventDelay,
+	// WithDebounce and WithThrottle control when the jobs run.
+	ScheduleOnEvent(ctx context.Context, event string, job JobData, opts ...TriggerOption) (triggerId string, err error)
+	ScheduleOnEventWithKey(ctx context.Context, triggerId string, event string, job JobData, opts ...TriggerOption) error
+	// FireEvent schedules the jobs of the triggers for the event.  If payload is not nil, it is used as the arguments of
+	// the jobs instead of the job the trigger was scheduled with, so it must be the arguments type of the job.
+	FireEvent(ctx context.Context, event string, payload any) error
+
+	// ScheduleChained schedules the job to run each time an upstream job finishes.  The chained job can find the id of
+	// the upstream job with UpstreamJobId.  WithEventDelay delays the chained job.
+	ScheduleChained(ctx context.Context, upstream Upstream, job JobData, opts ...TriggerOption) (triggerId string, err error)
+	ScheduleChainedWithKey(ctx context.Context, triggerId string, upstream Upstream, job JobData, opts ...TriggerOption) error
 
 	GetJob(ctx context.Context, jobId string) (*gorundb.JobData, error)
 	ListJobs(ctx context.Context, start, end time.Time) ([]*gorundb.JobData, error)
+	// QueryJobs returns a page of the jobs that match the filters, in the order they were created unless JobsOrderedBy
+	// is given, with the number of jobs that match on every page.  Pass the NextCursor of the page to JobsAfter to get
+	// the next page.
+	QueryJobs(ctx context.Context, filters ...JobFilter) (*gorundb.JobPage, error)
 	ListTriggers(ctx context.Context) ([]*gorundb.JobTrigger, error)
 	DeleteTrigger(ctx context.Context, triggerId string) error
+	// UpdateTrigger changes the schedule, job arguments or options of a trigger in place.  The jobs it scheduled that
+	// have not started are replaced, and the previous definition is kept, see TriggerHistory.
+	UpdateTrigger(ctx context.Context, triggerId string, opts ...UpdateOption) error
+	// TriggerHistory returns the previous definitions of a trigger, oldest first.
+	TriggerHistory(ctx context.Context, triggerId string) ([]*gorundb.TriggerAudit, error)
+	// PauseTrigger keeps the trigger but stops it from scheduling jobs, and removes the jobs it scheduled that have not
+	// started.
+	PauseTrigger(ctx context.Context, triggerId string) error
+	// ResumeTrigger lets a paused trigger schedule jobs again.  Runs missed while it was paused are not made up.
+	ResumeTrigger(ctx context.Context, triggerId string) error
+
+	// PauseJobType stops jobs of the type from starting, on every job server, until the type is resumed.  Jobs keep
+	// being scheduled while paused, and run once the type is resumed.
+	PauseJobType(ctx context.Context, jobType string) error
+	ResumeJobType(ctx context.Context, jobType string) error
+	ListPausedJobTypes(ctx context.Context) ([]string, error)
+
+	// SaveCalendar creates or replaces a calendar, which triggers use by name with WithCalendars.  Calendar names are
+	// shared by every tenant, so it returns gorundb.ErrConflict if another tenant has a calendar with the same name.
+	SaveCalendar(ctx context.Context, calendar *Calendar) error
+	GetCalendar(ctx context.Context, name string) (*Calendar, error)
+	ListCalendars(ctx context.Context) ([]*Calendar, error)
+	// DeleteCalendar deletes a calendar.  Triggers that use it are no longer restricted by it.
+	DeleteCalendar(ctx context.Context, name string) error
+
+	// PreviewTrigger returns the next n fire times of a cron expression, an RRULE recurrence, or a repeat trigger when
+	// expr is a duration such as "90s", without scheduling anything.  It can be used to validate an expression before
+	// saving it.  n can not be more than triggers.MaxFireTimes.
+	PreviewTrigger(expr string, loc *time.Location, n int) ([]time.Time, error)
+	// NextRuns returns the next n times at which jobs of a saved trigger will run, up to triggers.MaxFireTimes.
+	NextRuns(ctx context.Context, triggerId string, n int) ([]time.Time, error)
 
 	Start(ctx context.Context) error
 	Close()
@@ -70,20 +136,105 @@ const (
 
 type Trigger = triggers.Trigger
 
+// Upstream selects the jobs that a chained job runs after, either every job of JobType or every job of the trigger
+// TriggerId.
+type Upstream struct {
+	JobType   string
+	TriggerId string
+	// AnyStatus runs the chained job when the upstream job fails as well as when it completes.
+	AnyStatus bool
+}
+
+type Calendar = triggers.Calendar
+
+// ScheduledJob is a job for ScheduleMany to run Delay from now.
+type ScheduledJob struct {
+	Job   JobData
+	Delay time.Duration
+}
+
+// ScheduleManyError is returned by ScheduleMany when some of the jobs are not scheduled.  Errors has the error of each
+// job that failed by its index.  Without BestEffort, none of the jobs are scheduled.
+type ScheduleManyError struct {
+	Errors map[int]error
+}
+
+func (e *ScheduleManyError) Error() string {
+	first := -1
+	for i := range e.Errors {
+		if first == -1 || i < first {
+			first = i
+		}
+	}
+	return fmt.Sprintf("%d jobs could not be scheduled, the first is job %d: %s", len(e.Errors), first, e.Errors[first])
+}
+
+func (e *ScheduleManyError) Unwrap() []error {
+	errs := make([]error, 0, len(e.Errors))
+	for _, err := range e.Errors {
+		errs = append(errs, err)
+	}
+	return errs
+}
+
+// New returns a service that uses the Postgres database.  The schema and table names of the database are set with
+// WithDbOptions, for example New(db, WithDbOptions(gorundb.WithSchema("jobs"))).
 func New(db *sql.DB, opts ...Option) (GoRunService, error) {
-	gdb, err := gorundb.New(db)
+	gdb, err := gorundb.New(db, newOptions(opts).dbOptions...)
+	if err != nil {
+		return nil, err
+	}
+	return newGorunner(gdb.JobView, opts), nil
+}
+
+// NewFromPgxPool returns a service that uses the Postgres database of the pool.  Like New, the database is configured
+// with WithDbOptions.
+func NewFromPgxPool(pool *pgxpool.Pool, opts ...Option) (GoRunService, error) {
+	gdb, err := gorundb.NewFromPgxPool(pool, newOptions(opts).dbOptions...)
 	if err != nil {
 		return nil, err
 	}
-	return newGorunner(gdb, opts), nil
+	return newGorunner(gdb.JobView, opts), nil
 }
 
+// NewFromEnv returns a service that uses the database configured in the environment.  GORUN_DB_DRIVER selects
+// postgres, the default, sqlite or mysql.
 func NewFromEnv(opts ...Option) (GoRunService, error) {
-	db, err := gorundb.NewFromEnv()
+	config, err := gorundb.ConfigFromEnv()
 	if err != nil {
 		return nil, err
 	}
-	return newGorunner(db, opts), nil
+	switch config.Driver {
+	case gorundb.DriverSQLite:
+		store, err := sqlstore.OpenSQLite(config.SQLitePath)
+		if err != nil {
+			return nil, err
+		}
+		return newGorunner(store, opts), nil
+	case gorundb.DriverMySQL:
+		store, err := sqlstore.OpenMySQL(sqlstore.MySQLConfig(config))
+		if err != nil {
+			return nil, err
+		}
+		return newGorunner(store, opts), nil
+	default:
+		db, err := gorundb.NewFromConfig(config, newOptions(opts).dbOptions...)
+		if err != nil {
+			return nil, err
+		}
+		return newGorunner(db.JobView, opts), nil
+	}
+}
+
+// NewInMemory returns a service that keeps its jobs and triggers in memory, for unit tests and local development.
+// Nothing is shared with other processes, or kept once the process exits.
+func NewInMemory(opts ...Option) GoRunService {
+	return newGorunner(memstore.New(), opts)
+}
+
+// NewWithStore returns a service that keeps its jobs and triggers in the store.
+func NewWithStore(store gorundb.Store, opts ...Option) GoRunService {
+	return newGorunner(store, opts)
 }
 
 type Option func(*options)
@@ -132,3 +283,249 @@ func DisableLogging() Option {
 		o.disableLogging = true
 	}
 }
+
+// Set the schema, table names or migration behaviour of a Postgres database, such as gorundb.WithSchema or
+// gorundb.WithoutMigrations.  They are used by New, NewFromPgxPool and NewFromEnv, where they are applied after the
+// options set in the environment.  Other stores are configured when they are created.
+func WithDbOptions(opts ...gorundb.Option) Option {
+	return func(o *options) {
+		o.dbOptions = append(o.dbOptions, opts...)
+	}
+}
+
+type TriggerOption func(*triggerOptions)
+
+// Do not fire the trigger at times excluded by the named calendars, see SaveCalendar.  Calendars are applied before
+// the bounds set by WithStartAt and WithEndAt.
+func WithCalendars(names ...string) TriggerOption {
+	return func(o *triggerOptions) {
+		o.calendars = append(o.calendars, names...)
+	}
+}
+
+// Do not fire the trigger before startAt.  The trigger fires as though it had been scheduled at startAt.
+func WithStartAt(startAt time.Time) TriggerOption {
+	return func(o *triggerOptions) {
+		o.startAt = startAt
+	}
+}
+
+// Do not fire the trigger after endAt, after which the trigger is finished.
+func WithEndAt(endAt time.Time) TriggerOption {
+	return func(o *triggerOptions) {
+		o.endAt = endAt
+	}
+}
+
+// Fire the trigger at most maxRuns times, after which the trigger is finished.
+func WithMaxRuns(maxRuns int) TriggerOption {
+	return func(o *triggerOptions) {
+		o.maxRuns = maxRuns
+	}
+}
+
+// Delete the trigger once it is finished, instead of keeping it with its finished time set.  Jobs it already
+// scheduled still run.
+func DeleteWhenFinished() TriggerOption {
+	return func(o *triggerOptions) {
+		o.deleteWhenFinished = true
+	}
+}
+
+// Align a repeat trigger to fire at anchor plus a whole number of intervals, instead of one interval after it was
+// scheduled, so that its phase does not change when it is scheduled again.  An anchor at midnight in a location aligns
+// a 15 minute repeat to :00, :15, :30 and :45 there.  It is an error to use with other kinds of trigger.
+func WithAnchor(anchor time.Time) TriggerOption {
+	return func(o *triggerOptions) {
+		o.anchor = anchor
+	}
+}
+
+// Run the job of an event or chain trigger delay after the event is fired or the upstream job finishes.
+func WithEventDelay(delay time.Duration) TriggerOption {
+	return func(o *triggerOptions) {
+		o.eventDelay = delay
+	}
+}
+
+// Run the job of an event trigger window after the last time the event is fired.  Events that are fired before the job
+// starts collapse into the one job, which runs with the payload of the last event.
+func WithDebounce(window time.Duration) TriggerOption {
+	return func(o *triggerOptions) {
+		o.eventDelay = window
+		o.debounce = true
+	}
+}
+
+// Run the job of an event trigger at most once per window.  Events that are fired before the job starts collapse into
+// the one job, which runs with the payload of the last event.
+func WithThrottle(window time.Duration) TriggerOption {
+	return func(o *triggerOptions) {
+		o.throttle = window
+	}
+}
+
+// Delay each run of the trigger by a random amount of time less than window, so that triggers with the same schedule
+// do not all run at once.  The window should be shorter than the time between runs.
+func WithJitter(window time.Duration) TriggerOption {
+	return func(o *triggerOptions) {
+		o.jitter = window
+		o.jitterMode = triggers.JitterRandom
+	}
+}
+
+// Like WithJitter, but the delay is chosen from the trigger id and is the same for every run, so the trigger still
+// runs at a regular period.
+func WithSpread(window time.Duration) TriggerOption {
+	return func(o *triggerOptions) {
+		o.jitter = window
+		o.jitterMode = triggers.JitterSpread
+	}
+}
+
+type ScheduleManyOption func(*scheduleManyOptions)
+
+// Schedule the jobs that are valid and can be saved, instead of none of the jobs when one of them fails.  The jobs are
+// saved in chunks, so a failure only affects the jobs of its chunk.
+func BestEffort() ScheduleManyOption {
+	return func(o *scheduleManyOptions) {
+		o.bestEffort = true
+	}
+}
+
+type JobFilter func(*gorundb.JobQuery)
+
+// Only return jobs with one of the statuses.
+func JobsWithStatus(statuses ...string) JobFilter {
+	return func(q *gorundb.JobQuery) {
+		q.Statuses = append(q.Statuses, statuses...)
+	}
+}
+
+// Only return jobs of one of the job types.
+func JobsOfType(jobTypes ...string) JobFilter {
+	return func(q *gorundb.JobQuery) {
+		q.Types = append(q.Types, jobTypes...)
+	}
+}
+
+// Only return jobs scheduled by the trigger.
+func JobsOfTrigger(triggerId string) JobFilter {
+	return func(q *gorundb.JobQuery) {
+		q.TriggerId = triggerId
+	}
+}
+
+// Only return jobs of the tenant.  Jobs of other tenants than the one in the context are never returned.
+func JobsOfTenant(tenantId string) JobFilter {
+	return func(q *gorundb.JobQuery) {
+		q.TenantId = tenantId
+	}
+}
+
+// Only return jobs created at or after from and before until.  A zero time leaves that end of the range open.
+func JobsCreatedBetween(from, until time.Time) JobFilter {
+	return func(q *gorundb.JobQuery) {
+		q.CreatedFrom, q.CreatedUntil = from, until
+	}
+}
+
+// Only return jobs last updated at or after from and before until.  A zero time leaves that end of the range open.
+func JobsUpdatedBetween(from, until time.Time) JobFilter {
+	return func(q *gorundb.JobQuery) {
+		q.UpdatedFrom, q.UpdatedUntil = from, until
+	}
+}
+
+// Only return jobs that run at or after from and before until.  A zero time leaves that end of the range open.
+func JobsRunBetween(from, until time.Time) JobFilter {
+	return func(q *gorundb.JobQuery) {
+		q.RunFrom, q.RunUntil = from, until
+	}
+}
+
+// Only return jobs with a result that contains the text, ignoring case.
+func JobsWithResultContaining(text string) JobFilter {
+	return func(q *gorundb.JobQuery) {
+		q.ResultContains = text
+	}
+}
+
+// Sort the jobs by the column, then by the order they were created in.
+func JobsOrderedBy(order gorundb.JobOrder, descending bool) JobFilter {
+	return func(q *gorundb.JobQuery) {
+		q.OrderBy = order
+		q.Descending = descending
+	}
+}
+
+// Return the page after the one with the cursor as its NextCursor.  The other filters must be the same as for that
+// page.
+func JobsAfter(cursor string) JobFilter {
+	return func(q *gorundb.JobQuery) {
+		q.After = cursor
+	}
+}
+
+// Return at most limit jobs, up to gorundb.MaxJobQueryLimit.  The default is gorundb.DefaultJobQueryLimit.
+func JobsLimit(limit int) JobFilter {
+	return func(q *gorundb.JobQuery) {
+		q.Limit = limit
+	}
+}
+
+type UpdateOption func(*triggerUpdate)
+
+// Replace the schedule of the trigger with a cron expression.  The location of a cron trigger is kept unless it is
+// also updated.
+func UpdateCronExpr(cronExpr string) UpdateOption {
+	return func(u *triggerUpdate) {
+		u.triggerType = "cron"
+		u.expr = cronExpr
+	}
+}
+
+// Replace the schedule of the trigger with an RRULE recurrence.
+func UpdateRRule(recurrence string) UpdateOption {
+	return func(u *triggerUpdate) {
+		u.triggerType = "rrule"
+		u.expr = recurrence
+	}
+}
+
+// Replace the schedule of the trigger with a repeat interval.
+func UpdateInterval(interval time.Duration) UpdateOption {
+	return func(u *triggerUpdate) {
+		u.triggerType = "repeat"
+		u.interval = interval
+	}
+}
+
+// Change the location of a cron trigger.
+func UpdateLocation(loc *time.Location) UpdateOption {
+	return func(u *triggerUpdate) {
+		u.loc = loc
+	}
+}
+
+// Replace the arguments of the job the trigger schedules.  The job type can not be changed.
+func UpdateJob(job JobData) UpdateOption {
+	return func(u *triggerUpdate) {
+		u.job = job
+	}
+}
+
+// Replace all the options of the trigger, such as its calendars and bounds, with the given options.
+func UpdateTriggerOptions(opts ...TriggerOption) UpdateOption {
+	return func(u *triggerUpdate) {
+		u.replaceOptions = true
+		u.opts = opts
+	}
+}
+
+// Only update the trigger if it is still at the given version, which is incremented each time the trigger is changed.
+func IfVersion(version int) UpdateOption {
+	return func(u *triggerUpdate) {
+		u.version = &version
+	}
+}
@@ -5,6 +5,7 @@ import (
 	"encoding/json"
 	"reflect"
 	"runtime/debug"
+	"strings"
 	"sync"
 	"time"
 
@@ -14,14 +15,20 @@ import (
 	"github.com/jswidler/gorun/tenantctx"
 	"github.com/jswidler/gorun/triggers"
 	"github.com/jswidler/gorun/triggers/crontrigger"
+	"github.com/jswidler/gorun/triggers/rruletrigger"
 	"github.com/jswidler/gorun/ulid"
 )
 
 var ErrUnregisteredJobType = errors.Sentinel("unregistered job type")
 var ErrGorunInternalError = errors.Sentinel("internal gorun job service error")
+var ErrInvalidInterval = errors.Sentinel("invalid interval")
+var ErrInvalidTriggerOption = errors.Sentinel("invalid trigger option")
+var ErrInvalidTriggerUpdate = errors.Sentinel("invalid trigger update")
+var ErrInvalidDedupKey = errors.Sentinel("invalid dedup key")
+var ErrValueTooLong = errors.Sentinel("value too long")
 
 type gorunner struct {
-	db *gorundb.Db
+	store gorundb.Store
 
 	batchSize  int
 	batchFreq  time.Duration
@@ -41,13 +48,99 @@ type options struct {
 	batchFreq      time.Duration
 	jobTimeout     time.Duration
 	disableLogging bool
+	dbOptions      []gorundb.Option
 
 	jobInit      func(ctx context.Context, jobType string, jobId string) context.Context
 	argProcessor func(ctx context.Context, jobType string, jobId string, args any) error
 	jobComplete  func(ctx context.Context, jobType string, jobId string, result string, err error)
 }
 
-func newGorunner(db *gorundb.Db, opts []Option) *gorunner {
+type triggerOptions struct {
+	calendars          []string
+	startAt            time.Time
+	endAt              time.Time
+	maxRuns            int
+	deleteWhenFinished bool
+	jitter             time.Duration
+	jitterMode         triggers.JitterMode
+	anchor             time.Time
+	eventDelay         time.Duration
+	debounce           bool
+	throttle           time.Duration
+}
+
+// triggerUpdate holds the changes to make to a saved trigger.  Fields that are not set are left as they are.
+type triggerUpdate struct {
+	triggerType    string
+	expr           string
+	interval       time.Duration
+	loc            *time.Location
+	job            JobData
+	replaceOptions bool
+	opts           []TriggerOption
+	version        *int
+}
+
+type scheduleManyOptions struct {
+	bestEffort bool
+}
+
+// anchored returns the trigger aligned to the anchor option, if it was given.
+func (o triggerOptions) anchored(trigger Trigger) (Trigger, error) {
+	if o.anchor.IsZero() {
+		return trigger, nil
+	}
+	rt, ok := trigger.(*triggers.RepeatTrigger)
+	if !ok {
+		return nil, errors.Wrap(ErrInvalidTriggerOption, errors.WithMessagef("%s triggers can not be anchored", trigger.Type()))
+	}
+	return triggers.NewAnchoredRepeatTrigger(rt.Interval, o.anchor), nil
+}
+
+// eventTrigger returns an event trigger for the event with the delay, debounce and throttle options.
+func (o triggerOptions) eventTrigger(event string) (*triggers.EventTrigger, error) {
+	trigger := triggers.NewEventTrigger(event)
+	trigger.Delay = o.eventDelay
+	trigger.Debounce = o.debounce
+	trigger.Throttle = o.throttle
+	err := trigger.Validate()
+	if err != nil {
+		return nil, errors.Wrap(ErrInvalidTriggerOption, errors.WithCause(err), errors.WithMessage(err.Error()))
+	}
+	return trigger, nil
+}
+
+// apply checks the options and sets them on the trigger to be saved.
+func (o triggerOptions) apply(trigger *gorundb.JobTrigger) error {
+	if o.maxRuns < 0 {
+		return errors.Wrap(ErrInvalidTriggerOption, errors.WithMessagef("max runs must not be negative, got %d", o.maxRuns))
+	}
+	if !o.startAt.IsZero() && !o.endAt.IsZero() && !o.endAt.After(o.startAt) {
+		return errors.Wrap(ErrInvalidTriggerOption, errors.WithMessage("trigger must end after it starts"))
+	}
+	if o.jitter < 0 {
+		return errors.Wrap(ErrInvalidTriggerOption, errors.WithMessagef("jitter must not be negative, got %s", o.jitter))
+	}
+	trigger.Calendars = o.calendars
+	if !o.startAt.IsZero() {
+		trigger.StartAt = &o.startAt
+	}
+	if !o.endAt.IsZero() {
+		trigger.EndAt = &o.endAt
+	}
+	if o.maxRuns > 0 {
+		trigger.MaxRuns = &o.maxRuns
+	}
+	trigger.DeleteWhenFinished = o.deleteWhenFinished
+	trigger.Jitter = o.jitter
+	trigger.JitterMode = string(triggers.JitterRandom)
+	if o.jitterMode != "" {
+		trigger.JitterMode = string(o.jitterMode)
+	}
+	return nil
+}
+
+func newOptions(opts []Option) options {
 	o := options{
 		batchSize:  10,
 		batchFreq:  1 * time.Second,
@@ -56,11 +149,16 @@ func newGorunner(db *gorundb.Db, opts []Option) *gorunner {
 	for _, opt := range opts {
 		opt(&o)
 	}
+	return o
+}
+
+func newGorunner(store gorundb.Store, opts []Option) *gorunner {
+	o := newOptions(opts)
 
 	logger.DisableLogging = o.disableLogging
 
 	return &gorunner{
-		db:           db,
+		store:        store,
 		batchSize:    o.batchSize,
 		batchFreq:    o.batchFreq,
 		jobTimeout:   o.jobTimeout,
@@ -130,70 +228,858 @@ func (g gorunner) ScheduleAfter(ctx context.Context, delay time.Duration, job Jo
 	return g.schedule(ctx, ulid.New(), triggers.NewRunOnceTrigger(delay), job)
 }
 
-func (g gorunner) ScheduleCron(ctx context.Context, cronExpr string, loc *time.Location, job JobData) (triggerId string, err error) {
+// scheduleManyChunkSize is how many jobs ScheduleMany saves at a time in best effort mode.
+const scheduleManyChunkSize = 1000
+
+func (g gorunner) ScheduleMany(ctx context.Context, jobs []ScheduledJob, opts ...ScheduleManyOption) ([]string, error) {
+	o := scheduleManyOptions{}
+	for _, opt := range opts {
+		opt(&o)
+	}
+	now := time.Now()
+	failed := map[int]error{}
+	jobIds := make([]string, len(jobs))
+	jobData := make([]*gorundb.JobData, 0, len(jobs))
+	index := make([]int, 0, len(jobs))
+	for i, job := range jobs {
+		if job.Delay < 0 {
+			failed[i] = errors.Wrap(ErrInvalidInterval, errors.WithMessagef("invalid delay %s", job.Delay))
+			continue
+		}
+		data, err := newJob(ctx, now.Add(job.Delay), job.Job)
+		if err != nil {
+			failed[i] = err
+			continue
+		}
+		jobData = append(jobData, data)
+		index = append(index, i)
+	}
+
+	if !o.bestEffort {
+		if len(failed) > 0 {
+			return nil, &ScheduleManyError{Errors: failed}
+		}
+		err := g.store.InsertJobs(ctx, jobData)
+		if err != nil {
+			return nil, err
+		}
+		for i, data := range jobData {
+			jobIds[index[i]] = data.Id
+		}
+		return jobIds, nil
+	}
+
+	for start := 0; start < len(jobData); start += scheduleManyChunkSize {
+		end := min(start+scheduleManyChunkSize, len(jobData))
+		err := g.store.InsertJobs(ctx, jobData[start:end])
+		if err == nil {
+			for i := start; i < end; i++ {
+				jobIds[index[i]] = jobData[i].Id
+			}
+			continue
+		}
+		// Save the jobs of the chunk one at a time, to find the ones that failed.
+		for i := start; i < end; i++ {
+			err = g.store.InsertJobs(ctx, jobData[i:i+1])
+			if err != nil {
+				failed[index[i]] = err
+			} else {
+				jobIds[index[i]] = jobData[i].Id
+			}
+		}
+	}
+	if len(failed) > 0 {
+		return jobIds, &ScheduleManyError{Errors: failed}
+	}
+	return jobIds, nil
+}
+
+func (g gorunner) ScheduleDebounced(ctx context.Context, key string, delay time.Duration, job JobData) (jobId string, err error) {
+	if delay < 0 {
+		return "", errors.Wrap(ErrInvalidInterval, errors.WithMessagef("invalid debounce delay %s", delay))
+	}
+	jobData, err := newDedupJob(ctx, key, time.Now().Add(delay), job)
+	if err != nil {
+		return "", err
+	}
+	jobId, _, err = g.store.DebounceJob(ctx, jobData)
+	return
+}
+
+func (g gorunner) ScheduleThrottled(ctx context.Context, key string, window time.Duration, job JobData) (jobId string, err error) {
+	if window <= 0 {
+		return "", errors.Wrap(ErrInvalidInterval, errors.WithMessagef("invalid throttle window %s", window))
+	}
+	jobData, err := newDedupJob(ctx, key, time.Now(), job)
+	if err != nil {
+		return "", err
+	}
+	jobId, _, err = g.store.ThrottleJob(ctx, jobData, window)
+	return
+}
+
+// maxDedupKeyLength leaves room in the dedup_key column for the job type.
+const maxDedupKeyLength = 120
+
+// The longest values, in bytes, that the columns of every store can hold.
+const (
+	maxTriggerIdLength = 128
+	maxTenantIdLength  = 128
+	maxJobTypeLength   = 255
+	maxEventLength     = 300 // room for the events of chained jobs
+)
+
+// checkLength returns ErrValueTooLong if value is longer than max bytes.
+func checkLength(name string, value string, max int) error {
+	if len(value) > max {
+		return errors.Wrap(ErrValueTooLong, errors.WithMessagef("%s must be at most %d bytes, got %d", name, max, len(value)))
+	}
+	return nil
+}
+
+// checkJobLength checks the job type and tenant id fit in the columns of a job.
+func checkJobLength(tenantId string, jobType string) error {
+	err := checkLength("tenant id", tenantId, maxTenantIdLength)
+	if err != nil {
+		return err
+	}
+	return checkLength("job type", jobType, maxJobTypeLength)
+}
+
+// newDedupJob returns the job to run at runAt, with a dedup key made from its type and key.
+func newDedupJob(ctx context.Context, key string, runAt time.Time, job JobData) (*gorundb.JobData, error) {
+	if key == "" || len(key) > maxDedupKeyLength {
+		return nil, errors.Wrap(ErrInvalidDedupKey, errors.WithMessagef("key must be between 1 and %d bytes, got %d", maxDedupKeyLength, len(key)))
+	}
+	jobData, err := newJob(ctx, runAt, job)
+	if err != nil {
+		return nil, err
+	}
+	dedupKey := "key:" + job.JobType() + ":" + key
+	jobData.DedupKey = &dedupKey
+	return jobData, nil
+}
+
+// newJob validates the job and returns it to run at runAt, without a trigger.
+func newJob(ctx context.Context, runAt time.Time, job JobData) (*gorundb.JobData, error) {
+	err := checkJobLength(tenantctx.GetTenant(ctx), job.JobType())
+	if err != nil {
+		return nil, err
+	}
+	if v, ok := job.(Validateable); ok {
+		err = v.Validate()
+		if err != nil {
+			return nil, err
+		}
+	}
+	args, err := json.Marshal(job)
+	if err != nil {
+		return nil, errors.Wrap(err)
+	}
+	var tenantIdRef *string
+	if tenantId := tenantctx.GetTenant(ctx); tenantId != "" {
+		tenantIdRef = &tenantId
+	}
+	return &gorundb.JobData{
+		Id:       ulid.New(),
+		TenantId: tenantIdRef,
+		Status:   string(StatusScheduled),
+		RunAt:    runAt,
+		Type:     job.JobType(),
+		Args:     string(args),
+	}, nil
+}
+
+func (g gorunner) ScheduleCron(ctx context.Context, cronExpr string, loc *time.Location, job JobData, opts ...TriggerOption) (triggerId string, err error) {
 	trigger, err := crontrigger.NewWithLoc(cronExpr, loc)
 	if err != nil {
 		return
 	}
 	triggerId = ulid.New()
-	_, err = g.schedule(ctx, triggerId, trigger, job)
+	_, err = g.schedule(ctx, triggerId, trigger, job, opts...)
 	return
 }
 
-func (g gorunner) ScheduleCronWithKey(ctx context.Context, triggerId string, cronExpr string, loc *time.Location, job JobData) (err error) {
+func (g gorunner) ScheduleCronWithKey(ctx context.Context, triggerId string, cronExpr string, loc *time.Location, job JobData, opts ...TriggerOption) (err error) {
 	trigger, err := crontrigger.NewWithLoc(cronExpr, loc)
 	if err != nil {
 		return
 	}
-	_, err = g.schedule(ctx, triggerId, trigger, job)
+	_, err = g.schedule(ctx, triggerId, trigger, job, opts...)
+	return
+}
+
+func (g gorunner) ScheduleRepeated(ctx context.Context, interval time.Duration, job JobData, opts ...TriggerOption) (triggerId string, err error) {
+	triggerId = ulid.New()
+	_, err = g.schedule(ctx, triggerId, triggers.NewRepeatTrigger(interval), job, opts...)
+	return
+}
+
+func (g gorunner) ScheduleRepeatedWithKey(ctx context.Context, triggerId string, interval time.Duration, job JobData, opts ...TriggerOption) (err error) {
+	_, err = g.schedule(ctx, triggerId, triggers.NewRepeatTrigger(interval), job, opts...)
 	return
 }
 
-func (g gorunner) ScheduleRepeated(ctx context.Context, interval time.Duration, job JobData) (triggerId string, err error) {
+func (g gorunner) ScheduleRRule(ctx context.Context, recurrence string, loc *time.Location, job JobData, opts ...TriggerOption) (triggerId string, err error) {
+	trigger, err := rruletrigger.NewWithLoc(recurrence, loc)
+	if err != nil {
+		return
+	}
 	triggerId = ulid.New()
-	_, err = g.schedule(ctx, triggerId, triggers.NewRepeatTrigger(interval), job)
+	_, err = g.schedule(ctx, triggerId, trigger, job, opts...)
 	return
 }
 
-func (g gorunner) ScheduleRepeatedWithKey(ctx context.Context, triggerId string, interval time.Duration, job JobData) (err error) {
-	_, err = g.schedule(ctx, triggerId, triggers.NewRepeatTrigger(interval), job)
+func (g gorunner) ScheduleRRuleWithKey(ctx context.Context, triggerId string, recurrence string, loc *time.Location, job JobData, opts ...TriggerOption) (err error) {
+	trigger, err := rruletrigger.NewWithLoc(recurrence, loc)
+	if err != nil {
+		return
+	}
+	_, err = g.schedule(ctx, triggerId, trigger, job, opts...)
 	return
 }
 
-func (g gorunner) schedule(ctx context.Context, triggerId string, trigger Trigger, job JobData) (jobId string, err error) {
+func (g gorunner) schedule(ctx context.Context, triggerId string, trigger Trigger, job JobData, opts ...TriggerOption) (jobId string, err error) {
 	if v, ok := job.(Validateable); ok {
 		err = v.Validate()
 		if err != nil {
 			return
 		}
 	}
+	o := triggerOptions{}
+	for _, opt := range opts {
+		opt(&o)
+	}
+	trigger, err = o.anchored(trigger)
+	if err != nil {
+		return
+	}
 
-	trig, jobData, err := g.firstRun(ctx, triggerId, trigger, job)
+	trig, jobData, err := g.firstRun(ctx, triggerId, trigger, job, o)
 	if err != nil {
 		return
 	}
 	jobId = jobData[0].Id
 	if trig == nil {
-		err = g.db.JobView.InsertJobs(ctx, jobData)
+		err = g.store.InsertJobs(ctx, jobData)
 	} else {
-		err = g.db.JobView.MaybeUpsertTriggerWithJobs(ctx, trig, jobData)
+		err = g.store.MaybeUpsertTriggerWithJobs(ctx, trig, jobData)
 	}
 	return
 }
 
+func (g gorunner) ScheduleOnEvent(ctx context.Context, event string, job JobData, opts ...TriggerOption) (triggerId string, err error) {
+	triggerId = ulid.New()
+	err = g.ScheduleOnEventWithKey(ctx, triggerId, event, job, opts...)
+	return
+}
+
+func (g gorunner) ScheduleOnEventWithKey(ctx context.Context, triggerId string, event string, job JobData, opts ...TriggerOption) error {
+	o := triggerOptions{}
+	for _, opt := range opts {
+		opt(&o)
+	}
+	trigger, err := o.eventTrigger(event)
+	if err != nil {
+		return err
+	}
+	return g.scheduleOnEvent(ctx, triggerId, event, trigger, job, o)
+}
+
+// scheduleOnEvent saves a trigger that is fired by the event.
+func (g gorunner) scheduleOnEvent(ctx context.Context, triggerId string, event string, trigger Trigger, job JobData, o triggerOptions) error {
+	err := checkLength("event", event, maxEventLength)
+	if err != nil {
+		return err
+	}
+	if v, ok := job.(Validateable); ok {
+		err := v.Validate()
+		if err != nil {
+			return err
+		}
+	}
+	dbTrigger, err := g.toDbTrigger(tenantctx.GetTenant(ctx), triggerId, trigger, job)
+	if err != nil {
+		return err
+	}
+	err = o.apply(dbTrigger)
+	if err != nil {
+		return err
+	}
+	// Check the calendars exist.
+	_, err = g.withTriggerOptions(ctx, trigger, dbTrigger, true)
+	if err != nil {
+		return err
+	}
+	dbTrigger.Event = &event
+	dbTrigger.ScheduledUntil = time.Now()
+	return g.store.MaybeUpsertTriggerWithJobs(ctx, dbTrigger, nil)
+}
+
+func (g gorunner) ScheduleChained(ctx context.Context, upstream Upstream, job JobData, opts ...TriggerOption) (triggerId string, err error) {
+	triggerId = ulid.New()
+	err = g.ScheduleChainedWithKey(ctx, triggerId, upstream, job, opts...)
+	return
+}
+
+func (g gorunner) ScheduleChainedWithKey(ctx context.Context, triggerId string, upstream Upstream, job JobData, opts ...TriggerOption) error {
+	if upstream.JobType == job.JobType() || upstream.TriggerId == triggerId {
+		return errors.Wrap(ErrInvalidTriggerOption, errors.WithMessage("a job can not be chained after itself"))
+	}
+	o := triggerOptions{}
+	for _, opt := range opts {
+		opt(&o)
+	}
+	trigger := triggers.NewChainTrigger(upstream.JobType, upstream.TriggerId, upstream.AnyStatus)
+	trigger.Delay = o.eventDelay
+	err := trigger.Validate()
+	if err != nil {
+		return err
+	}
+	return g.scheduleOnEvent(ctx, triggerId, trigger.Event(), trigger, job, o)
+}
+
+func (g gorunner) FireEvent(ctx context.Context, event string, payload any) error {
+	var args string
+	if payload != nil {
+		data, err := json.Marshal(payload)
+		if err != nil {
+			return errors.Wrap(err)
+		}
+		args = string(data)
+	}
+	eventTriggers, err := g.store.ListEventTriggers(ctx, event)
+	if err != nil {
+		return err
+	}
+	logger.Ctx(ctx).Info().Str("event", event).Int("triggerCount", len(eventTriggers)).Msg("firing event")
+	for _, trigger := range eventTriggers {
+		err = g.store.WithTriggerLock(ctx, trigger.Id, func(ctx context.Context, trigger *gorundb.JobTrigger) error {
+			return g.fireEventTrigger(ctx, trigger, args, nil, time.Now())
+		})
+		if err != nil && !errors.Is(err, gorundb.ErrNotFound) {
+			return err
+		}
+	}
+	return nil
+}
+
+// fireEventTrigger schedules the job for an event fired at now, by the upstream job if the trigger is a chain trigger.
+// The trigger must be locked by the caller.
+func (g gorunner) fireEventTrigger(ctx context.Context, trigger *gorundb.JobTrigger, args string, upstream *gorundb.JobData, now time.Time) error {
+	if trigger.FinishedAt != nil || trigger.PausedAt != nil {
+		return nil
+	}
+	trig, err := triggers.LoadTrigger(trigger.TriggerType, trigger.TriggerData)
+	if err != nil {
+		return err
+	}
+	var debounce bool
+	var throttle time.Duration
+	switch t := trig.(type) {
+	case *triggers.EventTrigger:
+		debounce, throttle = t.Debounce, t.Throttle
+	case *triggers.ChainTrigger:
+		if upstream == nil || (!t.AnyStatus && upstream.Status != string(StatusCompleted)) {
+			return nil
+		}
+	default:
+		return errors.Wrap(ErrGorunInternalError, errors.WithMessagef("trigger %s is not fired by events", trigger.Id))
+	}
+	trig, err = g.withTriggerOptions(ctx, trig, trigger, false)
+	if err != nil {
+		return err
+	}
+
+	prevScheduleUntil := trigger.ScheduledUntil
+	trigger.ScheduledUntil = now
+	var jobs []*gorundb.JobData
+	next, err := trig.NextFireTime(now)
+	if errors.Is(err, triggers.ErrTriggerExpired) {
+		trigger.FinishedAt = &now
+	} else if err != nil {
+		return err
+	} else {
+		job := newJobFromTrigger(trigger, next)
+		if args != "" {
+			job.Args = args
+		}
+		if upstream != nil {
+			job.UpstreamJobId = &upstream.Id
+		}
+		dedupKey := "trigger:" + trigger.Id
+		job.DedupKey = &dedupKey
+
+		created := true
+		if debounce {
+			_, created, err = g.store.DebounceJob(ctx, job)
+		} else if throttle > 0 {
+			_, created, err = g.store.ThrottleJob(ctx, job, throttle)
+		} else {
+			jobs = append(jobs, job)
+		}
+		if err != nil {
+			return err
+		}
+		if created {
+			trigger.RunCount++
+		}
+		if trigger.MaxRuns != nil && trigger.RunCount >= *trigger.MaxRuns {
+			trigger.FinishedAt = &now
+		}
+	}
+	if trigger.FinishedAt != nil {
+		logger.Ctx(ctx).Info().Str("triggerId", trigger.Id).Int("runCount", trigger.RunCount).Msg("trigger will not fire again")
+	}
+	return g.store.ScheduleNewJobsFromTrigger(ctx, trigger, prevScheduleUntil, jobs)
+}
+
+// fireChainTriggers schedules the jobs of the chain triggers that run after the job, which has finished.
+func (g gorunner) fireChainTriggers(ctx context.Context, job *gorundb.JobData) error {
+	events := []string{triggers.JobFinishedEvent(job.Type)}
+	if job.TriggerId != nil {
+		events = append(events, triggers.TriggerFinishedEvent(*job.TriggerId))
+	}
+	for _, event := range events {
+		chainTriggers, err := g.store.ListEventTriggers(ctx, event)
+		if err != nil {
+			return err
+		}
+		for _, trigger := range chainTriggers {
+			logger.Ctx(ctx).Info().Str("chainTriggerId", trigger.Id).Msg("scheduling chained job")
+			err = g.store.WithTriggerLock(ctx, trigger.Id, func(ctx context.Context, trigger *gorundb.JobTrigger) error {
+				return g.fireEventTrigger(ctx, trigger, "", job, time.Now())
+			})
+			if err != nil && !errors.Is(err, gorundb.ErrNotFound) {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
 func (g gorunner) GetJob(ctx context.Context, jobId string) (*gorundb.JobData, error) {
-	return g.db.JobView.GetJobById(ctx, jobId)
+	return g.store.GetJobById(ctx, jobId)
 }
 
 func (g gorunner) DeleteTrigger(ctx context.Context, triggerId string) error {
-	return g.db.JobView.DeleteTriggerById(ctx, triggerId)
+	return g.store.DeleteTriggerById(ctx, triggerId)
 }
 
+func (g gorunner) PreviewTrigger(expr string, loc *time.Location, n int) ([]time.Time, error) {
+	var trigger Trigger
+	if interval, err := time.ParseDuration(expr); err == nil {
+		if interval <= 0 {
+			return nil, errors.Wrap(ErrInvalidInterval, errors.WithMessagef("invalid repeat interval %s", expr))
+		}
+		trigger = triggers.NewRepeatTrigger(interval)
+	} else if isRecurrence(expr) {
+		trigger, err = rruletrigger.NewWithLoc(expr, loc)
+		if err != nil {
+			return nil, err
+		}
+	} else {
+		trigger, err = crontrigger.NewWithLoc(expr, loc)
+		if err != nil {
+			return nil, err
+		}
+	}
+	return triggers.NextFireTimes(trigger, time.Now(), n)
+}
+
+// isRecurrence reports whether expr looks like an RRULE recurrence rather than a cron expression.
+func isRecurrence(expr string) bool {
+	expr = strings.ToUpper(expr)
+	return strings.Contains(expr, "FREQ=") || strings.Contains(expr, "DTSTART")
+}
+
+func (g gorunner) NextRuns(ctx context.Context, triggerId string, n int) ([]time.Time, error) {
+	if n <= 0 {
+		return nil, nil
+	} else if n > triggers.MaxFireTimes {
+		return nil, errors.Wrap(triggers.ErrTooManyFireTimes, errors.WithMessagef("can not return %d runs, the most is %d", n, triggers.MaxFireTimes))
+	}
+	trigger, err := g.store.GetTriggerById(ctx, triggerId)
+	if err != nil {
+		return nil, err
+	}
+	trig, err := g.loadTrigger(ctx, trigger)
+	if err != nil {
+		return nil, err
+	}
+
+	// Jobs up to scheduled_until are already saved, the runs after that are yet to be created by the trigger.
+	jobs, err := g.store.ListScheduledJobsForTrigger(ctx, triggerId)
+	if err != nil {
+		return nil, err
+	}
+	runs := make([]time.Time, 0, max(n, 0))
+	for _, job := range jobs {
+		if len(runs) == n {
+			return runs, nil
+		}
+		runs = append(runs, job.RunAt)
+	}
+	remaining := n - len(runs)
+	if trigger.FinishedAt != nil || trigger.Event != nil {
+		return runs, nil
+	} else if trigger.MaxRuns != nil {
+		remaining = min(remaining, *trigger.MaxRuns-trigger.RunCount)
+	}
+	next, err := triggers.NextFireTimes(trig, trigger.ScheduledUntil, remaining)
+	if err != nil {
+		return nil, err
+	}
+	// Random jitter can not be known ahead of time, but a spread delays every run by the same amount.
+	if triggers.JitterMode(trigger.JitterMode) == triggers.JitterSpread {
+		offset := triggers.Jitter(triggers.JitterSpread, trigger.Jitter, trigger.Id)
+		for i := range next {
+			next[i] = next[i].Add(offset)
+		}
+	}
+	return append(runs, next...), nil
+}
+
+func (g gorunner) SaveCalendar(ctx context.Context, calendar *Calendar) error {
+	err := calendar.Validate()
+	if err != nil {
+		return err
+	}
+	data, err := calendar.Serialize()
+	if err != nil {
+		return err
+	}
+	var tenantIdRef *string
+	if tenantId := tenantctx.GetTenant(ctx); tenantId != "" {
+		tenantIdRef = &tenantId
+	}
+	return g.store.UpsertCalendar(ctx, &gorundb.JobCalendar{
+		Id:           calendar.Name,
+		TenantId:     tenantIdRef,
+		CalendarData: data,
+	})
+}
+
+func (g gorunner) GetCalendar(ctx context.Context, name string) (*Calendar, error) {
+	calendar, err := g.store.GetCalendarById(ctx, name)
+	if err != nil {
+		return nil, err
+	}
+	return triggers.DeserializeCalendar(calendar.Id, calendar.CalendarData)
+}
+
+func (g gorunner) ListCalendars(ctx context.Context) ([]*Calendar, error) {
+	rows, err := g.store.ListCalendars(ctx)
+	if err != nil {
+		return nil, err
+	}
+	calendars := make([]*Calendar, 0, len(rows))
+	for _, row := range rows {
+		calendar, err := triggers.DeserializeCalendar(row.Id, row.CalendarData)
+		if err != nil {
+			return nil, err
+		}
+		calendars = append(calendars, calendar)
+	}
+	return calendars, nil
+}
+
+func (g gorunner) DeleteCalendar(ctx context.Context, name string) error {
+	return g.store.DeleteCalendarById(ctx, name)
+}
+
+// loadTrigger loads a saved trigger with its calendars and bounds applied.
+func (g gorunner) loadTrigger(ctx context.Context, trigger *gorundb.JobTrigger) (Trigger, error) {
+	trig, err := triggers.LoadTrigger(trigger.TriggerType, trigger.TriggerData)
+	if err != nil {
+		return nil, err
+	}
+	return g.withTriggerOptions(ctx, trig, trigger, false)
+}
+
+// withTriggerOptions wraps the trigger with the calendars and bounds of the saved trigger.  When strict, it is an
+// error for a calendar not to exist, otherwise calendars that were deleted are ignored.
+func (g gorunner) withTriggerOptions(ctx context.Context, trig Trigger, trigger *gorundb.JobTrigger, strict bool) (Trigger, error) {
+	if len(trigger.Calendars) > 0 {
+		calendars, missing, err := g.loadCalendars(ctx, trigger.Calendars)
+		if err != nil {
+			return nil, err
+		}
+		if len(missing) > 0 {
+			if strict {
+				return nil, errors.Wrap(gorundb.ErrNotFound, errors.WithMessagef("calendars not found: %s", strings.Join(missing, ", ")))
+			}
+			logger.Ctx(ctx).Warn().Str("triggerId", trigger.Id).Strs("calendars", missing).Msg("trigger calendars not found")
+		}
+		trig, err = triggers.WithCalendars(trig, calendars...)
+		if err != nil {
+			return nil, err
+		}
+	}
+	if trigger.StartAt != nil || trigger.EndAt != nil {
+		var startAt, endAt time.Time
+		if trigger.StartAt != nil {
+			startAt = *trigger.StartAt
+		}
+		if trigger.EndAt != nil {
+			endAt = *trigger.EndAt
+		}
+		trig = triggers.WithBounds(trig, startAt, endAt)
+	}
+	return trig, nil
+}
+
+// loadCalendars returns the named calendars, and the names of any that do not exist.
+func (g gorunner) loadCalendars(ctx context.Context, names []string) ([]*Calendar, []string, error) {
+	if len(names) == 0 {
+		return nil, nil, nil
+	}
+	rows, err := g.store.GetCalendarsByIds(ctx, names)
+	if err != nil {
+		return nil, nil, err
+	}
+	byName := make(map[string]*gorundb.JobCalendar, len(rows))
+	for _, row := range rows {
+		byName[row.Id] = row
+	}
+	var calendars []*Calendar
+	var missing []string
+	for _, name := range names {
+		row, ok := byName[name]
+		if !ok {
+			missing = append(missing, name)
+			continue
+		}
+		calendar, err := triggers.DeserializeCalendar(row.Id, row.CalendarData)
+		if err != nil {
+			return nil, nil, err
+		}
+		calendars = append(calendars, calendar)
+	}
+	return calendars, missing, nil
+}
+
+func (g gorunner) UpdateTrigger(ctx context.Context, triggerId string, opts ...UpdateOption) error {
+	u := triggerUpdate{}
+	for _, opt := range opts {
+		opt(&u)
+	}
+	current, err := g.store.GetTriggerById(ctx, triggerId)
+	if err != nil {
+		return err
+	}
+	if u.version != nil && *u.version != current.Version {
+		return errors.Wrap(gorundb.ErrConflict, errors.WithMessagef("trigger %s is at version %d, not %d", triggerId, current.Version, *u.version))
+	}
+
+	updated := *current
+	trig, err := g.updatedTrigger(current, u)
+	if err != nil {
+		return err
+	}
+	if u.job != nil {
+		if u.job.JobType() != current.JobType {
+			return errors.Wrap(ErrInvalidTriggerUpdate, errors.WithMessagef("job type can not be changed from %s to %s", current.JobType, u.job.JobType()))
+		}
+		if v, ok := u.job.(Validateable); ok {
+			err = v.Validate()
+			if err != nil {
+				return err
+			}
+		}
+		jobArgs, err := json.Marshal(u.job)
+		if err != nil {
+			return errors.Wrap(err)
+		}
+		updated.JobArgs = string(jobArgs)
+	}
+	if u.replaceOptions {
+		o := triggerOptions{}
+		for _, opt := range u.opts {
+			opt(&o)
+		}
+		updated.Calendars, updated.StartAt, updated.EndAt, updated.MaxRuns = nil, nil, nil, nil
+		err = o.apply(&updated)
+		if err != nil {
+			return err
+		}
+		trig, err = o.anchored(trig)
+		if err != nil {
+			return err
+		}
+		switch t := trig.(type) {
+		case *triggers.EventTrigger:
+			trig, err = o.eventTrigger(t.Event)
+			if err != nil {
+				return err
+			}
+		case *triggers.ChainTrigger:
+			chained := *t
+			chained.Delay = o.eventDelay
+			trig = &chained
+		}
+	}
+
+	updated.TriggerType = trig.Type()
+	updated.TriggerData, err = trig.Serialize()
+	if err != nil {
+		return err
+	}
+
+	// The jobs that have not started are replaced, so they no longer count towards the max runs.
+	pending, err := g.store.ListScheduledJobsForTrigger(ctx, triggerId)
+	if err != nil {
+		return err
+	}
+	runs := current.RunCount - len(pending)
+
+	now := time.Now()
+	updated.FinishedAt = nil
+	updated.ScheduledUntil = now
+	var jobs []*gorundb.JobData
+	trig, err = g.withTriggerOptions(ctx, trig, &updated, true)
+	if err != nil {
+		return err
+	}
+	if updated.MaxRuns != nil && runs >= *updated.MaxRuns {
+		updated.FinishedAt = &now
+	} else if updated.PausedAt == nil && updated.Event == nil {
+		next, err := trig.NextFireTime(now)
+		if errors.Is(err, triggers.ErrTriggerExpired) {
+			updated.FinishedAt = &now
+		} else if err != nil {
+			return err
+		} else {
+			updated.ScheduledUntil = next
+			jobs = append(jobs, newJobFromTrigger(&updated, next))
+		}
+	}
+
+	logger.Ctx(ctx).Info().Str("triggerId", triggerId).Int("version", current.Version+1).Msg("updating trigger")
+	return g.store.UpdateTrigger(ctx, &updated, current, jobs)
+}
+
+// updatedTrigger returns the trigger with its schedule changed by the update.
+func (g gorunner) updatedTrigger(current *gorundb.JobTrigger, u triggerUpdate) (Trigger, error) {
+	trig, err := triggers.LoadTrigger(current.TriggerType, current.TriggerData)
+	if err != nil {
+		return nil, err
+	}
+	loc := time.UTC
+	switch t := trig.(type) {
+	case *crontrigger.CronTrigger:
+		loc = t.Loc()
+	case *rruletrigger.RRuleTrigger:
+		loc = t.Loc()
+	}
+	if u.loc != nil {
+		loc = u.loc
+	}
+
+	triggerType := u.triggerType
+	if triggerType == "" {
+		triggerType = current.TriggerType
+	}
+	switch triggerType {
+	case "cron":
+		expr := u.expr
+		if u.triggerType == "" {
+			expr = trig.(*crontrigger.CronTrigger).Expr()
+		}
+		return crontrigger.NewWithLoc(expr, loc)
+	case "rrule":
+		recurrence := u.expr
+		if u.triggerType == "" {
+			recurrence = trig.(*rruletrigger.RRuleTrigger).Recurrence()
+		}
+		return rruletrigger.NewWithLoc(recurrence, loc)
+	case "repeat":
+		if u.loc != nil {
+			return nil, errors.Wrap(ErrInvalidTriggerUpdate, errors.WithMessage("repeat triggers do not have a location"))
+		}
+		if u.triggerType == "" {
+			return trig, nil
+		}
+		if u.interval <= 0 {
+			return nil, errors.Wrap(ErrInvalidInterval, errors.WithMessagef("invalid repeat interval %s", u.interval))
+		}
+		// Changing the interval of an anchored repeat keeps it anchored.
+		if rt, ok := trig.(*triggers.RepeatTrigger); ok && rt.Anchor != nil {
+			return triggers.NewAnchoredRepeatTrigger(u.interval, *rt.Anchor), nil
+		}
+		return triggers.NewRepeatTrigger(u.interval), nil
+	case "event", "chain":
+		// The event, delay, debounce and throttle of an event trigger are its options.
+		if u.triggerType != "" || u.loc != nil {
+			return nil, errors.Wrap(ErrInvalidTriggerUpdate, errors.WithMessage("event triggers do not have a schedule"))
+		}
+		return trig, nil
+	}
+	return nil, errors.Wrap(ErrInvalidTriggerUpdate, errors.WithMessagef("%s triggers can not be updated", triggerType))
+}
+
+func (g gorunner) TriggerHistory(ctx context.Context, triggerId string) ([]*gorundb.TriggerAudit, error) {
+	return g.store.ListTriggerAudits(ctx, triggerId)
+}
+
+func (g gorunner) PauseTrigger(ctx context.Context, triggerId string) error {
+	// Look the trigger up first, so that it must exist and belong to the tenant.
+	_, err := g.store.GetTriggerById(ctx, triggerId)
+	if err != nil {
+		return err
+	}
+	logger.Ctx(ctx).Info().Str("triggerId", triggerId).Msg("pausing trigger")
+	return g.store.PauseTrigger(ctx, triggerId)
+}
+
+func (g gorunner) ResumeTrigger(ctx context.Context, triggerId string) error {
+	trigger, err := g.store.GetTriggerById(ctx, triggerId)
+	if err != nil {
+		return err
+	}
+	if trigger.PausedAt == nil {
+		return nil
+	}
+	logger.Ctx(ctx).Info().Str("triggerId", triggerId).Msg("resuming trigger")
+
+	now := time.Now()
+	err = g.store.ResumeTrigger(ctx, triggerId, now)
+	if err != nil {
+		return err
+	}
+	if trigger.Event != nil {
+		return nil
+	}
+	// Schedule the next jobs now rather than waiting for triggers to be processed.
+	trigger.PausedAt = nil
+	trigger.ScheduledUntil = now
+	err = g.scheduleJobsFromTrigger(ctx, trigger, now, now.Add(scheduleAhead))
+	if errors.Is(err, gorundb.ErrConflict) {
+		return nil
+	}
+	return err
+}
+
+func (g gorunner) PauseJobType(ctx context.Context, jobType string) error {
+	return g.store.PauseJobType(ctx, jobType)
+}
+
+func (g gorunner) ResumeJobType(ctx context.Context, jobType string) error {
+	return g.store.ResumeJobType(ctx, jobType)
+}
+
+func (g gorunner) ListPausedJobTypes(ctx context.Context) ([]string, error) {
+	return g.store.ListPausedJobTypes(ctx)
+}
+
+// scheduleAhead is how far into the future jobs are scheduled when triggers are processed.
+const scheduleAhead = 3 * time.Minute
+
 func (g gorunner) ProcessTriggers(ctx context.Context) error {
 	now := time.Now()
-	minScheduleTime := now.Add(3 * time.Minute)
+	minScheduleTime := now.Add(scheduleAhead)
 
-	triggers, err := g.db.JobView.GetTriggersToUpdate(ctx, minScheduleTime)
+	triggers, err := g.store.GetTriggersToUpdate(ctx, minScheduleTime)
 	if err != nil {
 		return err
 	}
@@ -213,20 +1099,33 @@ func (g gorunner) ProcessTriggers(ctx context.Context) error {
 }
 
 func (g gorunner) ListJobs(ctx context.Context, start, end time.Time) ([]*gorundb.JobData, error) {
-	return g.db.JobView.ListJobs(ctx, start, end)
+	return g.store.ListJobs(ctx, start, end)
+}
+
+func (g gorunner) QueryJobs(ctx context.Context, filters ...JobFilter) (*gorundb.JobPage, error) {
+	query := gorundb.JobQuery{}
+	for _, filter := range filters {
+		filter(&query)
+	}
+	return g.store.QueryJobs(ctx, query)
 }
 
 func (g gorunner) ListTriggers(ctx context.Context) ([]*gorundb.JobTrigger, error) {
-	return g.db.JobView.ListTriggers(ctx)
+	return g.store.ListTriggers(ctx)
 }
 
 func (g gorunner) MarkIncompleteJobs(ctx context.Context) error {
-	jobs, err := g.db.JobView.MarkIncompleteJobs(ctx, g.jobTimeout)
+	jobs, err := g.store.MarkIncompleteJobs(ctx, g.jobTimeout)
 	if err != nil {
 		return err
 	}
 	for _, job := range jobs {
 		logger.Ctx(ctx).Error().Str("failedJobId", job.Id).Str("failedJobType", job.Type).Msg("job timed out")
+		// The job failed without a result being written, so its chained jobs are scheduled here.
+		err = g.fireChainTriggers(ctx, job)
+		if err != nil {
+			logger.Ctx(ctx).Error().Err(err).Str("failedJobId", job.Id).Msg("failed to schedule chained jobs")
+		}
 	}
 	return nil
 }
@@ -239,16 +1138,25 @@ func (g gorunner) scheduleJobsFromTrigger(ctx context.Context, trigger *gorundb.
 		Msg("scheduling job for trigger")
 
 	prevScheduleUntil := trigger.ScheduledUntil // stored to prevent race conditions from scheduling the same job twice
-	trig, err := triggers.LoadTrigger(trigger.TriggerType, trigger.TriggerData)
+	trig, err := g.loadTrigger(ctx, trigger)
 	if err != nil {
 		return err
 	}
 
 	jobList := []*gorundb.JobData{}
 	next := trigger.ScheduledUntil
+	finished := false
 	for {
+		if trigger.MaxRuns != nil && trigger.RunCount >= *trigger.MaxRuns {
+			finished = true
+			break
+		}
 		next, err = trig.NextFireTime(next)
 		if err != nil {
+			if errors.Is(err, triggers.ErrTriggerExpired) {
+				finished = true
+				break
+			}
 			if len(jobList) == 0 {
 				// the first fire time should not be an error, otherwise expect the error to indicate the trigger should not be repeated by the run-once trigger
 				return err
@@ -261,19 +1169,24 @@ func (g gorunner) scheduleJobsFromTrigger(ctx context.Context, trigger *gorundb.
 		}
 		jobList = append(jobList, newJobFromTrigger(trigger, next))
 		trigger.ScheduledUntil = next
+		trigger.RunCount++
 		if next.After(minScheduleTime) {
 			break
 		}
 	}
+	if finished {
+		logger.Ctx(ctx).Info().Str("triggerId", trigger.Id).Int("runCount", trigger.RunCount).Msg("trigger will not fire again")
+		trigger.FinishedAt = &now
+	}
 
-	return g.db.JobView.ScheduleNewJobsFromTrigger(ctx, trigger, prevScheduleUntil, jobList)
+	return g.store.ScheduleNewJobsFromTrigger(ctx, trigger, prevScheduleUntil, jobList)
 }
 
 func (g *gorunner) runBatch(ctx context.Context) error {
 	l := logger.Ctx(ctx).With().Str("batchId", ulid.New()).Logger()
 	ctx = l.WithContext(ctx)
 
-	jobs, err := g.db.JobView.AcquireJobsToRun(ctx, g.batchSize)
+	jobs, err := g.store.AcquireJobsToRun(ctx, g.batchSize)
 	if err != nil {
 		logger.Ctx(ctx).Error().Err(err).Msg("error acquiring jobs")
 		return err
@@ -308,6 +1221,10 @@ func (g *gorunner) runJob(ctx context.Context, job *gorundb.JobData) {
 		l = l.Str("tenantId", *job.TenantId)
 		ctx = tenantctx.WithTenant(ctx, *job.TenantId)
 	}
+	if job.UpstreamJobId != nil {
+		l = l.Str("upstreamJobId", *job.UpstreamJobId)
+		ctx = context.WithValue(ctx, upstreamJobIdCtxKey, *job.UpstreamJobId)
+	}
 	ctx = l.Logger().WithContext(ctx)
 
 	logger.Ctx(ctx).Info().Msg("job starting")
@@ -397,10 +1314,15 @@ func (g gorunner) writeJobResult(ctx context.Context, job *gorundb.JobData, star
 	}
 	job.Result = &result
 
-	err2 := g.db.JobView.UpdateJob(ctx, job)
+	err2 := g.store.UpdateJob(ctx, job)
 	if err2 != nil {
 		// writeJobResult does not return an error because it is run as a defer.
 		logger.Ctx(ctx).Error().Err(err2).Msg("failed to write job result")
+	} else {
+		err2 = g.fireChainTriggers(ctx, job)
+		if err2 != nil {
+			logger.Ctx(ctx).Error().Err(err2).Msg("failed to schedule chained jobs")
+		}
 	}
 
 	dur := time.Since(start)
@@ -412,11 +1334,19 @@ func (g gorunner) writeJobResult(ctx context.Context, job *gorundb.JobData, star
 }
 
 // firsRun determines the first run time time of a job from the trigger and creates the job data and trigger to be saved to the gorundb.
-func (g gorunner) firstRun(ctx context.Context, triggerId string, trigger Trigger, jobData JobData) (*gorundb.JobTrigger, []*gorundb.JobData, error) {
+func (g gorunner) firstRun(ctx context.Context, triggerId string, trigger Trigger, jobData JobData, opts triggerOptions) (*gorundb.JobTrigger, []*gorundb.JobData, error) {
 	dbTrigger, err := g.toDbTrigger(tenantctx.GetTenant(ctx), triggerId, trigger, jobData)
 	if err != nil {
 		return nil, nil, err
 	}
+	err = opts.apply(dbTrigger)
+	if err != nil {
+		return nil, nil, err
+	}
+	trigger, err = g.withTriggerOptions(ctx, trigger, dbTrigger, true)
+	if err != nil {
+		return nil, nil, err
+	}
 
 	next, err := trigger.NextFireTime(time.Now())
 	if err != nil {
@@ -424,12 +1354,20 @@ func (g gorunner) firstRun(ctx context.Context, triggerId string, trigger Trigge
 	}
 
 	dbTrigger.ScheduledUntil = next
+	dbTrigger.RunCount = 1
 	jobList := []*gorundb.JobData{newJobFromTrigger(dbTrigger, next)}
+	if dbTrigger.MaxRuns != nil && *dbTrigger.MaxRuns == 1 {
+		now := time.Now()
+		dbTrigger.FinishedAt = &now
+	}
 
 	// Don't save run-once triggers to the database, just save the job data.
 	if dbTrigger.TriggerType == "run-once" {
 		dbTrigger = nil
 		jobList[0].TriggerId = nil
+	} else if dbTrigger.FinishedAt != nil && dbTrigger.DeleteWhenFinished {
+		// The trigger finished with its first run, so there is nothing left to save.
+		dbTrigger = nil
 	}
 
 	return dbTrigger, jobList, nil
@@ -446,6 +1384,14 @@ func getHandler(jobType string) (any, error) {
 }
 
 func (g gorunner) toDbTrigger(tenantId string, triggerId string, trigger Trigger, jobData JobData) (*gorundb.JobTrigger, error) {
+	err := checkLength("trigger id", triggerId, maxTriggerIdLength)
+	if err != nil {
+		return nil, err
+	}
+	err = checkJobLength(tenantId, jobData.JobType())
+	if err != nil {
+		return nil, err
+	}
 	jobArgs, err := json.Marshal(jobData)
 	if err != nil {
 		return nil, errors.Wrap(err)
@@ -465,10 +1411,13 @@ func (g gorunner) toDbTrigger(tenantId string, triggerId string, trigger Trigger
 		TriggerData: triggerArgs,
 		JobType:     jobData.JobType(),
 		JobArgs:     string(jobArgs),
+		Version:     1,
 	}, nil
 }
 
+// newJobFromTrigger returns a job for the trigger to run at runAt, delayed by the jitter of the trigger.
 func newJobFromTrigger(trigger *gorundb.JobTrigger, runAt time.Time) *gorundb.JobData {
+	runAt = runAt.Add(triggers.Jitter(triggers.JitterMode(trigger.JitterMode), trigger.Jitter, trigger.Id))
 	return &gorundb.JobData{
 		Id:        ulid.New(),
 		TenantId:  trigger.TenantId,
@@ -482,7 +1431,10 @@ func newJobFromTrigger(trigger *gorundb.JobTrigger, runAt time.Time) *gorundb.Jo
 
 type ctxKey int
 
-const gorunCtxKey ctxKey = iota
+const (
+	gorunCtxKey ctxKey = iota
+	upstreamJobIdCtxKey
+)
 
 func withGoRunner(ctx context.Context, service *gorunner) context.Context {
 	return context.WithValue(ctx, gorunCtxKey, service)
@@ -492,3 +1444,9 @@ func getGoRunner(ctx context.Context) *gorunner {
 	service, _ := ctx.Value(gorunCtxKey).(*gorunner)
 	return service
 }
+
+// UpstreamJobId returns the id of the job that a chained job was scheduled after, from the context of the chained job.
+func UpstreamJobId(ctx context.Context) string {
+	jobId, _ := ctx.Value(upstreamJobIdCtxKey).(string)
+	return jobId
+}
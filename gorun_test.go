@@ -0,0 +1,658 @@
+package gorun_test
+
+import (
+	"context"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/jswidler/gorun"
+	"github.com/jswidler/gorun/gorundb"
+	"github.com/jswidler/gorun/gorundb/memstore"
+	"github.com/jswidler/gorun/gorundb/sqlstore"
+	"github.com/jswidler/gorun/tenantctx"
+	"github.com/jswidler/gorun/triggers"
+	"github.com/stretchr/testify/assert"
+)
+
+type testJob struct {
+	Msg string
+}
+
+func (testJob) JobType() string {
+	return "gorun-test"
+}
+
+type chainedTestJob struct{}
+
+func (chainedTestJob) JobType() string {
+	return "gorun-test-chained"
+}
+
+var upstreamJobIds = make(chan string, 10)
+
+func init() {
+	gorun.RegisterHandler(func(ctx context.Context, args *testJob) (string, error) {
+		return args.Msg, nil
+	})
+	gorun.RegisterHandler(func(ctx context.Context, args *chainedTestJob) (string, error) {
+		upstreamJobIds <- gorun.UpstreamJobId(ctx)
+		return "", nil
+	})
+}
+
+func TestRunJobs(t *testing.T) {
+	testRunJobs(t, gorun.NewInMemory(gorun.WithBatchFreq(10*time.Millisecond), gorun.DisableLogging()))
+}
+
+func TestRunJobsSQLite(t *testing.T) {
+	store, err := sqlstore.OpenSQLite(filepath.Join(t.TempDir(), "gorun.db"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer store.Close()
+	testRunJobs(t, gorun.NewWithStore(store, gorun.WithBatchFreq(10*time.Millisecond), gorun.DisableLogging()))
+}
+
+func testRunJobs(t *testing.T, service gorun.GoRunService) {
+	ctx := context.Background()
+	_, err := service.ScheduleChained(ctx, gorun.Upstream{JobType: testJob{}.JobType()}, chainedTestJob{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = service.Start(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer service.Close()
+
+	jobId, err := service.ScheduleImmediately(ctx, testJob{Msg: "hello"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	select {
+	case upstreamJobId := <-upstreamJobIds:
+		assert.Equal(t, jobId, upstreamJobId)
+	case <-time.After(5 * time.Second):
+		t.Fatal("chained job did not run")
+	}
+
+	job, err := service.GetJob(ctx, jobId)
+	if err != nil {
+		t.Fatal(err)
+	}
+	assert.Equal(t, string(gorun.StatusCompleted), job.Status)
+	assert.Equal(t, "hello", *job.Result)
+}
+
+// recordingStore records the methods of the store that the service calls.
+type recordingStore struct {
+	gorundb.Store
+	mu    sync.Mutex
+	calls map[string]int
+}
+
+func (s *recordingStore) record(method string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.calls[method]++
+}
+
+func (s *recordingStore) count(method string) int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.calls[method]
+}
+
+func (s *recordingStore) AcquireJobsToRun(ctx context.Context, jobLimit int) ([]*gorundb.JobData, error) {
+	s.record("AcquireJobsToRun")
+	return s.Store.AcquireJobsToRun(ctx, jobLimit)
+}
+
+func (s *recordingStore) UpdateJob(ctx context.Context, job *gorundb.JobData) error {
+	s.record("UpdateJob")
+	return s.Store.UpdateJob(ctx, job)
+}
+
+func (s *recordingStore) MaybeUpsertTriggerWithJobs(ctx context.Context, jobTrigger *gorundb.JobTrigger, jobs []*gorundb.JobData) error {
+	s.record("MaybeUpsertTriggerWithJobs")
+	return s.Store.MaybeUpsertTriggerWithJobs(ctx, jobTrigger, jobs)
+}
+
+func TestServiceUsesStore(t *testing.T) {
+	ctx := context.Background()
+	store := &recordingStore{Store: memstore.New(), calls: map[string]int{}}
+	service := gorun.NewWithStore(store, gorun.WithBatchFreq(10*time.Millisecond), gorun.DisableLogging())
+	err := service.ScheduleRepeatedWithKey(ctx, "hourly", time.Hour, testJob{Msg: "hourly"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	assert.Equal(t, 1, store.count("MaybeUpsertTriggerWithJobs"))
+
+	err = service.Start(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer service.Close()
+	jobId, err := service.ScheduleImmediately(ctx, testJob{Msg: "stored"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	assert.Eventually(t, func() bool {
+		job, err := service.GetJob(ctx, jobId)
+		return err == nil && job.Status == string(gorun.StatusCompleted)
+	}, 5*time.Second, 10*time.Millisecond)
+	assert.Positive(t, store.count("AcquireJobsToRun"))
+	assert.Positive(t, store.count("UpdateJob"))
+}
+
+func TestTimedOutJobFiresChain(t *testing.T) {
+	ctx := context.Background()
+	store := memstore.New()
+	service := gorun.NewWithStore(store, gorun.WithBatchFreq(10*time.Millisecond), gorun.WithJobTimeout(50*time.Millisecond), gorun.DisableLogging())
+	_, err := service.ScheduleChained(ctx, gorun.Upstream{JobType: "gorun-test-stuck", AnyStatus: true}, chainedTestJob{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	// A job that started running and never finished.
+	stuck := &gorundb.JobData{Id: "stuck", Status: gorun.StatusRunning, Type: "gorun-test-stuck", Args: "{}", RunAt: time.Now()}
+	assert.NoError(t, store.InsertJobs(ctx, []*gorundb.JobData{stuck}))
+	err = service.Start(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer service.Close()
+
+	time.Sleep(100 * time.Millisecond)
+	_, err = service.ScheduleImmediately(ctx, gorun.MarkIncompleteJobs{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	select {
+	case upstreamJobId := <-upstreamJobIds:
+		assert.Equal(t, "stuck", upstreamJobId)
+	case <-time.After(5 * time.Second):
+		t.Fatal("chained job did not run")
+	}
+	job, err := service.GetJob(ctx, "stuck")
+	assert.NoError(t, err)
+	assert.Equal(t, gorun.StatusFailed, job.Status)
+}
+
+func TestTenantsAreSeparate(t *testing.T) {
+	service := gorun.NewInMemory(gorun.DisableLogging())
+	tenantA := tenantctx.WithTenant(context.Background(), "a")
+	tenantB := tenantctx.WithTenant(context.Background(), "b")
+
+	triggerId, err := service.ScheduleRepeated(tenantA, time.Hour, testJob{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	triggersA, err := service.ListTriggers(tenantA)
+	assert.NoError(t, err)
+	assert.Len(t, triggersA, 1)
+	triggersB, err := service.ListTriggers(tenantB)
+	assert.NoError(t, err)
+	assert.Empty(t, triggersB)
+
+	_, err = service.NextRuns(tenantB, triggerId, 1)
+	assert.ErrorIs(t, err, gorundb.ErrNotFound)
+	runs, err := service.NextRuns(tenantA, triggerId, 3)
+	assert.NoError(t, err)
+	assert.Len(t, runs, 3)
+	assert.Equal(t, time.Hour, runs[1].Sub(runs[0]))
+
+	err = service.SaveCalendar(tenantA, &gorun.Calendar{Name: "holidays", Rule: triggers.CalendarSkip, Dates: []string{"2024-12-25"}})
+	assert.NoError(t, err)
+	err = service.SaveCalendar(tenantB, &gorun.Calendar{Name: "holidays", Rule: triggers.CalendarSkip})
+	assert.ErrorIs(t, err, gorundb.ErrConflict)
+	calendar, err := service.GetCalendar(tenantA, "holidays")
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"2024-12-25"}, calendar.Dates)
+}
+
+func TestPreviewTrigger(t *testing.T) {
+	ctx := context.Background()
+	service := gorun.NewInMemory(gorun.DisableLogging())
+	runs, err := service.PreviewTrigger("90s", time.UTC, 3)
+	assert.NoError(t, err)
+	assert.Len(t, runs, 3)
+	assert.Equal(t, 90*time.Second, runs[2].Sub(runs[1]))
+
+	_, err = service.PreviewTrigger("0 * * * * ?", time.UTC, triggers.MaxFireTimes+1)
+	assert.ErrorIs(t, err, triggers.ErrTooManyFireTimes)
+	err = service.ScheduleRepeatedWithKey(ctx, "report", time.Hour, testJob{Msg: "hourly"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, err = service.NextRuns(ctx, "report", triggers.MaxFireTimes+1)
+	assert.ErrorIs(t, err, triggers.ErrTooManyFireTimes)
+}
+
+func TestUpdateTrigger(t *testing.T) {
+	store := memstore.New()
+	testUpdateTrigger(t, gorun.NewWithStore(store, gorun.DisableLogging()), store)
+}
+
+func TestUpdateTriggerSQLite(t *testing.T) {
+	store, err := sqlstore.OpenSQLite(filepath.Join(t.TempDir(), "gorun.db"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer store.Close()
+	testUpdateTrigger(t, gorun.NewWithStore(store, gorun.DisableLogging()), store)
+}
+
+func testUpdateTrigger(t *testing.T, service gorun.GoRunService, store gorundb.Store) {
+	ctx := context.Background()
+	err := service.ScheduleRepeatedWithKey(ctx, "report", time.Hour, testJob{Msg: "v1"})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	err = service.UpdateTrigger(ctx, "report", gorun.UpdateInterval(2*time.Hour), gorun.UpdateJob(testJob{Msg: "v2"}), gorun.IfVersion(1))
+	assert.NoError(t, err)
+	err = service.UpdateTrigger(ctx, "report", gorun.UpdateInterval(3*time.Hour), gorun.IfVersion(1))
+	assert.ErrorIs(t, err, gorundb.ErrConflict)
+	err = service.UpdateTrigger(ctx, "report", gorun.UpdateCronExpr("not cron"))
+	assert.Error(t, err)
+
+	// Only the update that was saved is in the history, with the definition it replaced.
+	history, err := service.TriggerHistory(ctx, "report")
+	assert.NoError(t, err)
+	if assert.Len(t, history, 1) {
+		assert.Equal(t, 2, history[0].Version)
+		assert.Contains(t, history[0].Previous, "v1")
+	}
+
+	// The pending jobs are rescheduled with the new interval and arguments.
+	jobs, err := store.ListScheduledJobsForTrigger(ctx, "report")
+	assert.NoError(t, err)
+	for _, job := range jobs {
+		assert.JSONEq(t, `{"Msg":"v2"}`, job.Args)
+	}
+	runs, err := service.NextRuns(ctx, "report", 2)
+	assert.NoError(t, err)
+	assert.Equal(t, 2*time.Hour, runs[1].Sub(runs[0]))
+	triggers, err := service.ListTriggers(ctx)
+	assert.NoError(t, err)
+	assert.JSONEq(t, `{"Msg":"v2"}`, triggers[0].JobArgs)
+
+	// The time zone of a cron trigger can be changed on its own.
+	newYork, err := time.LoadLocation("America/New_York")
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = service.ScheduleCronWithKey(ctx, "nightly", "0 0 2 * * ?", time.UTC, testJob{Msg: "nightly"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = service.UpdateTrigger(ctx, "nightly", gorun.UpdateLocation(newYork))
+	assert.NoError(t, err)
+	runs, err = service.NextRuns(ctx, "nightly", 1)
+	if assert.NoError(t, err) && assert.Len(t, runs, 1) {
+		assert.Equal(t, 2, runs[0].In(newYork).Hour())
+	}
+}
+
+func TestScheduleDebounced(t *testing.T) {
+	testScheduleDebounced(t, memstore.New())
+}
+
+func TestScheduleDebouncedSQLite(t *testing.T) {
+	store, err := sqlstore.OpenSQLite(filepath.Join(t.TempDir(), "gorun.db"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer store.Close()
+	testScheduleDebounced(t, store)
+}
+
+func testScheduleDebounced(t *testing.T, store gorundb.Store) {
+	ctx := context.Background()
+	// Two services on the same store, like two processes on the same database.
+	service := gorun.NewWithStore(store, gorun.DisableLogging())
+	other := gorun.NewWithStore(store, gorun.DisableLogging())
+	first, err := service.ScheduleDebounced(ctx, "index", time.Minute, testJob{Msg: "first"})
+	assert.NoError(t, err)
+	second, err := other.ScheduleDebounced(ctx, "index", 2*time.Minute, testJob{Msg: "second"})
+	assert.NoError(t, err)
+	assert.Equal(t, first, second)
+
+	job, err := service.GetJob(ctx, first)
+	assert.NoError(t, err)
+	assert.JSONEq(t, `{"Msg":"second"}`, job.Args)
+	assert.WithinDuration(t, time.Now().Add(2*time.Minute), job.RunAt, 5*time.Second)
+
+	// A job for the key has not started, so it takes the arguments of the throttled job.
+	throttled, err := other.ScheduleThrottled(ctx, "index", time.Minute, testJob{Msg: "throttled"})
+	assert.NoError(t, err)
+	assert.Equal(t, first, throttled)
+
+	exported, err := service.ScheduleThrottled(ctx, "export", time.Hour, testJob{Msg: "export"})
+	assert.NoError(t, err)
+	assert.NotEqual(t, first, exported)
+	job, err = service.GetJob(ctx, exported)
+	assert.NoError(t, err)
+	assert.WithinDuration(t, time.Now(), job.RunAt, 5*time.Second)
+
+	// Once the job has started, the next one waits for the window after it.
+	job.Status = string(gorun.StatusRunning)
+	assert.NoError(t, store.UpdateJob(ctx, job))
+	next, err := other.ScheduleThrottled(ctx, "export", time.Hour, testJob{Msg: "export again"})
+	assert.NoError(t, err)
+	assert.NotEqual(t, exported, next)
+	nextJob, err := service.GetJob(ctx, next)
+	assert.NoError(t, err)
+	assert.WithinDuration(t, job.RunAt.Add(time.Hour), nextJob.RunAt, time.Millisecond)
+
+	_, err = service.ScheduleDebounced(ctx, "index", -time.Minute, testJob{})
+	assert.ErrorIs(t, err, gorun.ErrInvalidInterval)
+	_, err = service.ScheduleThrottled(ctx, "", time.Minute, testJob{})
+	assert.ErrorIs(t, err, gorun.ErrInvalidDedupKey)
+}
+
+func TestFireEvent(t *testing.T) {
+	ctx := context.Background()
+	store := memstore.New()
+	service := gorun.NewWithStore(store, gorun.DisableLogging())
+	scheduled := func(triggerId string) []*gorundb.JobData {
+		jobs, err := store.ListScheduledJobsForTrigger(ctx, triggerId)
+		assert.NoError(t, err)
+		return jobs
+	}
+
+	// The payload replaces the job arguments, and the trigger finishes after its last run.
+	err := service.ScheduleOnEventWithKey(ctx, "signup", "user.created", testJob{Msg: "default"}, gorun.WithMaxRuns(2))
+	if err != nil {
+		t.Fatal(err)
+	}
+	assert.NoError(t, service.FireEvent(ctx, "user.created", testJob{Msg: "payload"}))
+	assert.NoError(t, service.FireEvent(ctx, "user.created", nil))
+	assert.NoError(t, service.FireEvent(ctx, "user.created", nil))
+	jobs := scheduled("signup")
+	if assert.Len(t, jobs, 2) {
+		args := []string{jobs[0].Args, jobs[1].Args}
+		assert.ElementsMatch(t, []string{`{"Msg":"payload"}`, `{"Msg":"default"}`}, args)
+	}
+	trigger, err := store.GetTriggerById(ctx, "signup")
+	assert.NoError(t, err)
+	assert.NotNil(t, trigger.FinishedAt)
+
+	// Debounced events collapse into one job, which moves to the window after the last event.
+	err = service.ScheduleOnEventWithKey(ctx, "reindex", "doc.edited", testJob{}, gorun.WithDebounce(time.Minute))
+	if err != nil {
+		t.Fatal(err)
+	}
+	assert.NoError(t, service.FireEvent(ctx, "doc.edited", testJob{Msg: "first"}))
+	assert.NoError(t, service.FireEvent(ctx, "doc.edited", testJob{Msg: "second"}))
+	jobs = scheduled("reindex")
+	if assert.Len(t, jobs, 1) {
+		assert.JSONEq(t, `{"Msg":"second"}`, jobs[0].Args)
+		assert.WithinDuration(t, time.Now().Add(time.Minute), jobs[0].RunAt, 5*time.Second)
+	}
+
+	// Throttled events that are fired before the job starts give it their payload.
+	err = service.ScheduleOnEventWithKey(ctx, "notify", "order.placed", testJob{}, gorun.WithThrottle(time.Minute))
+	if err != nil {
+		t.Fatal(err)
+	}
+	assert.NoError(t, service.FireEvent(ctx, "order.placed", testJob{Msg: "first"}))
+	assert.NoError(t, service.FireEvent(ctx, "order.placed", testJob{Msg: "second"}))
+	jobs = scheduled("notify")
+	if assert.Len(t, jobs, 1) {
+		assert.JSONEq(t, `{"Msg":"second"}`, jobs[0].Args)
+		assert.WithinDuration(t, time.Now(), jobs[0].RunAt, 5*time.Second)
+	}
+
+	// A paused trigger is not fired.
+	err = service.ScheduleOnEventWithKey(ctx, "audit", "user.deleted", testJob{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	assert.NoError(t, service.PauseTrigger(ctx, "audit"))
+	assert.NoError(t, service.FireEvent(ctx, "user.deleted", nil))
+	assert.Empty(t, scheduled("audit"))
+	assert.NoError(t, service.ResumeTrigger(ctx, "audit"))
+	assert.NoError(t, service.FireEvent(ctx, "user.deleted", nil))
+	assert.Len(t, scheduled("audit"), 1)
+}
+
+func TestScheduleRejectsLongValues(t *testing.T) {
+	ctx := context.Background()
+	service := gorun.NewInMemory(gorun.DisableLogging())
+	err := service.ScheduleRepeatedWithKey(ctx, strings.Repeat("k", 129), time.Hour, testJob{})
+	assert.ErrorIs(t, err, gorun.ErrValueTooLong)
+	_, err = service.ScheduleOnEvent(ctx, strings.Repeat("e", 301), testJob{})
+	assert.ErrorIs(t, err, gorun.ErrValueTooLong)
+	_, err = service.ScheduleRepeated(tenantctx.WithTenant(ctx, strings.Repeat("t", 129)), time.Hour, testJob{})
+	assert.ErrorIs(t, err, gorun.ErrValueTooLong)
+
+	// Long job arguments are not limited.
+	_, err = service.ScheduleRepeated(ctx, time.Hour, testJob{Msg: strings.Repeat("m", 10000)})
+	assert.NoError(t, err)
+}
+
+func TestScheduleMany(t *testing.T) {
+	ctx := context.Background()
+	service := gorun.NewInMemory(gorun.DisableLogging())
+	jobs := []gorun.ScheduledJob{
+		{Job: testJob{Msg: "a"}},
+		{Job: testJob{Msg: "b"}, Delay: -time.Minute},
+		{Job: testJob{Msg: "c"}, Delay: time.Minute},
+	}
+
+	// One invalid job keeps all of them from being scheduled.
+	jobIds, err := service.ScheduleMany(ctx, jobs)
+	assert.ErrorIs(t, err, gorun.ErrInvalidInterval)
+	var manyErr *gorun.ScheduleManyError
+	if assert.ErrorAs(t, err, &manyErr) {
+		assert.Len(t, manyErr.Errors, 1)
+		assert.Contains(t, manyErr.Errors, 1)
+	}
+	assert.Nil(t, jobIds)
+	list, err := service.ListJobs(ctx, time.Now().Add(-time.Hour), time.Now().Add(time.Hour))
+	assert.NoError(t, err)
+	assert.Empty(t, list)
+
+	jobIds, err = service.ScheduleMany(ctx, jobs, gorun.BestEffort())
+	assert.ErrorAs(t, err, &manyErr)
+	if assert.Len(t, jobIds, 3) {
+		assert.Empty(t, jobIds[1])
+		job, err := service.GetJob(ctx, jobIds[2])
+		assert.NoError(t, err)
+		assert.JSONEq(t, `{"Msg":"c"}`, job.Args)
+		assert.WithinDuration(t, time.Now().Add(time.Minute), job.RunAt, 5*time.Second)
+	}
+}
+
+func TestQueryJobs(t *testing.T) {
+	testQueryJobs(t, memstore.New())
+}
+
+func TestQueryJobsSQLite(t *testing.T) {
+	store, err := sqlstore.OpenSQLite(filepath.Join(t.TempDir(), "gorun.db"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer store.Close()
+	testQueryJobs(t, store)
+}
+
+func testQueryJobs(t *testing.T, store gorundb.Store) {
+	ctx := context.Background()
+	now := time.Now().Truncate(time.Millisecond)
+	failed, done := "Failed: Timeout_100%", "ok"
+	tenant, trigger := "a", "t1"
+	err := store.InsertJobs(ctx, []*gorundb.JobData{
+		{Id: "j1", Status: gorun.StatusCompleted, Type: "report", Args: "{}", RunAt: now.Add(3 * time.Minute), Result: &done},
+		{Id: "j2", Status: gorun.StatusFailed, Type: "report", Args: "{}", RunAt: now.Add(time.Minute), Result: &failed, TriggerId: &trigger},
+		{Id: "j3", Status: gorun.StatusScheduled, Type: "export", Args: "{}", RunAt: now.Add(2 * time.Minute), TenantId: &tenant},
+		{Id: "j4", Status: gorun.StatusScheduled, Type: "report", Args: "{}", RunAt: now.Add(2 * time.Minute), TriggerId: &trigger},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	service := gorun.NewWithStore(store, gorun.DisableLogging())
+	ids := func(page *gorundb.JobPage) []string {
+		var ids []string
+		for _, job := range page.Jobs {
+			ids = append(ids, job.Id)
+		}
+		return ids
+	}
+
+	page, err := service.QueryJobs(ctx, gorun.JobsOfType("report"), gorun.JobsWithStatus(gorun.StatusScheduled, gorun.StatusFailed))
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"j2", "j4"}, ids(page))
+	assert.Equal(t, 2, page.Total)
+	assert.Empty(t, page.NextCursor)
+
+	page, err = service.QueryJobs(ctx, gorun.JobsWithResultContaining("timeout_100%"))
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"j2"}, ids(page))
+	page, err = service.QueryJobs(ctx, gorun.JobsWithResultContaining("t%1"))
+	assert.NoError(t, err)
+	assert.Empty(t, ids(page))
+
+	page, err = service.QueryJobs(ctx, gorun.JobsOfTrigger(trigger), gorun.JobsRunBetween(now.Add(2*time.Minute), time.Time{}))
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"j4"}, ids(page))
+	page, err = service.QueryJobs(tenantctx.WithTenant(ctx, tenant))
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"j3"}, ids(page))
+
+	// Page through the jobs by run time, latest first.
+	var all []string
+	var cursor string
+	for {
+		filters := []gorun.JobFilter{gorun.JobsOrderedBy(gorundb.OrderByRunAt, true), gorun.JobsLimit(2)}
+		if cursor != "" {
+			filters = append(filters, gorun.JobsAfter(cursor))
+		}
+		page, err = service.QueryJobs(ctx, filters...)
+		if !assert.NoError(t, err) {
+			break
+		}
+		assert.Equal(t, 4, page.Total)
+		all = append(all, ids(page)...)
+		cursor = page.NextCursor
+		if cursor == "" {
+			break
+		}
+	}
+	assert.Equal(t, []string{"j1", "j4", "j3", "j2"}, all)
+
+	// The next page starts after the last job of the page even if that job moves.
+	byRunAt := []gorun.JobFilter{gorun.JobsOrderedBy(gorundb.OrderByRunAt, true), gorun.JobsLimit(2)}
+	page, err = service.QueryJobs(ctx, byRunAt...)
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"j1", "j4"}, ids(page))
+	j4, err := store.GetJobById(ctx, "j4")
+	if err != nil {
+		t.Fatal(err)
+	}
+	j4.RunAt = now.Add(10 * time.Minute)
+	assert.NoError(t, store.UpdateJob(ctx, j4))
+	page, err = service.QueryJobs(ctx, append(byRunAt, gorun.JobsAfter(page.NextCursor))...)
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"j3", "j2"}, ids(page))
+
+	_, err = service.QueryJobs(ctx, gorun.JobsOrderedBy("args", false))
+	assert.ErrorIs(t, err, gorundb.ErrInvalidQuery)
+	_, err = service.QueryJobs(ctx, gorun.JobsOrderedBy(gorundb.OrderByRunAt, false), gorun.JobsAfter("j1"))
+	assert.ErrorIs(t, err, gorundb.ErrInvalidQuery)
+	page, err = service.QueryJobs(ctx, gorun.JobsAfter("j2"))
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"j3", "j4"}, ids(page))
+}
+
+func TestPause(t *testing.T) {
+	store := memstore.New()
+	testPause(t, gorun.NewWithStore(store, gorun.DisableLogging()), store)
+}
+
+func TestPauseSQLite(t *testing.T) {
+	store, err := sqlstore.OpenSQLite(filepath.Join(t.TempDir(), "gorun.db"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer store.Close()
+	testPause(t, gorun.NewWithStore(store, gorun.DisableLogging()), store)
+}
+
+func testPause(t *testing.T, service gorun.GoRunService, store gorundb.Store) {
+	ctx := context.Background()
+	scheduled := func(triggerId string) []*gorundb.JobData {
+		jobs, err := store.ListScheduledJobsForTrigger(ctx, triggerId)
+		assert.NoError(t, err)
+		return jobs
+	}
+	runCount := func(triggerId string) int {
+		trigger, err := store.GetTriggerById(ctx, triggerId)
+		if !assert.NoError(t, err) {
+			return -1
+		}
+		return trigger.RunCount
+	}
+
+	// A paused job type is not acquired until it is resumed.
+	jobId, err := service.ScheduleImmediately(ctx, testJob{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	assert.NoError(t, service.PauseJobType(ctx, testJob{}.JobType()))
+	paused, err := service.ListPausedJobTypes(ctx)
+	assert.NoError(t, err)
+	assert.Equal(t, []string{testJob{}.JobType()}, paused)
+	time.Sleep(5 * time.Millisecond)
+	jobs, err := store.AcquireJobsToRun(ctx, 10)
+	assert.NoError(t, err)
+	assert.Empty(t, jobs)
+	assert.NoError(t, service.ResumeJobType(ctx, testJob{}.JobType()))
+	jobs, err = store.AcquireJobsToRun(ctx, 10)
+	assert.NoError(t, err)
+	if assert.Len(t, jobs, 1) {
+		assert.Equal(t, jobId, jobs[0].Id)
+	}
+
+	// Pausing a trigger removes its pending jobs, and they no longer count towards its runs.
+	err = service.ScheduleRepeatedWithKey(ctx, "limited", time.Minute, testJob{}, gorun.WithMaxRuns(2))
+	if err != nil {
+		t.Fatal(err)
+	}
+	assert.Len(t, scheduled("limited"), 1)
+	assert.Equal(t, 1, runCount("limited"))
+	assert.NoError(t, service.PauseTrigger(ctx, "limited"))
+	assert.Empty(t, scheduled("limited"))
+	assert.Equal(t, 0, runCount("limited"))
+
+	// Resuming schedules the remaining runs from now.
+	resumed := time.Now()
+	assert.NoError(t, service.ResumeTrigger(ctx, "limited"))
+	jobs = scheduled("limited")
+	if assert.Len(t, jobs, 2) {
+		assert.WithinDuration(t, resumed.Add(time.Minute), jobs[0].RunAt, 5*time.Second)
+	}
+	assert.Equal(t, 2, runCount("limited"))
+
+	// Runs missed while a trigger was paused are not made up.
+	err = service.ScheduleRepeatedWithKey(ctx, "hourly", time.Minute, testJob{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	assert.NoError(t, service.PauseTrigger(ctx, "hourly"))
+	assert.NoError(t, store.ResumeTrigger(ctx, "hourly", time.Now().Add(-time.Hour)))
+	assert.NoError(t, store.PauseTrigger(ctx, "hourly"))
+	resumed = time.Now()
+	assert.NoError(t, service.ResumeTrigger(ctx, "hourly"))
+	jobs = scheduled("hourly")
+	assert.NotEmpty(t, jobs)
+	// Only the runs of the next few minutes are scheduled, not the hour that was missed.
+	assert.LessOrEqual(t, len(jobs), 4)
+	for _, job := range jobs {
+		assert.False(t, job.RunAt.Before(resumed), "job scheduled at %s, before the trigger was resumed", job.RunAt)
+	}
+}
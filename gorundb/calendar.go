@@ -0,0 +1,60 @@
+package gorundb
+
+import (
+	"context"
+	"time"
+
+	"github.com/jmoiron/sqlx"
+	"github.com/jswidler/gorun/errors"
+	"github.com/jswidler/gorun/logger"
+	"github.com/jswidler/gorun/tenantctx"
+)
+
+type JobCalendar struct {
+	Id           string    `db:"id" json:"id"`
+	TenantId     *string   `db:"tenant_id" json:"tenantId"`
+	CreatedAt    time.Time `db:"created_at" json:"createdAt"`
+	UpdatedAt    time.Time `db:"updated_at" json:"updatedAt"`
+	CalendarData string    `db:"calendar_data" json:"calendarData"`
+}
+
+func (view JobView) UpsertCalendar(ctx context.Context, calendar *JobCalendar) error {
+	logger.Ctx(ctx).Info().Str("calendarId", calendar.Id).Msg("upserting calendar")
+	return view.db.useTx(ctx, func(ctx context.Context, tx *sqlx.Tx) error {
+		// The calendar of another tenant is left as it is, and nothing is updated.
+		now := time.Now().UTC()
+		r, err := tx.ExecContext(ctx, view.db.sql(`INSERT INTO {calendar} AS c ("id", "tenant_id", "created_at", "updated_at", "calendar_data")
+			VALUES ($1, $2, $3, $3, $4)
+			ON CONFLICT ("id") DO UPDATE SET "updated_at" = EXCLUDED."updated_at", "calendar_data" = EXCLUDED."calendar_data"
+			WHERE c."tenant_id" IS NOT DISTINCT FROM EXCLUDED."tenant_id"`),
+			calendar.Id, calendar.TenantId, now, calendar.CalendarData)
+		if err != nil {
+			return errors.Wrap(ErrDatabaseError, errors.WithCause(err))
+		} else if n, err := r.RowsAffected(); err != nil {
+			return errors.Wrap(ErrDatabaseError, errors.WithCause(err))
+		} else if n != 1 {
+			return errors.Wrap(ErrConflict, errors.WithMessagef("calendar %s belongs to another tenant", calendar.Id))
+		}
+		return nil
+	})
+}
+
+func (view JobView) GetCalendarById(ctx context.Context, calendarId string) (*JobCalendar, error) {
+	return byId[JobCalendar](ctx, view.db.db, view.db.table("calendar"), calendarId)
+}
+
+func (view JobView) GetCalendarsByIds(ctx context.Context, calendarIds []string) ([]*JobCalendar, error) {
+	return byIds[JobCalendar](ctx, view.db.db, view.db.table("calendar"), calendarIds)
+}
+
+func (view JobView) ListCalendars(ctx context.Context) ([]*JobCalendar, error) {
+	tenantId := tenantctx.GetTenant(ctx)
+	if tenantId != "" {
+		return queryMany[JobCalendar](ctx, view.db.db, view.db.sql(`SELECT * FROM {calendar} WHERE "tenant_id" = $1`), tenantId)
+	}
+	return queryMany[JobCalendar](ctx, view.db.db, view.db.sql(`SELECT * FROM {calendar}`))
+}
+
+func (view JobView) DeleteCalendarById(ctx context.Context, calendarId string) error {
+	return deleteById(ctx, view.db.db, view.db.table("calendar"), calendarId)
+}
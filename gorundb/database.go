@@ -1,49 +1,112 @@
 package gorundb
 
 import (
+	"context"
 	"database/sql"
 	"fmt"
 	"strings"
 	"time"
 
 	"github.com/caarlos0/env/v11"
+	"github.com/jackc/pgx/v5/pgxpool"
+	"github.com/jackc/pgx/v5/stdlib"
 	"github.com/jmoiron/sqlx"
 	"github.com/jswidler/gorun/errors"
 	"github.com/jswidler/gorun/logger"
-	_ "github.com/lib/pq"
 )
 
 type Db struct {
 	JobView JobView
 
-	db *sqlx.DB
+	db     *sqlx.DB
+	opts   dbOptions
+	tables *strings.Replacer
 }
 
-func New(db *sql.DB) (*Db, error) {
+// New returns the database after migrating it to the latest version, or checking it is at the latest version with
+// WithoutMigrations.  db can be opened with either the pgx or the
+// lib/pq driver.
+func New(db *sql.DB, opts ...Option) (*Db, error) {
+	o, err := newDbOptions(opts)
+	if err != nil {
+		return nil, err
+	}
 	d := &Db{
-		db: sqlx.NewDb(db, "postgres"),
+		db:     sqlx.NewDb(db, "postgres"),
+		opts:   o,
+		tables: o.replacer(),
 	}
 
 	d.JobView.db = d
 
+	if o.skipMigrations {
+		return d, d.CheckMigrations()
+	}
 	return d, d.MigrateUp()
 }
 
+// NewFromPgxPool returns the database of the pool after migrating it to the latest version.  Closing the Db does not
+// close the pool.
+func NewFromPgxPool(pool *pgxpool.Pool, opts ...Option) (*Db, error) {
+	return New(stdlib.OpenDBFromPool(pool), opts...)
+}
+
+// Drivers that NewFromEnv can connect to, selected with GORUN_DB_DRIVER.
+const (
+	DriverPostgres = "postgres"
+	DriverSQLite   = "sqlite"
+	DriverMySQL    = "mysql"
+)
+
+var ErrUnsupportedDriver = errors.Sentinel("unsupported database driver")
+
+// DatabaseConfig is the database to connect to.  The user, password, host, port and database name are used by the
+// postgres and mysql drivers.
 type DatabaseConfig struct {
+	Driver string `env:"GORUN_DB_DRIVER" envDefault:"postgres"`
+	// SQLitePath is the database file used with the sqlite driver.
+	SQLitePath string `env:"GORUN_DB_SQLITE_PATH" envDefault:"gorun.db"`
+
 	User            string `env:"GORUN_DB_USER" envDefault:"postgres"`
 	Password        string `env:"GORUN_DB_PASSWORD" envDefault:"postgres"`
 	Host            string `env:"GORUN_DB_HOST" envDefault:"localhost"`
-	Port            string `env:"GORUN_DB_PORT" envDefault:"5432"`
+	Port            string `env:"GORUN_DB_PORT"` // defaults to the port of the driver
 	DatabaseName    string `env:"GORUN_DB_DATABASE_NAME" envDefault:"postgres"`
 	SslMode         string `env:"GORUN_DB_SSL_MODE" envDefault:"require"`
 	ApplicationName string `env:"GORUN_DB_APPLICATION_NAME" envDefault:"gorun"`
+
+	// Schema, TablePrefix and MigrationTable are used by the postgres driver.  See WithSchema, WithTablePrefix and
+	// WithMigrationTable.
+	Schema         string `env:"GORUN_DB_SCHEMA"`
+	TablePrefix    string `env:"GORUN_DB_TABLE_PREFIX"`
+	MigrationTable string `env:"GORUN_DB_MIGRATION_TABLE"`
+	// SkipMigrations checks the postgres database has been migrated instead of migrating it.  See WithoutMigrations.
+	SkipMigrations bool `env:"GORUN_DB_SKIP_MIGRATIONS"`
 }
 
-func NewFromEnv() (*Db, error) {
+// ConfigFromEnv reads the database config from the environment.
+func ConfigFromEnv() (DatabaseConfig, error) {
 	config := DatabaseConfig{}
 	err := env.Parse(&config)
 	if err != nil {
-		return nil, errors.Wrap(err, errors.WithMessage("failed to read database config from environment"))
+		return config, errors.Wrap(err, errors.WithMessage("failed to read database config from environment"))
+	}
+	return config, nil
+}
+
+// NewFromEnv connects to the Postgres database in the config read from the environment.
+func NewFromEnv() (*Db, error) {
+	config, err := ConfigFromEnv()
+	if err != nil {
+		return nil, err
+	}
+	return NewFromConfig(config)
+}
+
+// NewFromConfig connects to the Postgres database in the config.  opts are applied after the options of the config.
+func NewFromConfig(config DatabaseConfig, opts ...Option) (*Db, error) {
+	if config.Driver != DriverPostgres {
+		return nil, errors.Wrap(ErrUnsupportedDriver, errors.WithMessagef("driver %s is not postgres", config.Driver))
 	}
 
 	logger.Default().Info().
@@ -51,25 +114,54 @@ func NewFromEnv() (*Db, error) {
 		Str("dbName", config.DatabaseName).
 		Msg("connecting to postgres")
 
-	connString := config.ConnString()
-	var db *sql.DB
-	for i := 1; i <= 3; i++ {
-		var err error
-		db, err = sql.Open("postgres", connString)
+	db, err := connect(config.ConnString())
+	if err != nil {
+		return nil, err
+	}
+	return New(db, append(config.Options(), opts...)...)
+}
+
+// connect tries to reach the database connectAttempts times, connectRetryDelay apart, waiting up to connectTimeout
+// each time.
+const (
+	connectAttempts = 3
+	connectTimeout  = 10 * time.Second
+)
+
+var connectRetryDelay = 5 * time.Second
+
+// connect opens the database and checks it can be reached, retrying if it can not.
+func connect(connString string) (*sql.DB, error) {
+	for i := 1; ; i++ {
+		db, err := sql.Open("pgx", connString)
 		if err == nil {
-			_, err = db.Query("SELECT 1")
+			ctx, cancel := context.WithTimeout(context.Background(), connectTimeout)
+			err = db.PingContext(ctx)
+			cancel()
+			if err == nil {
+				return db, nil
+			}
+			db.Close()
 		}
-		if err == nil {
-			break
-		} else if i < 6 {
-			logger.Default().Warn().Err(err).Msg("failed to connect to postgres, retrying")
-			time.Sleep(time.Second * 5)
-		} else {
-			return nil, errors.Wrap(err, errors.WithMessage("failed to connect to postgres"))
+		if i == connectAttempts {
+			return nil, errors.Wrap(err, errors.WithMessagef("failed to connect to postgres after %d attempts", i))
 		}
+		logger.Default().Warn().Err(err).Msg("failed to connect to postgres, retrying")
+		time.Sleep(connectRetryDelay)
 	}
+}
 
-	return New(db)
+// Options returns the options for the schema and table names in the config.  Names that are not set keep their
+// defaults.
+func (c DatabaseConfig) Options() []Option {
+	opts := []Option{WithSchema(c.Schema), WithMigrationTable(c.MigrationTable)}
+	if c.TablePrefix != "" {
+		opts = append(opts, WithTablePrefix(c.TablePrefix))
+	}
+	if c.SkipMigrations {
+		opts = append(opts, WithoutMigrations())
+	}
+	return opts
 }
 
 func (d *Db) Close() error {
@@ -82,6 +174,10 @@ func (c DatabaseConfig) ConnString() string {
 		return fmt.Sprintf("user=%s password=%s database=%s host=%s",
 			c.User, c.Password, c.DatabaseName, c.Host)
 	}
+	port := c.Port
+	if port == "" {
+		port = "5432"
+	}
 	return fmt.Sprintf("postgresql://%s:%s@%s:%s/%s?connect_timeout=10&sslmode=%s&application_name=%s",
-		c.User, c.Password, c.Host, c.Port, c.DatabaseName, c.SslMode, c.ApplicationName)
+		c.User, c.Password, c.Host, port, c.DatabaseName, c.SslMode, c.ApplicationName)
 }
@@ -2,9 +2,13 @@ package gorundb
 
 import (
 	"embed"
+	"fmt"
 	"io/fs"
-	"net/http"
+	"os"
+	"path"
+	"path/filepath"
 	"sort"
+	"strings"
 	"time"
 
 	"github.com/jswidler/gorun/errors"
@@ -15,19 +19,35 @@ import (
 
 const dialect = "postgres"
 
+// The migrations refer to tables and indexes with the tokens of Db.sql, so they can be applied with any schema and
+// table prefix.  sql-migrate tracks the migrations that have been applied by the name of the file, not its contents,
+// so a released migration must never be changed; add a new migration instead.  The migrations that were released
+// before the tokens were introduced render to the same SQL they had with the default options, which is checked against
+// the copies in testdata/released_migrations.
+//
 //go:embed migrations/*
 var migrationFiles embed.FS
 
 var (
-	ErrDbMigrationFailed = errors.Sentinel("database migration failed")
-	ErrDbResetNotAllowed = errors.Sentinel("database reset is not allowed due to settings")
+	ErrDbMigrationFailed   = errors.Sentinel("database migration failed")
+	ErrDbResetNotAllowed   = errors.Sentinel("database reset is not allowed due to settings")
+	ErrDbMigrationsPending = errors.Sentinel("database migrations have not been applied")
 )
 
 // MigrateUp migrates the database to the latest version
 func (d *Db) MigrateUp() error {
 	logger.Default().Info().Msg("checking database is up to date")
-	migrations := getMigrations()
-	n, err := migrate.Exec(d.db.DB, dialect, migrations, migrate.Up)
+	migrations, err := d.migrations()
+	if err != nil {
+		return err
+	}
+	if d.opts.schema != "" {
+		_, err = d.db.Exec(fmt.Sprintf(`CREATE SCHEMA IF NOT EXISTS "%s"`, d.opts.schema))
+		if err != nil {
+			return errors.Wrap(ErrDbMigrationFailed, errors.WithCause(err))
+		}
+	}
+	n, err := d.migrationSet().Exec(d.db.DB, dialect, migrations, migrate.Up)
 	if err != nil {
 		return errors.Wrap(ErrDbMigrationFailed, errors.WithCause(err))
 	}
@@ -43,11 +63,14 @@ func (d *Db) MigrateUp() error {
 
 // MigrateDown will roll back the database at most `max` migrations. USE CAREFULLY!!! Pass 0 for no limit.
 func (d *Db) MigrateDown(max int) error {
-	migrations := getMigrations()
+	migrations, err := d.migrations()
+	if err != nil {
+		return err
+	}
 
 	logger.Default().Info().Msg("rolling back a database migration")
 
-	n, err := migrate.ExecMax(d.db.DB, dialect, migrations, migrate.Down, max)
+	n, err := d.migrationSet().ExecMax(d.db.DB, dialect, migrations, migrate.Down, max)
 	if err != nil {
 		return errors.Wrap(ErrDbMigrationFailed, errors.WithCause(err))
 	}
@@ -60,27 +83,82 @@ func (d *Db) MigrateDown(max int) error {
 	return nil
 }
 
-// MigrateStatus will log the status of all known migrations
-func (d *Db) MigrateStatus() error {
+// MigrateStatus will log and return the status of all known migrations, in order
+func (d *Db) MigrateStatus() ([]*MigrationStatus, error) {
+	rowList, err := d.migrationStatus()
+	if err != nil {
+		return nil, err
+	}
+
+	for _, r := range rowList {
+		if r.Migrated {
+			if r.MigrationFileMissing {
+				log.Info().Msgf("%s: %s (migration file missing)", r.ID, r.AppliedAt.String())
+			} else {
+				log.Info().Msgf("%s: %s", r.ID, r.AppliedAt.String())
+			}
+		} else {
+			log.Info().Msgf("%s: not applied", r.ID)
+		}
+	}
+
+	return rowList, nil
+}
+
+// CheckMigrations returns ErrDbMigrationsPending if any of the migrations has not been applied to the database.
+func (d *Db) CheckMigrations() error {
+	rowList, err := d.migrationStatus()
+	if err != nil {
+		return err
+	}
+	return checkMigrationStatus(rowList)
+}
+
+// checkMigrationStatus returns ErrDbMigrationsPending if any of the migrations has not been applied.
+func checkMigrationStatus(rowList []*MigrationStatus) error {
+	var pending []string
+	for _, r := range rowList {
+		if !r.Migrated {
+			pending = append(pending, r.ID)
+		}
+	}
+	if len(pending) > 0 {
+		return errors.Wrap(ErrDbMigrationsPending, errors.WithMessagef("migrations not applied: %s", strings.Join(pending, ", ")))
+	}
+	return nil
+}
+
+func (d *Db) migrationStatus() ([]*MigrationStatus, error) {
 	// Get all migrations found in the app
-	migrations, err := getMigrations().FindMigrations()
+	source, err := d.migrations()
 	if err != nil {
-		return errors.Wrap(ErrDbMigrationFailed, errors.WithCause(err))
+		return nil, err
+	}
+	migrations, err := source.FindMigrations()
+	if err != nil {
+		return nil, errors.Wrap(ErrDbMigrationFailed, errors.WithCause(err))
 	}
 
 	// Get all migrations found in the database
-	records, err := migrate.GetMigrationRecords(d.db.DB, dialect)
+	records, err := d.migrationSet().GetMigrationRecords(d.db.DB, dialect)
 	if err != nil {
-		return errors.Wrap(ErrDbMigrationFailed, errors.WithCause(err))
+		return nil, errors.Wrap(ErrDbMigrationFailed, errors.WithCause(err))
 	}
 
+	return combineMigrationStatus(migrations, records), nil
+}
+
+// combineMigrationStatus returns the status of the migrations found in the app and in the database, in order.
+// Migrations are matched by id, which is the name of the file, so the status does not depend on the options the
+// migrations are rendered with.
+func combineMigrationStatus(migrations []*migrate.Migration, records []*migrate.MigrationRecord) []*MigrationStatus {
 	// Combine the information - it's possible to find migrations in one but not the other
 	// rowList is so we can print in order.  rowMap is so we can find the entry in the list.
-	rowMap := make(map[string]*statusRow)
-	rowList := make([]*statusRow, 0, len(migrations))
+	rowMap := make(map[string]*MigrationStatus)
+	rowList := make([]*MigrationStatus, 0, len(migrations))
 
 	for _, m := range migrations {
-		rowMap[m.Id] = &statusRow{
+		rowMap[m.Id] = &MigrationStatus{
 			ID:       m.Id,
 			Migrated: false,
 		}
@@ -89,7 +167,7 @@ func (d *Db) MigrateStatus() error {
 
 	for _, r := range records {
 		if rowMap[r.Id] == nil {
-			rowMap[r.Id] = &statusRow{
+			rowMap[r.Id] = &MigrationStatus{
 				ID:                   r.Id,
 				MigrationFileMissing: true,
 			}
@@ -103,32 +181,87 @@ func (d *Db) MigrateStatus() error {
 		return rowList[i].ID < rowList[j].ID
 	})
 
-	for _, r := range rowList {
-		if r.Migrated {
-			if r.MigrationFileMissing {
-				log.Info().Msgf("%s: %s (migration file missing)", r.ID, r.AppliedAt.String())
-			} else {
-				log.Info().Msgf("%s: %s", r.ID, r.AppliedAt.String())
-			}
-		} else {
-			log.Info().Msgf("%s: not applied", r.ID)
+	return rowList
+}
+
+// migrationSet records the migrations in the migration table of the Db.  The table is not created when migrations are
+// disabled, since they are applied by another tool.
+func (d *Db) migrationSet() migrate.MigrationSet {
+	return migrate.MigrationSet{TableName: d.opts.migrationTable, SchemaName: d.opts.schema, DisableCreateTable: d.opts.skipMigrations}
+}
+
+// migrations returns the embedded migrations, with the names of the tables of the Db.
+func (d *Db) migrations() (*migrate.MemoryMigrationSource, error) {
+	files, err := d.migrationFiles()
+	if err != nil {
+		return nil, err
+	}
+	source := &migrate.MemoryMigrationSource{}
+	for _, f := range files {
+		m, err := migrate.ParseMigration(f.name, strings.NewReader(f.sql))
+		if err != nil {
+			return nil, errors.Wrap(ErrDbMigrationFailed, errors.WithCause(err))
 		}
+		source.Migrations = append(source.Migrations, m)
 	}
+	return source, nil
+}
 
-	return nil
+type migrationFile struct {
+	name string
+	sql  string
 }
 
-func getMigrations() *migrate.HttpFileSystemMigrationSource {
-	fsys, err := fs.Sub(migrationFiles, "migrations")
+// migrationFiles returns the embedded migration files in order, with the names of the tables of the Db.
+func (d *Db) migrationFiles() ([]migrationFile, error) {
+	entries, err := fs.ReadDir(migrationFiles, "migrations")
 	if err != nil {
-		panic(err)
+		return nil, errors.Wrap(ErrDbMigrationFailed, errors.WithCause(err))
 	}
-	return &migrate.HttpFileSystemMigrationSource{
-		FileSystem: http.FS(fsys),
+	files := make([]migrationFile, 0, len(entries))
+	for _, entry := range entries {
+		data, err := migrationFiles.ReadFile(path.Join("migrations", entry.Name()))
+		if err != nil {
+			return nil, errors.Wrap(ErrDbMigrationFailed, errors.WithCause(err))
+		}
+		files = append(files, migrationFile{name: entry.Name(), sql: d.sql(string(data))})
 	}
+	return files, nil
+}
+
+// MigrationSource returns the migrations for the tables named by the options, to apply with sql-migrate or to read
+// the statements of.
+func MigrationSource(opts ...Option) (*migrate.MemoryMigrationSource, error) {
+	d, err := newOfflineDb(opts)
+	if err != nil {
+		return nil, err
+	}
+	return d.migrations()
+}
+
+// ExportMigrations writes the migrations for the tables named by the options to dir as SQL files, in the format of
+// sql-migrate, with the statements to apply each migration after "-- +migrate Up" and to roll it back after
+// "-- +migrate Down".
+func ExportMigrations(dir string, opts ...Option) error {
+	d, err := newOfflineDb(opts)
+	if err != nil {
+		return err
+	}
+	files, err := d.migrationFiles()
+	if err != nil {
+		return err
+	}
+	for _, f := range files {
+		err = os.WriteFile(filepath.Join(dir, f.name), []byte(f.sql), 0o644)
+		if err != nil {
+			return errors.Wrap(err, errors.WithMessagef("failed to write migration %s", f.name))
+		}
+	}
+	return nil
 }
 
-type statusRow struct {
+// MigrationStatus is whether a migration has been applied to the database.
+type MigrationStatus struct {
 	ID                   string
 	Migrated             bool
 	MigrationFileMissing bool
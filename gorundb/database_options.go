@@ -0,0 +1,106 @@
+package gorundb
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+
+	"github.com/jswidler/gorun/errors"
+)
+
+var ErrInvalidOption = errors.Sentinel("invalid database option")
+
+// tables are the names of the tables without their prefix.
+var tables = []string{"trigger", "job_data", "calendar", "paused_job_type", "trigger_audit"}
+
+var identifierPattern = regexp.MustCompile(`^[A-Za-z_][A-Za-z0-9_]*$`)
+
+type Option func(*dbOptions)
+
+type dbOptions struct {
+	schema         string
+	tablePrefix    string
+	migrationTable string
+	skipMigrations bool
+}
+
+// WithSchema puts the tables in the Postgres schema, which is created if it does not exist.  By default the tables are
+// in the first schema of the search path.
+func WithSchema(schema string) Option {
+	return func(o *dbOptions) {
+		o.schema = schema
+	}
+}
+
+// WithTablePrefix names the tables with the prefix instead of "gorun_".
+func WithTablePrefix(prefix string) Option {
+	return func(o *dbOptions) {
+		o.tablePrefix = prefix
+	}
+}
+
+// WithMigrationTable records the migrations that have been applied in the table, in the schema of the other tables,
+// instead of in the gorp_migrations table that sql-migrate uses by default.
+func WithMigrationTable(table string) Option {
+	return func(o *dbOptions) {
+		o.migrationTable = table
+	}
+}
+
+// WithoutMigrations checks that the migrations have been applied instead of applying them, for databases that are
+// migrated by another tool.  See MigrationSource and ExportMigrations.
+func WithoutMigrations() Option {
+	return func(o *dbOptions) {
+		o.skipMigrations = true
+	}
+}
+
+func newDbOptions(opts []Option) (dbOptions, error) {
+	o := dbOptions{tablePrefix: "gorun_"}
+	for _, opt := range opts {
+		opt(&o)
+	}
+	if o.schema != "" && !identifierPattern.MatchString(o.schema) {
+		return o, errors.Wrap(ErrInvalidOption, errors.WithMessagef("invalid schema name %q", o.schema))
+	}
+	if o.tablePrefix != "" && !identifierPattern.MatchString(o.tablePrefix) {
+		return o, errors.Wrap(ErrInvalidOption, errors.WithMessagef("invalid table prefix %q", o.tablePrefix))
+	}
+	if o.migrationTable != "" && !identifierPattern.MatchString(o.migrationTable) {
+		return o, errors.Wrap(ErrInvalidOption, errors.WithMessagef("invalid migration table name %q", o.migrationTable))
+	}
+	return o, nil
+}
+
+// replacer returns the replacer for the tokens in queries and migrations.  {<table>} is the name of the table, quoted
+// and qualified by the schema, {schema} qualifies other names by the schema, and {prefix} is the table prefix.
+func (o dbOptions) replacer() *strings.Replacer {
+	schema := ""
+	if o.schema != "" {
+		schema = fmt.Sprintf(`"%s".`, o.schema)
+	}
+	oldnew := []string{"{schema}", schema, "{prefix}", o.tablePrefix}
+	for _, table := range tables {
+		oldnew = append(oldnew, "{"+table+"}", fmt.Sprintf(`%s"%s%s"`, schema, o.tablePrefix, table))
+	}
+	return strings.NewReplacer(oldnew...)
+}
+
+// newOfflineDb returns a Db without a connection, for the names of its tables.
+func newOfflineDb(opts []Option) (*Db, error) {
+	o, err := newDbOptions(opts)
+	if err != nil {
+		return nil, err
+	}
+	return &Db{opts: o, tables: o.replacer()}, nil
+}
+
+// table returns the quoted name of the table, qualified by the schema.
+func (d *Db) table(name string) string {
+	return d.sql("{" + name + "}")
+}
+
+// sql replaces the table tokens in the query.
+func (d *Db) sql(query string) string {
+	return d.tables.Replace(query)
+}
@@ -0,0 +1,119 @@
+package gorundb
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	migrate "github.com/rubenv/sql-migrate"
+	"github.com/stretchr/testify/assert"
+)
+
+func newTestDb(t *testing.T, opts ...Option) *Db {
+	d, err := newOfflineDb(opts)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return d
+}
+
+func TestTableNames(t *testing.T) {
+	d := newTestDb(t)
+	assert.Equal(t, `"gorun_job_data"`, d.table("job_data"))
+	assert.Equal(t, `SELECT * FROM "gorun_trigger"`, d.sql(`SELECT * FROM {trigger}`))
+
+	d = newTestDb(t, WithSchema("jobs"), WithTablePrefix("app_"))
+	assert.Equal(t, `"jobs"."app_job_data"`, d.table("job_data"))
+
+	_, err := newDbOptions([]Option{WithSchema(`jobs"; DROP TABLE x; --`)})
+	assert.ErrorIs(t, err, ErrInvalidOption)
+}
+
+func TestMigrationsUseTableNames(t *testing.T) {
+	source, err := MigrationSource(WithSchema("jobs"), WithTablePrefix("app_"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	migrations, err := source.FindMigrations()
+	assert.NoError(t, err)
+	assert.Equal(t, "001_job_tables.sql", migrations[0].Id)
+
+	var statements []string
+	for _, m := range migrations {
+		statements = append(statements, m.Up...)
+		statements = append(statements, m.Down...)
+	}
+	all := strings.Join(statements, "\n")
+	assert.Contains(t, all, `CREATE TABLE IF NOT EXISTS "jobs"."app_trigger" (`)
+	assert.Contains(t, all, `CREATE INDEX IF NOT EXISTS "app_trigger_event" ON "jobs"."app_trigger" ("event")`)
+	assert.Contains(t, all, `DROP INDEX IF EXISTS "jobs"."app_trigger_event"`)
+	assert.NotContains(t, all, "gorun_")
+	assert.NotContains(t, all, "{prefix}")
+}
+
+func TestExportMigrations(t *testing.T) {
+	dir := t.TempDir()
+	assert.NoError(t, ExportMigrations(dir, WithTablePrefix("app_")))
+	data, err := os.ReadFile(filepath.Join(dir, "001_job_tables.sql"))
+	assert.NoError(t, err)
+	assert.Contains(t, string(data), "-- +migrate Up\nCREATE TABLE IF NOT EXISTS \"app_trigger\" (")
+}
+
+// The migrations released before the tokens were introduced must render to the SQL they were released with, since a
+// database that applied them has no record of their contents.
+func TestReleasedMigrationsAreUnchanged(t *testing.T) {
+	released, err := os.ReadDir("testdata/released_migrations")
+	assert.NoError(t, err)
+	assert.NotEmpty(t, released)
+
+	files, err := newTestDb(t).migrationFiles()
+	assert.NoError(t, err)
+	rendered := make(map[string]string)
+	for _, f := range files {
+		rendered[f.name] = f.sql
+	}
+	for _, entry := range released {
+		data, err := os.ReadFile(filepath.Join("testdata/released_migrations", entry.Name()))
+		assert.NoError(t, err)
+		assert.Equal(t, string(data), rendered[entry.Name()], entry.Name())
+	}
+}
+
+// A database migrated with the released files, before they used tokens, has the same migrations applied as one
+// migrated now, whatever the options.
+func TestReleasedMigrationsAreNotPending(t *testing.T) {
+	released, err := os.ReadDir("testdata/released_migrations")
+	assert.NoError(t, err)
+	var records []*migrate.MigrationRecord
+	for _, entry := range released {
+		records = append(records, &migrate.MigrationRecord{Id: entry.Name(), AppliedAt: time.Now()})
+	}
+
+	for _, opts := range [][]Option{nil, {WithSchema("jobs"), WithTablePrefix("app_")}} {
+		source, err := MigrationSource(opts...)
+		assert.NoError(t, err)
+		migrations, err := source.FindMigrations()
+		assert.NoError(t, err)
+
+		// Only the migrations added since are pending
+		var added []*migrate.MigrationRecord
+		rows := combineMigrationStatus(migrations, records)
+		for _, r := range rows {
+			assert.False(t, r.MigrationFileMissing, r.ID)
+			if !r.Migrated {
+				assert.Greater(t, r.ID, released[len(released)-1].Name())
+				added = append(added, &migrate.MigrationRecord{Id: r.ID, AppliedAt: time.Now()})
+			}
+		}
+		assert.Len(t, rows, len(migrations))
+
+		// and once they are applied, nothing is
+		rows = combineMigrationStatus(migrations, append(records, added...))
+		assert.NoError(t, checkMigrationStatus(rows))
+
+		rows = combineMigrationStatus(migrations, records[1:])
+		assert.ErrorIs(t, checkMigrationStatus(rows), ErrDbMigrationsPending)
+	}
+}
@@ -2,7 +2,6 @@ package gorundb
 
 import (
 	"github.com/jswidler/gorun/errors"
-	"github.com/lib/pq"
 )
 
 var (
@@ -16,18 +15,27 @@ var (
 )
 
 const (
-	uniqueViolationErr   = pq.ErrorCode("23505")
-	invalidForeignKeyErr = pq.ErrorCode("23503")
+	uniqueViolationErr   = "23505"
+	invalidForeignKeyErr = "23503"
 )
 
+// sqlStateError is implemented by the errors of both lib/pq and pgx, so neither driver is needed to classify them.
+type sqlStateError interface {
+	SQLState() string
+}
+
+func sqlState(err error) string {
+	var stateErr sqlStateError
+	if errors.As(err, &stateErr) {
+		return stateErr.SQLState()
+	}
+	return ""
+}
+
 func isConflict(err error) bool {
-	var pqErr *pq.Error
-	ok := errors.As(err, &pqErr)
-	return ok && pqErr.Code == uniqueViolationErr
+	return sqlState(err) == uniqueViolationErr
 }
 
 func isInvalidForeignKey(err error) bool {
-	var pqErr *pq.Error
-	ok := errors.As(err, &pqErr)
-	return ok && pqErr.Code == invalidForeignKeyErr
+	return sqlState(err) == invalidForeignKeyErr
 }
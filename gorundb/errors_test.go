@@ -0,0 +1,26 @@
+package gorundb
+
+import (
+	"testing"
+
+	"github.com/jackc/pgx/v5/pgconn"
+	"github.com/jswidler/gorun/errors"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestIsConflict(t *testing.T) {
+	err := errors.Wrap(&pgconn.PgError{Code: "23505"})
+	assert.True(t, isConflict(err))
+	assert.False(t, isInvalidForeignKey(err))
+	assert.True(t, isInvalidForeignKey(&pgconn.PgError{Code: "23503"}))
+	assert.False(t, isConflict(errors.New("not a database error")))
+}
+
+func TestTextArray(t *testing.T) {
+	v, err := textArray{"a", `b"c`, `d\e`}.Value()
+	assert.NoError(t, err)
+	assert.Equal(t, `{"a","b\"c","d\\e"}`, v)
+	v, err = textArray{}.Value()
+	assert.NoError(t, err)
+	assert.Equal(t, `{}`, v)
+}
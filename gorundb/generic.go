@@ -10,7 +10,6 @@ import (
 	"github.com/jswidler/gorun/errors"
 	"github.com/jswidler/gorun/gorundb/internal/columns"
 	"github.com/jswidler/gorun/tenantctx"
-	"github.com/lib/pq"
 )
 
 // This could be better abstracted for sharing.
@@ -19,6 +18,8 @@ import (
 // TODO: write this in a way it can be a bit more flexible depending on the columns in the table, maybe allow customizable
 // column names and types in some cases.
 
+// Table names are passed quoted, and qualified by the schema if there is one, as returned by Db.table.
+
 // Some tables fields are special:
 // `id` - primary varchar-32 key, required
 // `tenant_id` - tenant id as string.  if it is added to the context, it will be used in most generated queries
@@ -55,24 +56,24 @@ func byIds[T any](ctx context.Context, db SelectContexter, table string, ids []s
 	tenantId := tenantctx.GetTenant(ctx)
 	if tenantId == "" {
 		return queryMany[T](ctx, db,
-			fmt.Sprintf(`SELECT * FROM "%s" WHERE "id" = ANY($1)`, table),
-			pq.StringArray(ids),
+			fmt.Sprintf(`SELECT * FROM %s WHERE "id" = ANY($1)`, table),
+			textArray(ids),
 		)
 	}
 
 	return queryMany[T](ctx, db,
-		fmt.Sprintf(`SELECT * FROM "%s" WHERE "tenant_id" = $1 AND "id" = ANY($2)`, table),
-		tenantId, pq.StringArray(ids),
+		fmt.Sprintf(`SELECT * FROM %s WHERE "tenant_id" = $1 AND "id" = ANY($2)`, table),
+		tenantId, textArray(ids),
 	)
 }
 
 func byFieldWithTenant[T any](ctx context.Context, db GetContexter, table, field, value string) (*T, error) {
-	query := fmt.Sprintf(`SELECT * FROM "%s" WHERE "tenant_id" = $1 AND "%s" = $2`, table, field)
+	query := fmt.Sprintf(`SELECT * FROM %s WHERE "tenant_id" = $1 AND "%s" = $2`, table, field)
 	return queryOne[T](ctx, db, query, tenantctx.MustGetTenant(ctx), value)
 }
 
 func byFieldWithoutTenant[T any](ctx context.Context, db GetContexter, table, field, value string) (*T, error) {
-	query := fmt.Sprintf(`SELECT * FROM "%s" WHERE "%s" = $1`, table, field)
+	query := fmt.Sprintf(`SELECT * FROM %s WHERE "%s" = $1`, table, field)
 	return queryOne[T](ctx, db, query, value)
 }
 
@@ -93,7 +94,7 @@ func updateById[T any](ctx context.Context, db GetContexter, table string, row *
 	}
 	cols.Remove("id")
 
-	query := fmt.Sprintf(`UPDATE "%s" SET (%s)=(%s) WHERE id=$1 RETURNING *`, table, cols.Columns(), cols.ColumnsPlaceholder(2))
+	query := fmt.Sprintf(`UPDATE %s SET (%s)=(%s) WHERE id=$1 RETURNING *`, table, cols.Columns(), cols.ColumnsPlaceholder(2))
 	params := []any{id}
 	params = append(params, cols.Values()...)
 
@@ -115,9 +116,9 @@ func updateById[T any](ctx context.Context, db GetContexter, table string, row *
 func deleteById(ctx context.Context, db ExecContexter, table string, id string) error {
 	tenantId := tenantctx.GetTenant(ctx)
 	if tenantId == "" {
-		return delete(ctx, db, fmt.Sprintf(`DELETE FROM "%s" WHERE id = $1`, table), id)
+		return delete(ctx, db, fmt.Sprintf(`DELETE FROM %s WHERE id = $1`, table), id)
 	}
-	return delete(ctx, db, fmt.Sprintf(`DELETE FROM "%s" WHERE tenant_id = $1 AND id = $2`, table), tenantId, id)
+	return delete(ctx, db, fmt.Sprintf(`DELETE FROM %s WHERE tenant_id = $1 AND id = $2`, table), tenantId, id)
 }
 
 func deleteByIds(ctx context.Context, db ExecContexter, table string, ids []string) error {
@@ -126,9 +127,9 @@ func deleteByIds(ctx context.Context, db ExecContexter, table string, ids []stri
 	}
 	tenantId := tenantctx.GetTenant(ctx)
 	if tenantId == "" {
-		return delete(ctx, db, fmt.Sprintf(`DELETE FROM "%s" WHERE "id" = ANY($1)`, table), pq.StringArray(ids))
+		return delete(ctx, db, fmt.Sprintf(`DELETE FROM %s WHERE "id" = ANY($1)`, table), textArray(ids))
 	}
-	return delete(ctx, db, fmt.Sprintf(`DELETE FROM "%s" WHERE "tenant_id" = $1 AND "id" = ANY($2)`, table), tenantId, pq.StringArray(ids))
+	return delete(ctx, db, fmt.Sprintf(`DELETE FROM %s WHERE "tenant_id" = $1 AND "id" = ANY($2)`, table), tenantId, textArray(ids))
 }
 
 func queryOne[T any](ctx context.Context, db GetContexter, query string, args ...interface{}) (*T, error) {
@@ -162,7 +163,7 @@ func insert[T any](ctx context.Context, db GetContexter, table string, row *T) e
 		l.Set("updated_at", now)
 	}
 
-	query := fmt.Sprintf(`INSERT INTO "%s" (%s) VALUES (%s) RETURNING *`, table, l.Columns(), l.ColumnsPlaceholder(1))
+	query := fmt.Sprintf(`INSERT INTO %s (%s) VALUES (%s) RETURNING *`, table, l.Columns(), l.ColumnsPlaceholder(1))
 
 	var r T
 	err := db.GetContext(ctx, &r, query, l.Values()...)
@@ -179,6 +180,9 @@ func insert[T any](ctx context.Context, db GetContexter, table string, row *T) e
 	return nil
 }
 
+// maxBulkParams is the most parameters a Postgres statement can have.
+const maxBulkParams = 65535
+
 func insertBulk[T any](ctx context.Context, db NamedExecContexter, table string, rows []*T) error {
 	if len(rows) == 0 {
 		return nil
@@ -202,16 +206,20 @@ func insertBulk[T any](ctx context.Context, db NamedExecContexter, table string,
 	placeholders := l.ColumnsNamedPlaceholder()
 	columnNames := l.Columns()
 
-	query := fmt.Sprintf(`INSERT INTO "%s" (%s) VALUES (%s)`, table, columnNames, placeholders)
-
-	_, err := db.NamedExecContext(ctx, query, rowsInsert)
-	if err != nil {
-		if isConflict(err) {
-			return errors.Wrap(ErrConflict, errors.WithCause(err))
-		} else if isInvalidForeignKey(err) {
-			return errors.Wrap(ErrInvalidForeignKey, errors.WithCause(err))
+	query := fmt.Sprintf(`INSERT INTO %s (%s) VALUES (%s)`, table, columnNames, placeholders)
+
+	// Each statement can only have so many parameters, so large inserts are split.
+	chunkSize := maxBulkParams / len(l.Names())
+	for start := 0; start < len(rowsInsert); start += chunkSize {
+		_, err := db.NamedExecContext(ctx, query, rowsInsert[start:min(start+chunkSize, len(rowsInsert))])
+		if err != nil {
+			if isConflict(err) {
+				return errors.Wrap(ErrConflict, errors.WithCause(err))
+			} else if isInvalidForeignKey(err) {
+				return errors.Wrap(ErrInvalidForeignKey, errors.WithCause(err))
+			}
+			return errors.Wrap(ErrDatabaseError, errors.WithCause(err))
 		}
-		return errors.Wrap(ErrDatabaseError, errors.WithCause(err))
 	}
 
 	return nil
@@ -266,7 +274,7 @@ func upsertBulkAtTime[T any](ctx context.Context, db NamedExecContexter, now tim
 	}
 	onUpdateClause := strings.Join(onUpdate, ", ")
 
-	query := fmt.Sprintf(`INSERT INTO "%s" (%s) VALUES (%s) ON CONFLICT %s DO UPDATE SET %s`,
+	query := fmt.Sprintf(`INSERT INTO %s (%s) VALUES (%s) ON CONFLICT %s DO UPDATE SET %s`,
 		table, columnNames, placeholders,
 		conflict, onUpdateClause,
 	)
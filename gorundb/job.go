@@ -2,12 +2,17 @@ package gorundb
 
 import (
 	"context"
+	"database/sql"
+	"encoding/json"
+	"reflect"
+	"slices"
 	"time"
 
 	"github.com/jmoiron/sqlx"
 	"github.com/jswidler/gorun/errors"
 	"github.com/jswidler/gorun/logger"
 	"github.com/jswidler/gorun/tenantctx"
+	"github.com/jswidler/gorun/ulid"
 )
 
 type JobView struct {
@@ -25,7 +30,38 @@ type JobTrigger struct {
 
 	JobType string `db:"job_type" json:"jobType"`
 	JobArgs string `db:"job_args" json:"jobArgs"`
+
+	Calendars StringList `db:"calendars" json:"calendars"`
+
+	StartAt            *time.Time `db:"start_at" json:"startAt"`
+	EndAt              *time.Time `db:"end_at" json:"endAt"`
+	MaxRuns            *int       `db:"max_runs" json:"maxRuns"`
+	RunCount           int        `db:"run_count" json:"runCount"`
+	FinishedAt         *time.Time `db:"finished_at" json:"finishedAt"`
+	DeleteWhenFinished bool       `db:"delete_when_finished" json:"deleteWhenFinished"`
+
+	PausedAt *time.Time `db:"paused_at" json:"pausedAt"`
+
+	Jitter     time.Duration `db:"jitter" json:"jitter"`
+	JitterMode string        `db:"jitter_mode" json:"jitterMode"`
+
+	// Event is the name of the event that fires the trigger, or nil for triggers that fire on a schedule.
+	Event *string `db:"event" json:"event"`
+
+	Version int `db:"version" json:"version"`
 }
+
+// TriggerAudit records the definition of a trigger before it was changed.
+type TriggerAudit struct {
+	Id        string    `db:"id" json:"id"`
+	TenantId  *string   `db:"tenant_id" json:"tenantId"`
+	CreatedAt time.Time `db:"created_at" json:"createdAt"`
+
+	TriggerId string `db:"trigger_id" json:"triggerId"`
+	Version   int    `db:"version" json:"version"`
+	Previous  string `db:"previous" json:"previous"`
+}
+
 type JobData struct {
 	Id        string    `db:"id" json:"id"`
 	TenantId  *string   `db:"tenant_id" json:"tenantId"`
@@ -40,13 +76,19 @@ type JobData struct {
 	Type   string  `db:"type" json:"type"`
 	Args   string  `db:"args" json:"args"`
 	Result *string `db:"result" json:"result"`
+
+	// DedupKey groups jobs that are debounced or throttled together.
+	DedupKey *string `db:"dedup_key" json:"dedupKey"`
+	// UpstreamJobId is the job that a chained job was scheduled after.
+	UpstreamJobId *string `db:"upstream_job_id" json:"upstreamJobId"`
 }
 
 func (view JobView) AcquireJobsToRun(ctx context.Context, jobLimit int) ([]*JobData, error) {
-	jobs, err := queryMany[JobData](ctx, view.db.db, `WITH to_run AS (
-			SELECT * from "gorun_job_data" WHERE "status" = 'scheduled' AND "run_at" < NOW() LIMIT $1 FOR UPDATE
+	jobs, err := queryMany[JobData](ctx, view.db.db, view.db.sql(`WITH to_run AS (
+			SELECT * from {job_data} WHERE "status" = 'scheduled' AND "run_at" < NOW()
+				AND "type" NOT IN (SELECT "job_type" FROM {paused_job_type}) LIMIT $1 FOR UPDATE
 		)
-		UPDATE "gorun_job_data" j SET "status" = 'running', "updated_at" = NOW(), FROM to_run WHERE j.id = to_run.id AND "status" = 'scheduled' RETURNING j.*`, jobLimit)
+		UPDATE {job_data} j SET "status" = 'running', "updated_at" = NOW() FROM to_run WHERE j.id = to_run.id AND j."status" = 'scheduled' RETURNING j.*`), jobLimit)
 
 	if len(jobs) == jobLimit {
 		// Warn if we hit the limit
@@ -58,34 +100,39 @@ func (view JobView) AcquireJobsToRun(ctx context.Context, jobLimit int) ([]*JobD
 
 func (view JobView) MarkIncompleteJobs(ctx context.Context, jobTimeout time.Duration) ([]*JobData, error) {
 	tenMinAgo := time.Now().Add(-1 * jobTimeout)
-	return queryMany[JobData](ctx, view.db.db, `WITH stuck AS (
-			SELECT * from "gorun_job_data" WHERE "status" = 'running' AND "updated_at" < $1 FOR UPDATE
+	return queryMany[JobData](ctx, view.db.db, view.db.sql(`WITH stuck AS (
+			SELECT * from {job_data} WHERE "status" = 'running' AND "updated_at" < $1 FOR UPDATE
 		)
-		UPDATE "gorun_job_data" j SET "status" = 'failed', "updated_at" = NOW(), result = $2 FROM stuck WHERE j.id = stuck.id  AND "status" = 'running' RETURNING j.*`,
+		UPDATE {job_data} j SET "status" = 'failed', "updated_at" = NOW(), result = $2 FROM stuck WHERE j.id = stuck.id  AND "status" = 'running' RETURNING j.*`),
 		tenMinAgo, "job timed out")
 }
 
 func (view JobView) UpdateJob(ctx context.Context, job *JobData) error {
-	return updateById(ctx, view.db.db, "gorun_job_data", job)
+	return updateById(ctx, view.db.db, view.db.table("job_data"), job)
 }
 
 func (view JobView) GetJobById(ctx context.Context, jobId string) (*JobData, error) {
-	return byId[JobData](ctx, view.db.db, "gorun_job_data", jobId)
+	return byId[JobData](ctx, view.db.db, view.db.table("job_data"), jobId)
 }
 
 func (view JobView) ListJobs(ctx context.Context, startTime, endTime time.Time) ([]*JobData, error) {
 	tenantId := tenantctx.GetTenant(ctx)
 	if tenantId == "" {
-		return queryMany[JobData](ctx, view.db.db, `SELECT * FROM "gorun_job_data" WHERE "run_at" >= $1 AND "run_at" < $2`, startTime, endTime)
+		return queryMany[JobData](ctx, view.db.db, view.db.sql(`SELECT * FROM {job_data} WHERE "run_at" >= $1 AND "run_at" < $2`), startTime, endTime)
 	}
-	return queryMany[JobData](ctx, view.db.db, `SELECT * FROM "gorun_job_data" WHERE "tenant_id" = $1 AND "run_at" >= $2 AND "run_at" < $3`, tenantId, startTime, endTime)
+	return queryMany[JobData](ctx, view.db.db, view.db.sql(`SELECT * FROM {job_data} WHERE "tenant_id" = $1 AND "run_at" >= $2 AND "run_at" < $3`), tenantId, startTime, endTime)
+}
+
+// ListScheduledJobsForTrigger returns the jobs of a trigger that have not started running, in the order they will run.
+func (view JobView) ListScheduledJobsForTrigger(ctx context.Context, triggerId string) ([]*JobData, error) {
+	return queryMany[JobData](ctx, view.db.db, view.db.sql(`SELECT * FROM {job_data} WHERE "trigger_id" = $1 AND "status" = 'scheduled' ORDER BY "run_at"`), triggerId)
 }
 
 func (view JobView) GetTriggerById(ctx context.Context, triggerId string) (*JobTrigger, error) {
 	var trigger *JobTrigger
 	err := view.db.useTx(ctx, func(ctx context.Context, tx *sqlx.Tx) error {
 		var err error
-		trigger, err = byId[JobTrigger](ctx, tx, "gorun_trigger", triggerId)
+		trigger, err = byId[JobTrigger](ctx, tx, view.db.table("trigger"), triggerId)
 		return err
 	})
 	return trigger, err
@@ -94,22 +141,79 @@ func (view JobView) GetTriggerById(ctx context.Context, triggerId string) (*JobT
 func (view JobView) ListTriggers(ctx context.Context) ([]*JobTrigger, error) {
 	tenantId := tenantctx.GetTenant(ctx)
 	if tenantId != "" {
-		return queryMany[JobTrigger](ctx, view.db.db, `SELECT * FROM "gorun_trigger" WHERE "tenant_id" = $1`, tenantId)
+		return queryMany[JobTrigger](ctx, view.db.db, view.db.sql(`SELECT * FROM {trigger} WHERE "tenant_id" = $1`), tenantId)
 	}
-	return queryMany[JobTrigger](ctx, view.db.db, `SELECT * FROM "gorun_trigger"`)
+	return queryMany[JobTrigger](ctx, view.db.db, view.db.sql(`SELECT * FROM {trigger}`))
 }
 
 func (view JobView) DeleteTriggerById(ctx context.Context, triggerId string) error {
 	return view.db.useTx(ctx, func(ctx context.Context, tx *sqlx.Tx) error {
 		// Attempt to stop any jobs from running that are scheduled by the trigger, but have run yet.
-		err := delete(ctx, tx, `DELETE FROM gorun_job_data WHERE trigger_id = $1 AND status = 'scheduled'`, triggerId)
+		err := delete(ctx, tx, view.db.sql(`DELETE FROM {job_data} WHERE trigger_id = $1 AND status = 'scheduled'`), triggerId)
 		if err != nil {
 			return err
 		}
-		return delete(ctx, tx, `DELETE FROM gorun_trigger WHERE id = $1`, triggerId)
+		return delete(ctx, tx, view.db.sql(`DELETE FROM {trigger} WHERE id = $1`), triggerId)
+	})
+}
+
+// PauseTrigger stops a trigger from scheduling jobs and removes the jobs it has scheduled that have not started.  The
+// removed jobs no longer count towards the run count of the trigger.
+func (view JobView) PauseTrigger(ctx context.Context, triggerId string) error {
+	return view.db.useTx(ctx, func(ctx context.Context, tx *sqlx.Tx) error {
+		r, err := tx.ExecContext(ctx, view.db.sql(`DELETE FROM {job_data} WHERE trigger_id = $1 AND status = 'scheduled'`), triggerId)
+		if err != nil {
+			return errors.Wrap(ErrDatabaseError, errors.WithCause(err))
+		}
+		removed, err := r.RowsAffected()
+		if err != nil {
+			return errors.Wrap(ErrDatabaseError, errors.WithCause(err))
+		}
+		_, err = tx.ExecContext(ctx, view.db.sql(`UPDATE {trigger} SET "paused_at" = COALESCE("paused_at", NOW()), "run_count" = GREATEST("run_count" - $2, 0), "updated_at" = NOW() WHERE "id" = $1`),
+			triggerId, removed)
+		if err != nil {
+			return errors.Wrap(ErrDatabaseError, errors.WithCause(err))
+		}
+		return nil
+	})
+}
+
+// ResumeTrigger lets a paused trigger schedule jobs again, starting from scheduledUntil.
+func (view JobView) ResumeTrigger(ctx context.Context, triggerId string, scheduledUntil time.Time) error {
+	return view.db.useTx(ctx, func(ctx context.Context, tx *sqlx.Tx) error {
+		_, err := tx.ExecContext(ctx, view.db.sql(`UPDATE {trigger} SET "paused_at" = NULL, "scheduled_until" = $2, "updated_at" = NOW() WHERE "id" = $1 AND "paused_at" IS NOT NULL`),
+			triggerId, scheduledUntil)
+		if err != nil {
+			return errors.Wrap(ErrDatabaseError, errors.WithCause(err))
+		}
+		return nil
 	})
 }
 
+// PauseJobType stops jobs of the type from being acquired to run until the type is resumed.
+func (view JobView) PauseJobType(ctx context.Context, jobType string) error {
+	logger.Ctx(ctx).Info().Str("jobType", jobType).Msg("pausing job type")
+	_, err := view.db.db.ExecContext(ctx, view.db.sql(`INSERT INTO {paused_job_type} ("job_type", "paused_at") VALUES ($1, NOW()) ON CONFLICT DO NOTHING`), jobType)
+	if err != nil {
+		return errors.Wrap(ErrDatabaseError, errors.WithCause(err))
+	}
+	return nil
+}
+
+func (view JobView) ResumeJobType(ctx context.Context, jobType string) error {
+	logger.Ctx(ctx).Info().Str("jobType", jobType).Msg("resuming job type")
+	return delete(ctx, view.db.db, view.db.sql(`DELETE FROM {paused_job_type} WHERE "job_type" = $1`), jobType)
+}
+
+func (view JobView) ListPausedJobTypes(ctx context.Context) ([]string, error) {
+	var jobTypes []string
+	err := view.db.db.SelectContext(ctx, &jobTypes, view.db.sql(`SELECT "job_type" FROM {paused_job_type} ORDER BY "job_type"`))
+	if err != nil {
+		return nil, errors.Wrap(ErrDatabaseError, errors.WithCause(err))
+	}
+	return jobTypes, nil
+}
+
 func (view JobView) InsertTriggerWithJobs(ctx context.Context, jobTrigger *JobTrigger, jobs []*JobData) error {
 	return view.db.useTx(ctx, func(ctx context.Context, tx *sqlx.Tx) error {
 		err := view.InsertTrigger(ctx, jobTrigger)
@@ -130,20 +234,82 @@ func (view JobView) MaybeUpsertTriggerWithJobs(ctx context.Context, jobTrigger *
 			return view.InsertTriggerWithJobs(ctx, jobTrigger, jobs)
 		}
 
-		if trig.TriggerType == jobTrigger.TriggerType && trig.TriggerData == jobTrigger.TriggerData && trig.JobType == jobTrigger.JobType && trig.JobArgs == jobTrigger.JobArgs {
+		if trig.SameDefinition(jobTrigger) {
 			return nil
 		}
-		err = delete(ctx, tx, `DELETE FROM gorun_job_data WHERE trigger_id = $1 AND status = 'scheduled'`, jobTrigger.Id)
+		// Changing a paused trigger does not resume it.
+		jobTrigger.PausedAt = trig.PausedAt
+		jobTrigger.Version = trig.Version + 1
+		if trig.SameSchedule(jobTrigger) {
+			// The schedule is the same, so keep its phase.  The jobs already scheduled keep their run times, with the new
+			// arguments.
+			_, err = tx.ExecContext(ctx, view.db.sql(`UPDATE {job_data} SET "args" = $2, "updated_at" = $3 WHERE "trigger_id" = $1 AND "status" = 'scheduled'`),
+				jobTrigger.Id, jobTrigger.JobArgs, time.Now().UTC())
+			if err != nil {
+				return errors.Wrap(ErrDatabaseError, errors.WithCause(err))
+			}
+			jobTrigger.ScheduledUntil = trig.ScheduledUntil
+			jobTrigger.RunCount = trig.RunCount
+			jobTrigger.FinishedAt = trig.FinishedAt
+			jobs = nil
+		} else {
+			r, err := tx.ExecContext(ctx, view.db.sql(`DELETE FROM {job_data} WHERE trigger_id = $1 AND status = 'scheduled'`), jobTrigger.Id)
+			if err != nil {
+				return errors.Wrap(ErrDatabaseError, errors.WithCause(err))
+			}
+			replaced, err := r.RowsAffected()
+			if err != nil {
+				return errors.Wrap(ErrDatabaseError, errors.WithCause(err))
+			}
+			if trig.PausedAt != nil {
+				// The first job is scheduled when the trigger is resumed.
+				jobs = nil
+			}
+			jobTrigger.RunCount = max(trig.RunCount-int(replaced), 0) + len(jobs)
+		}
+		err = view.insertTriggerAudit(ctx, trig, jobTrigger.Version)
 		if err != nil {
 			return err
 		}
-		return updateById(ctx, tx, "gorun_trigger", jobTrigger)
+		err = updateById(ctx, tx, view.db.table("trigger"), jobTrigger)
+		if err != nil {
+			return err
+		}
+		return view.InsertJobs(ctx, jobs)
 	})
 }
 
-func (view JobView) ScheduleNewJobsFromTrigger(ctx context.Context, jobTrigger *JobTrigger, prevScheduleUntil time.Time, jobs []*JobData) error {
+// UpdateTrigger replaces the definition of a trigger along with its jobs that have not started, provided the trigger
+// is still at version prev.Version and has not been scheduled further by another process.  The definition from prev is
+// kept as an audit record, and the run count of the trigger no longer includes the jobs that were replaced.
+func (view JobView) UpdateTrigger(ctx context.Context, jobTrigger *JobTrigger, prev *JobTrigger, jobs []*JobData) error {
 	return view.db.useTx(ctx, func(ctx context.Context, tx *sqlx.Tx) error {
-		err := view.UpdateScheduledUntil(ctx, jobTrigger.Id, jobTrigger.ScheduledUntil, prevScheduleUntil)
+		r, err := tx.ExecContext(ctx, view.db.sql(`UPDATE {trigger} SET "version" = "version" + 1 WHERE "id" = $1 AND "version" = $2 AND "scheduled_until" = $3`),
+			prev.Id, prev.Version, prev.ScheduledUntil)
+		if err != nil {
+			return errors.Wrap(ErrDatabaseError, errors.WithCause(err))
+		} else if n, err := r.RowsAffected(); err != nil {
+			return errors.Wrap(ErrDatabaseError, errors.WithCause(err))
+		} else if n != 1 {
+			return errors.Wrap(ErrConflict, errors.WithMessagef("trigger %s was changed by another process", prev.Id))
+		}
+
+		r, err = tx.ExecContext(ctx, view.db.sql(`DELETE FROM {job_data} WHERE trigger_id = $1 AND status = 'scheduled'`), prev.Id)
+		if err != nil {
+			return errors.Wrap(ErrDatabaseError, errors.WithCause(err))
+		}
+		replaced, err := r.RowsAffected()
+		if err != nil {
+			return errors.Wrap(ErrDatabaseError, errors.WithCause(err))
+		}
+
+		jobTrigger.Version = prev.Version + 1
+		jobTrigger.RunCount = max(prev.RunCount-int(replaced), 0) + len(jobs)
+		err = view.insertTriggerAudit(ctx, prev, jobTrigger.Version)
+		if err != nil {
+			return err
+		}
+		err = updateById(ctx, tx, view.db.table("trigger"), jobTrigger)
 		if err != nil {
 			return err
 		}
@@ -151,13 +317,57 @@ func (view JobView) ScheduleNewJobsFromTrigger(ctx context.Context, jobTrigger *
 	})
 }
 
+func (view JobView) insertTriggerAudit(ctx context.Context, prev *JobTrigger, version int) error {
+	previous, err := json.Marshal(prev)
+	if err != nil {
+		return errors.Wrap(err)
+	}
+	return view.db.useTx(ctx, func(ctx context.Context, tx *sqlx.Tx) error {
+		return insert(ctx, tx, view.db.table("trigger_audit"), &TriggerAudit{
+			Id:        ulid.New(),
+			TenantId:  prev.TenantId,
+			TriggerId: prev.Id,
+			Version:   version,
+			Previous:  string(previous),
+		})
+	})
+}
+
+// ListTriggerAudits returns the previous definitions of a trigger, oldest first.
+func (view JobView) ListTriggerAudits(ctx context.Context, triggerId string) ([]*TriggerAudit, error) {
+	tenantId := tenantctx.GetTenant(ctx)
+	if tenantId != "" {
+		return queryMany[TriggerAudit](ctx, view.db.db, view.db.sql(`SELECT * FROM {trigger_audit} WHERE "tenant_id" = $1 AND "trigger_id" = $2 ORDER BY "version"`), tenantId, triggerId)
+	}
+	return queryMany[TriggerAudit](ctx, view.db.db, view.db.sql(`SELECT * FROM {trigger_audit} WHERE "trigger_id" = $1 ORDER BY "version"`), triggerId)
+}
+
+// ScheduleNewJobsFromTrigger saves the progress of the trigger along with its new jobs.  A trigger that has finished
+// and is set to be deleted when finished is deleted, but its jobs are left to run.
+func (view JobView) ScheduleNewJobsFromTrigger(ctx context.Context, jobTrigger *JobTrigger, prevScheduleUntil time.Time, jobs []*JobData) error {
+	return view.db.useTx(ctx, func(ctx context.Context, tx *sqlx.Tx) error {
+		err := view.UpdateTriggerProgress(ctx, jobTrigger, prevScheduleUntil)
+		if err != nil {
+			return err
+		}
+		err = view.InsertJobs(ctx, jobs)
+		if err != nil {
+			return err
+		}
+		if jobTrigger.FinishedAt != nil && jobTrigger.DeleteWhenFinished {
+			return delete(ctx, tx, view.db.sql(`DELETE FROM {trigger} WHERE id = $1`), jobTrigger.Id)
+		}
+		return nil
+	})
+}
+
 func (view JobView) InsertTrigger(ctx context.Context, jobTrigger *JobTrigger) error {
 	logger.Ctx(ctx).Info().Str("triggerId", jobTrigger.Id).
 		Str("jobType", jobTrigger.JobType).
 		Str("triggerType", jobTrigger.TriggerType).
 		Msg("inserting job trigger")
 	return view.db.useTx(ctx, func(ctx context.Context, tx *sqlx.Tx) error {
-		return insert(ctx, tx, "gorun_trigger", jobTrigger)
+		return insert(ctx, tx, view.db.table("trigger"), jobTrigger)
 	})
 }
 
@@ -167,14 +377,14 @@ func (view JobView) UpsertTrigger(ctx context.Context, jobTrigger *JobTrigger) e
 		Str("triggerType", jobTrigger.TriggerType).
 		Msg("upserting job trigger")
 	return view.db.useTx(ctx, func(ctx context.Context, tx *sqlx.Tx) error {
-		return upsert(ctx, tx, "gorun_trigger", jobTrigger, "(id)")
+		return upsert(ctx, tx, view.db.table("trigger"), jobTrigger, "(id)")
 	})
 }
 
 func (view JobView) InsertJobs(ctx context.Context, jobs []*JobData) error {
 	logger.Ctx(ctx).Info().Int("jobCount", len(jobs)).Msg("inserting jobs")
 	return view.db.useTx(ctx, func(ctx context.Context, tx *sqlx.Tx) error {
-		return insertBulk(ctx, tx, "gorun_job_data", jobs)
+		return insertBulk(ctx, tx, view.db.table("job_data"), jobs)
 	})
 }
 
@@ -182,7 +392,7 @@ func (view JobView) TriggerById(ctx context.Context, triggerId string) (*JobTrig
 	var trigger *JobTrigger
 	err := view.db.useTx(ctx, func(ctx context.Context, tx *sqlx.Tx) error {
 		var err error
-		trigger, err = byId[JobTrigger](ctx, tx, "gorun_trigger", triggerId)
+		trigger, err = byId[JobTrigger](ctx, tx, view.db.table("trigger"), triggerId)
 		return err
 	})
 	return trigger, err
@@ -192,24 +402,179 @@ func (view JobView) GetTriggersToUpdate(ctx context.Context, t time.Time) ([]*Jo
 	var triggers []*JobTrigger
 	err := view.db.useTx(ctx, func(ctx context.Context, tx *sqlx.Tx) error {
 		var err error
-		triggers, err = queryMany[JobTrigger](ctx, tx, `SELECT * FROM "gorun_trigger" WHERE "scheduled_until" < $1`, t)
+		triggers, err = queryMany[JobTrigger](ctx, tx, view.db.sql(`SELECT * FROM {trigger} WHERE "scheduled_until" < $1 AND "finished_at" IS NULL AND "paused_at" IS NULL AND "event" IS NULL`), t)
 		return err
 	})
 	return triggers, err
 }
 
-func (view JobView) UpdateScheduledUntil(ctx context.Context, triggerId string, nextScheduledUntil time.Time, prevScheduledUntil time.Time) error {
+// UpdateTriggerProgress saves the scheduled_until, run_count and finished_at of a trigger, provided scheduled_until
+// has not been changed by another process since it was prevScheduledUntil, and the trigger has not been paused.
+func (view JobView) UpdateTriggerProgress(ctx context.Context, jobTrigger *JobTrigger, prevScheduledUntil time.Time) error {
 	return view.db.useTx(ctx, func(ctx context.Context, tx *sqlx.Tx) error {
-		r, err := tx.Exec(`UPDATE "gorun_trigger" SET "scheduled_until" = $1, updated_at = NOW() WHERE "id" = $2 AND "scheduled_until" = $3`,
-			nextScheduledUntil, triggerId, prevScheduledUntil)
+		r, err := tx.Exec(view.db.sql(`UPDATE {trigger} SET "scheduled_until" = $1, "run_count" = $2, "finished_at" = $3, updated_at = NOW() WHERE "id" = $4 AND "scheduled_until" = $5 AND "paused_at" IS NULL`),
+			jobTrigger.ScheduledUntil, jobTrigger.RunCount, jobTrigger.FinishedAt, jobTrigger.Id, prevScheduledUntil)
 		if err != nil {
 			return errors.Wrap(ErrDatabaseError, errors.WithCause(err))
 		} else if n, err := r.RowsAffected(); err != nil {
 			return errors.Wrap(ErrDatabaseError, errors.WithCause(err))
 		} else if n != 1 {
-			return errors.Wrap(ErrConflict, errors.WithMessage("scheduled_until was updated by another process"))
+			return errors.Wrap(ErrConflict, errors.WithMessage("trigger was updated or paused by another process"))
 		}
 
 		return nil
 	})
 }
+
+// ListEventTriggers returns the triggers fired by the event that are not finished or paused.
+func (view JobView) ListEventTriggers(ctx context.Context, event string) ([]*JobTrigger, error) {
+	tenantId := tenantctx.GetTenant(ctx)
+	if tenantId != "" {
+		return queryMany[JobTrigger](ctx, view.db.db, view.db.sql(`SELECT * FROM {trigger} WHERE "tenant_id" = $1 AND "event" = $2 AND "finished_at" IS NULL AND "paused_at" IS NULL`), tenantId, event)
+	}
+	return queryMany[JobTrigger](ctx, view.db.db, view.db.sql(`SELECT * FROM {trigger} WHERE "event" = $1 AND "finished_at" IS NULL AND "paused_at" IS NULL`), event)
+}
+
+// WithTriggerLock calls fn with the trigger in a transaction that holds a lock on it, so that other processes wait to
+// change the trigger until fn returns.
+func (view JobView) WithTriggerLock(ctx context.Context, triggerId string, fn func(ctx context.Context, trigger *JobTrigger) error) error {
+	return view.db.useTx(ctx, func(ctx context.Context, tx *sqlx.Tx) error {
+		trigger, err := queryOne[JobTrigger](ctx, tx, view.db.sql(`SELECT * FROM {trigger} WHERE "id" = $1 FOR UPDATE`), triggerId)
+		if err != nil {
+			return err
+		}
+		return fn(ctx, trigger)
+	})
+}
+
+// DebounceJob saves the job, unless a job with the same dedup key has not started yet, in which case that job is moved
+// to the run time of job and given its arguments instead.  It returns the id of the job that will run, and whether it
+// is a new job.
+func (view JobView) DebounceJob(ctx context.Context, job *JobData) (string, bool, error) {
+	var jobId string
+	created := false
+	err := view.db.useTx(ctx, func(ctx context.Context, tx *sqlx.Tx) error {
+		pending, err := view.lockDedupKey(ctx, tx, job)
+		if err != nil {
+			return err
+		}
+		if pending != nil {
+			moved, err := view.updateScheduledJob(ctx, tx, pending.Id, `"run_at" = $2, "args" = $3`, job.RunAt, job.Args)
+			if err != nil || moved {
+				jobId = pending.Id
+				return err
+			}
+		}
+		jobId, created = job.Id, true
+		return view.InsertJobs(ctx, []*JobData{job})
+	})
+	return jobId, created, err
+}
+
+// ThrottleJob saves the job to run no sooner than window after the last job with the same dedup key.  If a job with the
+// key has not started yet, that job is given the arguments of job instead.  It returns the id of the job that will run,
+// and whether it is a new job.
+func (view JobView) ThrottleJob(ctx context.Context, job *JobData, window time.Duration) (string, bool, error) {
+	var jobId string
+	created := false
+	err := view.db.useTx(ctx, func(ctx context.Context, tx *sqlx.Tx) error {
+		pending, err := view.lockDedupKey(ctx, tx, job)
+		if err != nil {
+			return err
+		}
+		if pending != nil {
+			updated, err := view.updateScheduledJob(ctx, tx, pending.Id, `"args" = $2`, job.Args)
+			if err != nil || updated {
+				jobId = pending.Id
+				return err
+			}
+		}
+		var last sql.NullTime
+		err = tx.GetContext(ctx, &last, view.db.sql(`SELECT MAX("run_at") FROM {job_data} WHERE "dedup_key" = $1 AND "tenant_id" IS NOT DISTINCT FROM $2`),
+			job.DedupKey, job.TenantId)
+		if err != nil {
+			return errors.Wrap(ErrDatabaseError, errors.WithCause(err))
+		}
+		if last.Valid && last.Time.Add(window).After(job.RunAt) {
+			job.RunAt = last.Time.Add(window)
+		}
+		jobId, created = job.Id, true
+		return view.InsertJobs(ctx, []*JobData{job})
+	})
+	return jobId, created, err
+}
+
+// lockDedupKey holds a lock on the dedup key of the job until the transaction ends, and returns the next job with the
+// key that has not started, if there is one.
+func (view JobView) lockDedupKey(ctx context.Context, tx *sqlx.Tx, job *JobData) (*JobData, error) {
+	if job.DedupKey == nil {
+		return nil, errors.New("job does not have a dedup key")
+	}
+	lockKey := *job.DedupKey
+	if job.TenantId != nil {
+		lockKey = *job.TenantId + ":" + lockKey
+	}
+	_, err := tx.ExecContext(ctx, `SELECT pg_advisory_xact_lock(hashtext($1))`, lockKey)
+	if err != nil {
+		return nil, errors.Wrap(ErrDatabaseError, errors.WithCause(err))
+	}
+	pending, err := queryOne[JobData](ctx, tx, view.db.sql(`SELECT * FROM {job_data} WHERE "dedup_key" = $1 AND "tenant_id" IS NOT DISTINCT FROM $2 AND "status" = 'scheduled' ORDER BY "run_at" LIMIT 1`),
+		job.DedupKey, job.TenantId)
+	if errors.Is(err, ErrNotFound) {
+		return nil, nil
+	}
+	return pending, err
+}
+
+// updateScheduledJob sets the columns of a job, provided it has not started.  set refers to the values as $2 onwards.
+func (view JobView) updateScheduledJob(ctx context.Context, tx *sqlx.Tx, jobId string, set string, values ...any) (bool, error) {
+	r, err := tx.ExecContext(ctx, view.db.sql(`UPDATE {job_data} SET `+set+`, "updated_at" = NOW() WHERE "id" = $1 AND "status" = 'scheduled'`),
+		append([]any{jobId}, values...)...)
+	if err != nil {
+		return false, errors.Wrap(ErrDatabaseError, errors.WithCause(err))
+	}
+	n, err := r.RowsAffected()
+	if err != nil {
+		return false, errors.Wrap(ErrDatabaseError, errors.WithCause(err))
+	}
+	return n == 1, nil
+}
+
+// SameSchedule reports whether the triggers fire at the same times, before their options are applied.
+func (t *JobTrigger) SameSchedule(other *JobTrigger) bool {
+	return t.TriggerType == other.TriggerType && t.TriggerData == other.TriggerData
+}
+
+// SameDefinition reports whether the triggers have the same schedule, job and options.
+func (t *JobTrigger) SameDefinition(other *JobTrigger) bool {
+	return t.SameSchedule(other) && t.JobType == other.JobType && equalJSON(t.JobArgs, other.JobArgs) &&
+		slices.Equal(t.Calendars, other.Calendars) && equalTime(t.StartAt, other.StartAt) && equalTime(t.EndAt, other.EndAt) &&
+		equalPtr(t.MaxRuns, other.MaxRuns) && t.DeleteWhenFinished == other.DeleteWhenFinished &&
+		t.Jitter == other.Jitter && t.JitterMode == other.JitterMode
+}
+
+func equalPtr[T comparable](a, b *T) bool {
+	if a == nil || b == nil {
+		return a == b
+	}
+	return *a == *b
+}
+
+func equalTime(a, b *time.Time) bool {
+	if a == nil || b == nil {
+		return a == b
+	}
+	return a.Equal(*b)
+}
+
+// equalJSON compares two JSON documents, since the database does not keep the formatting of jsonb columns.
+func equalJSON(a, b string) bool {
+	if a == b {
+		return true
+	}
+	var va, vb any
+	if json.Unmarshal([]byte(a), &va) != nil || json.Unmarshal([]byte(b), &vb) != nil {
+		return false
+	}
+	return reflect.DeepEqual(va, vb)
+}
@@ -0,0 +1,246 @@
+package gorundb
+
+import (
+	"context"
+	"encoding/base64"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/jmoiron/sqlx"
+	"github.com/jswidler/gorun/errors"
+	"github.com/jswidler/gorun/tenantctx"
+)
+
+var ErrInvalidQuery = errors.Sentinel("invalid job query")
+
+const (
+	DefaultJobQueryLimit = 100
+	MaxJobQueryLimit     = 1000
+)
+
+// JobOrder is the column that the jobs of a JobQuery are sorted by.  Jobs with the same value are sorted by id, which
+// is the order they were created in.
+type JobOrder string
+
+const (
+	OrderById        JobOrder = "id"
+	OrderByRunAt     JobOrder = "run_at"
+	OrderByCreatedAt JobOrder = "created_at"
+	OrderByUpdatedAt JobOrder = "updated_at"
+)
+
+// JobQuery selects a page of jobs.  Filters that are not set match every job, and times are inclusive at the start and
+// exclusive at the end.  Jobs of other tenants than the one in the context are never matched.
+type JobQuery struct {
+	Statuses  []string
+	Types     []string
+	TriggerId string
+	TenantId  string
+
+	CreatedFrom, CreatedUntil time.Time
+	UpdatedFrom, UpdatedUntil time.Time
+	RunFrom, RunUntil         time.Time
+
+	// ResultContains matches jobs with a result that contains the text, ignoring case.
+	ResultContains string
+
+	OrderBy    JobOrder
+	Descending bool
+	// After is the NextCursor of the previous page.
+	After string
+	// Limit is the most jobs in the page, DefaultJobQueryLimit if it is 0.
+	Limit int
+}
+
+// JobPage is a page of the jobs that match a JobQuery.
+type JobPage struct {
+	Jobs []*JobData `json:"jobs"`
+	// Total is the number of jobs that match the filters of the query, on every page.
+	Total int `json:"total"`
+	// NextCursor selects the next page when it is set as the After of the query, and is empty on the last page.
+	NextCursor string `json:"nextCursor"`
+}
+
+// Normalize returns the query with the default order and limit, or ErrInvalidQuery if they are not valid.
+func (q JobQuery) Normalize() (JobQuery, error) {
+	switch q.OrderBy {
+	case "":
+		q.OrderBy = OrderById
+	case OrderById, OrderByRunAt, OrderByCreatedAt, OrderByUpdatedAt:
+	default:
+		return q, errors.Wrap(ErrInvalidQuery, errors.WithMessagef("can not order jobs by %q", q.OrderBy))
+	}
+	if q.Limit == 0 {
+		q.Limit = DefaultJobQueryLimit
+	} else if q.Limit < 0 || q.Limit > MaxJobQueryLimit {
+		return q, errors.Wrap(ErrInvalidQuery, errors.WithMessagef("limit must be between 1 and %d, got %d", MaxJobQueryLimit, q.Limit))
+	}
+	return q, nil
+}
+
+// OrderValue returns the time that the jobs are ordered by for the job, or the zero time when they are ordered by id.
+func (q JobQuery) OrderValue(job *JobData) time.Time {
+	switch q.OrderBy {
+	case OrderByRunAt:
+		return job.RunAt
+	case OrderByCreatedAt:
+		return job.CreatedAt
+	case OrderByUpdatedAt:
+		return job.UpdatedAt
+	default:
+		return time.Time{}
+	}
+}
+
+// Cursor returns the NextCursor of a page that ends with the job.  When the jobs are ordered by id it is the id of the
+// job, and otherwise it is the order value and id of the job, so the next page is the same if the job is updated or
+// deleted.
+func (q JobQuery) Cursor(job *JobData) string {
+	if q.OrderBy == OrderById {
+		return job.Id
+	}
+	return base64.RawURLEncoding.EncodeToString([]byte(q.OrderValue(job).UTC().Format(time.RFC3339Nano) + "|" + job.Id))
+}
+
+// ParseCursor returns the order value and id of the After cursor, or ErrInvalidQuery if it is not a cursor for the
+// order of the query.  The value is the zero time when the jobs are ordered by id.
+func (q JobQuery) ParseCursor() (time.Time, string, error) {
+	if q.OrderBy == OrderById {
+		return time.Time{}, q.After, nil
+	}
+	invalid := errors.Wrap(ErrInvalidQuery, errors.WithMessagef("invalid cursor %q for jobs ordered by %s", q.After, q.OrderBy))
+	b, err := base64.RawURLEncoding.DecodeString(q.After)
+	if err != nil {
+		return time.Time{}, "", invalid
+	}
+	value, id, ok := strings.Cut(string(b), "|")
+	if !ok || id == "" {
+		return time.Time{}, "", invalid
+	}
+	t, err := time.Parse(time.RFC3339Nano, value)
+	if err != nil {
+		return time.Time{}, "", invalid
+	}
+	return t, id, nil
+}
+
+// Matches returns whether the job matches the filters of the query.  It does not check the tenant of the context.
+func (q JobQuery) Matches(job *JobData) bool {
+	inRange := func(t, from, until time.Time) bool {
+		return (from.IsZero() || !t.Before(from)) && (until.IsZero() || t.Before(until))
+	}
+	return (len(q.Statuses) == 0 || slices.Contains(q.Statuses, job.Status)) &&
+		(len(q.Types) == 0 || slices.Contains(q.Types, job.Type)) &&
+		(q.TriggerId == "" || (job.TriggerId != nil && *job.TriggerId == q.TriggerId)) &&
+		(q.TenantId == "" || (job.TenantId != nil && *job.TenantId == q.TenantId)) &&
+		inRange(job.CreatedAt, q.CreatedFrom, q.CreatedUntil) &&
+		inRange(job.UpdatedAt, q.UpdatedFrom, q.UpdatedUntil) &&
+		inRange(job.RunAt, q.RunFrom, q.RunUntil) &&
+		(q.ResultContains == "" || (job.Result != nil && strings.Contains(strings.ToLower(*job.Result), strings.ToLower(q.ResultContains))))
+}
+
+// EscapeLike escapes the wildcards of a LIKE pattern, for use with ESCAPE '!'.
+func EscapeLike(s string) string {
+	return strings.NewReplacer("!", "!!", "%", "!%", "_", "!_").Replace(s)
+}
+
+func (view JobView) QueryJobs(ctx context.Context, query JobQuery) (*JobPage, error) {
+	query, err := query.Normalize()
+	if err != nil {
+		return nil, err
+	}
+
+	var where []string
+	var args []any
+	filter := func(cond string, arg ...any) {
+		where = append(where, cond)
+		args = append(args, arg...)
+	}
+	in := func(column string, values []string) {
+		filter(column+" IN (?"+strings.Repeat(", ?", len(values)-1)+")", anySlice(values)...)
+	}
+	between := func(column string, from, until time.Time) {
+		if !from.IsZero() {
+			filter(column+" >= ?", from.UTC())
+		}
+		if !until.IsZero() {
+			filter(column+" < ?", until.UTC())
+		}
+	}
+	if tenantId := tenantctx.GetTenant(ctx); tenantId != "" {
+		filter(`"tenant_id" = ?`, tenantId)
+	}
+	if query.TenantId != "" {
+		filter(`"tenant_id" = ?`, query.TenantId)
+	}
+	if len(query.Statuses) > 0 {
+		in(`"status"`, query.Statuses)
+	}
+	if len(query.Types) > 0 {
+		in(`"type"`, query.Types)
+	}
+	if query.TriggerId != "" {
+		filter(`"trigger_id" = ?`, query.TriggerId)
+	}
+	between(`"created_at"`, query.CreatedFrom, query.CreatedUntil)
+	between(`"updated_at"`, query.UpdatedFrom, query.UpdatedUntil)
+	between(`"run_at"`, query.RunFrom, query.RunUntil)
+	if query.ResultContains != "" {
+		filter(`"result" ILIKE ? ESCAPE '!'`, "%"+EscapeLike(query.ResultContains)+"%")
+	}
+
+	page := &JobPage{}
+	err = view.db.useTx(ctx, func(ctx context.Context, tx *sqlx.Tx) error {
+		err := tx.GetContext(ctx, &page.Total, view.sqlWhere(`SELECT COUNT(*) FROM {job_data}`, where, ""), args...)
+		if err != nil {
+			return errors.Wrap(ErrDatabaseError, errors.WithCause(err))
+		}
+
+		column, cmp, dir := `"`+string(query.OrderBy)+`"`, ">", "ASC"
+		if query.Descending {
+			cmp, dir = "<", "DESC"
+		}
+		if query.After != "" {
+			value, id, err := query.ParseCursor()
+			if err != nil {
+				return err
+			}
+			if query.OrderBy == OrderById {
+				filter(`"id" `+cmp+` ?`, id)
+			} else {
+				filter("("+column+" "+cmp+" ? OR ("+column+` = ? AND "id" `+cmp+" ?))", value, value, id)
+			}
+		}
+		order := ` ORDER BY "id" ` + dir
+		if query.OrderBy != OrderById {
+			order = " ORDER BY " + column + " " + dir + `, "id" ` + dir
+		}
+		page.Jobs, err = queryMany[JobData](ctx, tx, view.sqlWhere(`SELECT * FROM {job_data}`, where, order+" LIMIT ?"), append(args, query.Limit+1)...)
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+	if len(page.Jobs) > query.Limit {
+		page.Jobs = page.Jobs[:query.Limit]
+		page.NextCursor = query.Cursor(page.Jobs[query.Limit-1])
+	}
+	return page, nil
+}
+
+// sqlWhere returns the statement with the conditions and suffix, and numbered placeholders.
+func (view JobView) sqlWhere(statement string, where []string, suffix string) string {
+	if len(where) > 0 {
+		statement += " WHERE " + strings.Join(where, " AND ")
+	}
+	return sqlx.Rebind(sqlx.DOLLAR, view.db.sql(statement+suffix))
+}
+
+func anySlice(values []string) []any {
+	args := make([]any, len(values))
+	for i, v := range values {
+		args[i] = v
+	}
+	return args
+}
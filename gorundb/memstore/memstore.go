@@ -0,0 +1,726 @@
+// Package memstore keeps jobs and triggers in memory, for tests and local development.  It has the same semantics as
+// the Postgres store, but nothing is shared between processes or kept after the process exits.
+package memstore
+
+import (
+	"context"
+	"encoding/json"
+	"slices"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/jswidler/gorun/errors"
+	"github.com/jswidler/gorun/gorundb"
+	"github.com/jswidler/gorun/tenantctx"
+	"github.com/jswidler/gorun/ulid"
+)
+
+// Store is an in-memory gorundb.Store.
+type Store struct {
+	mu sync.Mutex
+
+	jobs        map[string]*gorundb.JobData
+	triggers    map[string]*gorundb.JobTrigger
+	calendars   map[string]*gorundb.JobCalendar
+	audits      []*gorundb.TriggerAudit
+	pausedTypes map[string]time.Time
+
+	// undo is set while WithTriggerLock runs.
+	undo *undoLog
+}
+
+// undoLog holds the entries changed while it is set as they were before the first change, so the changes can be undone.
+// A nil entry did not exist.
+type undoLog struct {
+	jobs        map[string]*gorundb.JobData
+	triggers    map[string]*gorundb.JobTrigger
+	calendars   map[string]*gorundb.JobCalendar
+	pausedTypes map[string]*time.Time
+	audits      int
+}
+
+func (u *undoLog) restore(s *Store) {
+	restore(s.jobs, u.jobs)
+	restore(s.triggers, u.triggers)
+	restore(s.calendars, u.calendars)
+	for jobType, pausedAt := range u.pausedTypes {
+		if pausedAt == nil {
+			delete(s.pausedTypes, jobType)
+		} else {
+			s.pausedTypes[jobType] = *pausedAt
+		}
+	}
+	s.audits = s.audits[:u.audits]
+}
+
+// remember adds the entry to the log before its first change.
+func remember[V any](log map[string]*V, entries map[string]*V, key string, clone func(*V) *V) {
+	if _, ok := log[key]; ok {
+		return
+	}
+	if v, ok := entries[key]; ok {
+		log[key] = clone(v)
+	} else {
+		log[key] = nil
+	}
+}
+
+func restore[V any](entries map[string]*V, log map[string]*V) {
+	for key, v := range log {
+		if v == nil {
+			delete(entries, key)
+		} else {
+			entries[key] = v
+		}
+	}
+}
+
+// touchJob must be called before the job is changed, so that the change can be undone.
+func (s *Store) touchJob(jobId string) {
+	if s.undo != nil {
+		remember(s.undo.jobs, s.jobs, jobId, copyJob)
+	}
+}
+
+// touchTrigger must be called before the trigger is changed, so that the change can be undone.
+func (s *Store) touchTrigger(triggerId string) {
+	if s.undo != nil {
+		remember(s.undo.triggers, s.triggers, triggerId, copyTrigger)
+	}
+}
+
+// touchCalendar must be called before the calendar is changed, so that the change can be undone.
+func (s *Store) touchCalendar(calendarId string) {
+	if s.undo != nil {
+		remember(s.undo.calendars, s.calendars, calendarId, func(c *gorundb.JobCalendar) *gorundb.JobCalendar {
+			saved := *c
+			return &saved
+		})
+	}
+}
+
+// touchPausedType must be called before the job type is paused or resumed, so that the change can be undone.
+func (s *Store) touchPausedType(jobType string) {
+	if s.undo == nil {
+		return
+	}
+	if _, ok := s.undo.pausedTypes[jobType]; ok {
+		return
+	}
+	if pausedAt, ok := s.pausedTypes[jobType]; ok {
+		s.undo.pausedTypes[jobType] = &pausedAt
+	} else {
+		s.undo.pausedTypes[jobType] = nil
+	}
+}
+
+// Verify Store satisfies the Store interface.
+var _ gorundb.Store = (*Store)(nil)
+
+func New() *Store {
+	return &Store{
+		jobs:        map[string]*gorundb.JobData{},
+		triggers:    map[string]*gorundb.JobTrigger{},
+		calendars:   map[string]*gorundb.JobCalendar{},
+		pausedTypes: map[string]time.Time{},
+	}
+}
+
+type lockKeyType int
+
+const lockKey lockKeyType = iota
+
+// lock holds the lock on the store until the returned func is called, unless the context already holds it.
+func (s *Store) lock(ctx context.Context) func() {
+	if ctx.Value(lockKey) == s {
+		return func() {}
+	}
+	s.mu.Lock()
+	return s.mu.Unlock
+}
+
+// visible reports whether a row of the tenant can be seen with the tenant in the context.
+func visible(ctx context.Context, tenantId *string) bool {
+	tenant := tenantctx.GetTenant(ctx)
+	return tenant == "" || (tenantId != nil && *tenantId == tenant)
+}
+
+func sameTenant(a, b *string) bool {
+	return (a == nil && b == nil) || (a != nil && b != nil && *a == *b)
+}
+
+func notFound(table, id string) error {
+	return errors.Wrap(gorundb.ErrNotFound, errors.WithMessagef("%s %s not found", table, id))
+}
+
+func copyJob(job *gorundb.JobData) *gorundb.JobData {
+	c := *job
+	return &c
+}
+
+func copyTrigger(trigger *gorundb.JobTrigger) *gorundb.JobTrigger {
+	c := *trigger
+	c.Calendars = slices.Clone(trigger.Calendars)
+	return &c
+}
+
+func (s *Store) AcquireJobsToRun(ctx context.Context, jobLimit int) ([]*gorundb.JobData, error) {
+	defer s.lock(ctx)()
+	now := time.Now().UTC()
+	var due []*gorundb.JobData
+	for _, job := range s.jobs {
+		if _, paused := s.pausedTypes[job.Type]; job.Status == "scheduled" && job.RunAt.Before(now) && !paused {
+			due = append(due, job)
+		}
+	}
+	sortJobs(due)
+	if len(due) > jobLimit {
+		due = due[:jobLimit]
+	}
+	acquired := make([]*gorundb.JobData, 0, len(due))
+	for _, job := range due {
+		s.touchJob(job.Id)
+		job.Status = "running"
+		job.UpdatedAt = now
+		acquired = append(acquired, copyJob(job))
+	}
+	return acquired, nil
+}
+
+func (s *Store) MarkIncompleteJobs(ctx context.Context, jobTimeout time.Duration) ([]*gorundb.JobData, error) {
+	defer s.lock(ctx)()
+	now := time.Now().UTC()
+	result := "job timed out"
+	var stuck []*gorundb.JobData
+	for _, job := range s.jobs {
+		if job.Status == "running" && job.UpdatedAt.Before(now.Add(-jobTimeout)) {
+			s.touchJob(job.Id)
+			job.Status = "failed"
+			job.UpdatedAt = now
+			job.Result = &result
+			stuck = append(stuck, copyJob(job))
+		}
+	}
+	return stuck, nil
+}
+
+func (s *Store) UpdateJob(ctx context.Context, job *gorundb.JobData) error {
+	defer s.lock(ctx)()
+	saved, ok := s.jobs[job.Id]
+	if !ok {
+		return errors.Wrap(gorundb.ErrDatabaseError, errors.WithMessagef("failed to update gorun_job_data with id %s", job.Id))
+	}
+	updated := copyJob(job)
+	updated.CreatedAt = saved.CreatedAt
+	updated.TenantId = saved.TenantId
+	updated.UpdatedAt = time.Now().UTC()
+	s.touchJob(job.Id)
+	s.jobs[job.Id] = updated
+	*job = *copyJob(updated)
+	return nil
+}
+
+func (s *Store) InsertJobs(ctx context.Context, jobs []*gorundb.JobData) error {
+	defer s.lock(ctx)()
+	for _, job := range jobs {
+		if _, ok := s.jobs[job.Id]; ok {
+			return errors.Wrap(gorundb.ErrConflict, errors.WithMessagef("job %s already exists", job.Id))
+		}
+	}
+	now := time.Now().UTC()
+	for _, job := range jobs {
+		saved := copyJob(job)
+		saved.CreatedAt, saved.UpdatedAt = now, now
+		s.touchJob(job.Id)
+		s.jobs[job.Id] = saved
+	}
+	return nil
+}
+
+func (s *Store) GetJobById(ctx context.Context, jobId string) (*gorundb.JobData, error) {
+	defer s.lock(ctx)()
+	job, ok := s.jobs[jobId]
+	if !ok || !visible(ctx, job.TenantId) {
+		return nil, notFound("job", jobId)
+	}
+	return copyJob(job), nil
+}
+
+func (s *Store) ListJobs(ctx context.Context, startTime, endTime time.Time) ([]*gorundb.JobData, error) {
+	defer s.lock(ctx)()
+	return s.findJobs(func(job *gorundb.JobData) bool {
+		return visible(ctx, job.TenantId) && !job.RunAt.Before(startTime) && job.RunAt.Before(endTime)
+	}), nil
+}
+
+func (s *Store) QueryJobs(ctx context.Context, query gorundb.JobQuery) (*gorundb.JobPage, error) {
+	query, err := query.Normalize()
+	if err != nil {
+		return nil, err
+	}
+	defer s.lock(ctx)()
+	jobs := s.findJobs(func(job *gorundb.JobData) bool {
+		return visible(ctx, job.TenantId) && query.Matches(job)
+	})
+	page := &gorundb.JobPage{Total: len(jobs)}
+
+	compare := func(a, b *gorundb.JobData) int {
+		c := 0
+		if query.OrderBy != gorundb.OrderById {
+			c = query.OrderValue(a).Compare(query.OrderValue(b))
+		}
+		if c == 0 {
+			c = strings.Compare(a.Id, b.Id)
+		}
+		if query.Descending {
+			return -c
+		}
+		return c
+	}
+	slices.SortFunc(jobs, compare)
+	if query.After != "" {
+		value, id, err := query.ParseCursor()
+		if err != nil {
+			return nil, err
+		}
+		after := &gorundb.JobData{Id: id, RunAt: value, CreatedAt: value, UpdatedAt: value}
+		i, found := slices.BinarySearchFunc(jobs, after, compare)
+		if found {
+			i++
+		}
+		jobs = jobs[i:]
+	}
+	if len(jobs) > query.Limit {
+		jobs = jobs[:query.Limit]
+		page.NextCursor = query.Cursor(jobs[query.Limit-1])
+	}
+	page.Jobs = jobs
+	return page, nil
+}
+
+func (s *Store) ListScheduledJobsForTrigger(ctx context.Context, triggerId string) ([]*gorundb.JobData, error) {
+	defer s.lock(ctx)()
+	return s.findJobs(func(job *gorundb.JobData) bool {
+		return isScheduledFor(job, triggerId)
+	}), nil
+}
+
+// findJobs returns copies of the jobs that match, in the order they run.
+func (s *Store) findJobs(match func(job *gorundb.JobData) bool) []*gorundb.JobData {
+	var jobs []*gorundb.JobData
+	for _, job := range s.jobs {
+		if match(job) {
+			jobs = append(jobs, copyJob(job))
+		}
+	}
+	sortJobs(jobs)
+	return jobs
+}
+
+func sortJobs(jobs []*gorundb.JobData) {
+	sort.Slice(jobs, func(i, j int) bool {
+		if jobs[i].RunAt.Equal(jobs[j].RunAt) {
+			return jobs[i].Id < jobs[j].Id
+		}
+		return jobs[i].RunAt.Before(jobs[j].RunAt)
+	})
+}
+
+func isScheduledFor(job *gorundb.JobData, triggerId string) bool {
+	return job.Status == "scheduled" && job.TriggerId != nil && *job.TriggerId == triggerId
+}
+
+// deleteScheduledJobs deletes the jobs of the trigger that have not started and returns how many there were.
+func (s *Store) deleteScheduledJobs(triggerId string) int {
+	n := 0
+	for id, job := range s.jobs {
+		if isScheduledFor(job, triggerId) {
+			s.touchJob(id)
+			delete(s.jobs, id)
+			n++
+		}
+	}
+	return n
+}
+
+func (s *Store) DebounceJob(ctx context.Context, job *gorundb.JobData) (string, bool, error) {
+	defer s.lock(ctx)()
+	if pending := s.pendingDedupJob(job); pending != nil {
+		pending.RunAt = job.RunAt
+		s.touchJob(pending.Id)
+		pending.Args = job.Args
+		pending.UpdatedAt = time.Now().UTC()
+		return pending.Id, false, nil
+	}
+	return job.Id, true, s.InsertJobs(context.WithValue(ctx, lockKey, s), []*gorundb.JobData{job})
+}
+
+func (s *Store) ThrottleJob(ctx context.Context, job *gorundb.JobData, window time.Duration) (string, bool, error) {
+	defer s.lock(ctx)()
+	if pending := s.pendingDedupJob(job); pending != nil {
+		s.touchJob(pending.Id)
+		pending.Args = job.Args
+		pending.UpdatedAt = time.Now().UTC()
+		return pending.Id, false, nil
+	}
+	var last time.Time
+	for _, saved := range s.jobs {
+		if sameDedupKey(saved, job) && saved.RunAt.After(last) {
+			last = saved.RunAt
+		}
+	}
+	if !last.IsZero() && last.Add(window).After(job.RunAt) {
+		job.RunAt = last.Add(window)
+	}
+	return job.Id, true, s.InsertJobs(context.WithValue(ctx, lockKey, s), []*gorundb.JobData{job})
+}
+
+// pendingDedupJob returns the next job with the dedup key of job that has not started.
+func (s *Store) pendingDedupJob(job *gorundb.JobData) *gorundb.JobData {
+	var pending *gorundb.JobData
+	for _, saved := range s.jobs {
+		if saved.Status == "scheduled" && sameDedupKey(saved, job) && (pending == nil || saved.RunAt.Before(pending.RunAt)) {
+			pending = saved
+		}
+	}
+	return pending
+}
+
+func sameDedupKey(a, b *gorundb.JobData) bool {
+	return a.DedupKey != nil && b.DedupKey != nil && *a.DedupKey == *b.DedupKey && equalPtr(a.TenantId, b.TenantId)
+}
+
+func equalPtr[T comparable](a, b *T) bool {
+	if a == nil || b == nil {
+		return a == b
+	}
+	return *a == *b
+}
+
+func (s *Store) GetTriggerById(ctx context.Context, triggerId string) (*gorundb.JobTrigger, error) {
+	defer s.lock(ctx)()
+	trigger, ok := s.triggers[triggerId]
+	if !ok || !visible(ctx, trigger.TenantId) {
+		return nil, notFound("trigger", triggerId)
+	}
+	return copyTrigger(trigger), nil
+}
+
+func (s *Store) ListTriggers(ctx context.Context) ([]*gorundb.JobTrigger, error) {
+	defer s.lock(ctx)()
+	return s.findTriggers(func(trigger *gorundb.JobTrigger) bool {
+		return visible(ctx, trigger.TenantId)
+	}), nil
+}
+
+func (s *Store) GetTriggersToUpdate(ctx context.Context, t time.Time) ([]*gorundb.JobTrigger, error) {
+	defer s.lock(ctx)()
+	return s.findTriggers(func(trigger *gorundb.JobTrigger) bool {
+		return trigger.ScheduledUntil.Before(t) && trigger.FinishedAt == nil && trigger.PausedAt == nil && trigger.Event == nil
+	}), nil
+}
+
+func (s *Store) ListEventTriggers(ctx context.Context, event string) ([]*gorundb.JobTrigger, error) {
+	defer s.lock(ctx)()
+	return s.findTriggers(func(trigger *gorundb.JobTrigger) bool {
+		return visible(ctx, trigger.TenantId) && trigger.Event != nil && *trigger.Event == event &&
+			trigger.FinishedAt == nil && trigger.PausedAt == nil
+	}), nil
+}
+
+// findTriggers returns copies of the triggers that match, ordered by id.
+func (s *Store) findTriggers(match func(trigger *gorundb.JobTrigger) bool) []*gorundb.JobTrigger {
+	var triggers []*gorundb.JobTrigger
+	for _, trigger := range s.triggers {
+		if match(trigger) {
+			triggers = append(triggers, copyTrigger(trigger))
+		}
+	}
+	sort.Slice(triggers, func(i, j int) bool { return triggers[i].Id < triggers[j].Id })
+	return triggers
+}
+
+func (s *Store) MaybeUpsertTriggerWithJobs(ctx context.Context, jobTrigger *gorundb.JobTrigger, jobs []*gorundb.JobData) error {
+	defer s.lock(ctx)()
+	ctx = context.WithValue(ctx, lockKey, s)
+	trig, ok := s.triggers[jobTrigger.Id]
+	if !ok {
+		now := time.Now().UTC()
+		saved := copyTrigger(jobTrigger)
+		saved.CreatedAt, saved.UpdatedAt = now, now
+		if saved.Version == 0 {
+			saved.Version = 1
+		}
+		err := s.InsertJobs(ctx, jobs)
+		if err != nil {
+			return err
+		}
+		s.touchTrigger(jobTrigger.Id)
+		s.triggers[jobTrigger.Id] = saved
+		return nil
+	}
+	if trig.SameDefinition(jobTrigger) {
+		return nil
+	}
+
+	// Changing a paused trigger does not resume it.
+	jobTrigger.PausedAt = trig.PausedAt
+	jobTrigger.Version = trig.Version + 1
+	if trig.SameSchedule(jobTrigger) {
+		// The schedule is the same, so keep its phase.  The jobs already scheduled keep their run times, with the new
+		// arguments.
+		now := time.Now().UTC()
+		for _, job := range s.jobs {
+			if isScheduledFor(job, jobTrigger.Id) {
+				s.touchJob(job.Id)
+				job.Args = jobTrigger.JobArgs
+				job.UpdatedAt = now
+			}
+		}
+		jobTrigger.ScheduledUntil = trig.ScheduledUntil
+		jobTrigger.RunCount = trig.RunCount
+		jobTrigger.FinishedAt = trig.FinishedAt
+		jobs = nil
+	} else {
+		replaced := s.deleteScheduledJobs(jobTrigger.Id)
+		if trig.PausedAt != nil {
+			// The first job is scheduled when the trigger is resumed.
+			jobs = nil
+		}
+		jobTrigger.RunCount = max(trig.RunCount-replaced, 0) + len(jobs)
+	}
+	s.insertTriggerAudit(trig, jobTrigger.Version)
+	s.replaceTrigger(jobTrigger)
+	return s.InsertJobs(ctx, jobs)
+}
+
+func (s *Store) UpdateTrigger(ctx context.Context, jobTrigger *gorundb.JobTrigger, prev *gorundb.JobTrigger, jobs []*gorundb.JobData) error {
+	defer s.lock(ctx)()
+	trig, ok := s.triggers[prev.Id]
+	if !ok || trig.Version != prev.Version || !trig.ScheduledUntil.Equal(prev.ScheduledUntil) {
+		return errors.Wrap(gorundb.ErrConflict, errors.WithMessagef("trigger %s was changed by another process", prev.Id))
+	}
+	for _, job := range jobs {
+		if _, ok := s.jobs[job.Id]; ok {
+			return errors.Wrap(gorundb.ErrConflict, errors.WithMessagef("job %s already exists", job.Id))
+		}
+	}
+	replaced := s.deleteScheduledJobs(prev.Id)
+	jobTrigger.Version = prev.Version + 1
+	jobTrigger.RunCount = max(prev.RunCount-replaced, 0) + len(jobs)
+	s.insertTriggerAudit(prev, jobTrigger.Version)
+	s.replaceTrigger(jobTrigger)
+	return s.InsertJobs(context.WithValue(ctx, lockKey, s), jobs)
+}
+
+// replaceTrigger saves the trigger over the existing one, keeping when and for which tenant it was created.
+func (s *Store) replaceTrigger(jobTrigger *gorundb.JobTrigger) {
+	saved := copyTrigger(jobTrigger)
+	if trig, ok := s.triggers[jobTrigger.Id]; ok {
+		saved.CreatedAt = trig.CreatedAt
+		saved.TenantId = trig.TenantId
+	}
+	saved.UpdatedAt = time.Now().UTC()
+	s.touchTrigger(jobTrigger.Id)
+	s.triggers[jobTrigger.Id] = saved
+}
+
+func (s *Store) insertTriggerAudit(prev *gorundb.JobTrigger, version int) {
+	previous, _ := json.Marshal(prev)
+	s.audits = append(s.audits, &gorundb.TriggerAudit{
+		Id:        ulid.New(),
+		TenantId:  prev.TenantId,
+		CreatedAt: time.Now().UTC(),
+		TriggerId: prev.Id,
+		Version:   version,
+		Previous:  string(previous),
+	})
+}
+
+func (s *Store) ScheduleNewJobsFromTrigger(ctx context.Context, jobTrigger *gorundb.JobTrigger, prevScheduleUntil time.Time, jobs []*gorundb.JobData) error {
+	defer s.lock(ctx)()
+	trig, ok := s.triggers[jobTrigger.Id]
+	if !ok || !trig.ScheduledUntil.Equal(prevScheduleUntil) || trig.PausedAt != nil {
+		return errors.Wrap(gorundb.ErrConflict, errors.WithMessage("trigger was updated or paused by another process"))
+	}
+	err := s.InsertJobs(context.WithValue(ctx, lockKey, s), jobs)
+	if err != nil {
+		return err
+	}
+	s.touchTrigger(jobTrigger.Id)
+	if jobTrigger.FinishedAt != nil && jobTrigger.DeleteWhenFinished {
+		delete(s.triggers, jobTrigger.Id)
+		return nil
+	}
+	trig.ScheduledUntil = jobTrigger.ScheduledUntil
+	trig.RunCount = jobTrigger.RunCount
+	trig.FinishedAt = jobTrigger.FinishedAt
+	trig.UpdatedAt = time.Now().UTC()
+	return nil
+}
+
+func (s *Store) WithTriggerLock(ctx context.Context, triggerId string, fn func(ctx context.Context, trigger *gorundb.JobTrigger) error) error {
+	defer s.lock(ctx)()
+	trigger, ok := s.triggers[triggerId]
+	if !ok {
+		return notFound("trigger", triggerId)
+	}
+
+	if s.undo != nil {
+		// The changes are part of the enclosing WithTriggerLock, which undoes them if it fails.
+		return fn(ctx, copyTrigger(trigger))
+	}
+
+	// Log the entries that fn changes, so that its changes can be undone if it fails.
+	s.undo = &undoLog{
+		jobs:        map[string]*gorundb.JobData{},
+		triggers:    map[string]*gorundb.JobTrigger{},
+		calendars:   map[string]*gorundb.JobCalendar{},
+		pausedTypes: map[string]*time.Time{},
+		audits:      len(s.audits),
+	}
+	defer func() { s.undo = nil }()
+	err := fn(context.WithValue(ctx, lockKey, s), copyTrigger(trigger))
+	if err != nil {
+		s.undo.restore(s)
+	}
+	return err
+}
+
+func (s *Store) DeleteTriggerById(ctx context.Context, triggerId string) error {
+	defer s.lock(ctx)()
+	s.deleteScheduledJobs(triggerId)
+	s.touchTrigger(triggerId)
+	delete(s.triggers, triggerId)
+	return nil
+}
+
+func (s *Store) ListTriggerAudits(ctx context.Context, triggerId string) ([]*gorundb.TriggerAudit, error) {
+	defer s.lock(ctx)()
+	var audits []*gorundb.TriggerAudit
+	for _, audit := range s.audits {
+		if audit.TriggerId == triggerId && visible(ctx, audit.TenantId) {
+			c := *audit
+			audits = append(audits, &c)
+		}
+	}
+	sort.SliceStable(audits, func(i, j int) bool { return audits[i].Version < audits[j].Version })
+	return audits, nil
+}
+
+func (s *Store) PauseTrigger(ctx context.Context, triggerId string) error {
+	defer s.lock(ctx)()
+	removed := s.deleteScheduledJobs(triggerId)
+	s.touchTrigger(triggerId)
+	if trigger, ok := s.triggers[triggerId]; ok {
+		now := time.Now().UTC()
+		if trigger.PausedAt == nil {
+			trigger.PausedAt = &now
+		}
+		trigger.RunCount = max(trigger.RunCount-removed, 0)
+		trigger.UpdatedAt = now
+	}
+	return nil
+}
+
+func (s *Store) ResumeTrigger(ctx context.Context, triggerId string, scheduledUntil time.Time) error {
+	defer s.lock(ctx)()
+	s.touchTrigger(triggerId)
+	if trigger, ok := s.triggers[triggerId]; ok && trigger.PausedAt != nil {
+		trigger.PausedAt = nil
+		trigger.ScheduledUntil = scheduledUntil
+		trigger.UpdatedAt = time.Now().UTC()
+	}
+	return nil
+}
+
+func (s *Store) PauseJobType(ctx context.Context, jobType string) error {
+	defer s.lock(ctx)()
+	s.touchPausedType(jobType)
+	if _, ok := s.pausedTypes[jobType]; !ok {
+		s.pausedTypes[jobType] = time.Now().UTC()
+	}
+	return nil
+}
+
+func (s *Store) ResumeJobType(ctx context.Context, jobType string) error {
+	defer s.lock(ctx)()
+	s.touchPausedType(jobType)
+	delete(s.pausedTypes, jobType)
+	return nil
+}
+
+func (s *Store) ListPausedJobTypes(ctx context.Context) ([]string, error) {
+	defer s.lock(ctx)()
+	return sortedKeys(s.pausedTypes), nil
+}
+
+func sortedKeys[V any](m map[string]V) []string {
+	keys := make([]string, 0, len(m))
+	for key := range m {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+func (s *Store) UpsertCalendar(ctx context.Context, calendar *gorundb.JobCalendar) error {
+	defer s.lock(ctx)()
+	now := time.Now().UTC()
+	saved := *calendar
+	saved.CreatedAt, saved.UpdatedAt = now, now
+	if existing, ok := s.calendars[calendar.Id]; ok {
+		if !sameTenant(existing.TenantId, calendar.TenantId) {
+			return errors.Wrap(gorundb.ErrConflict, errors.WithMessagef("calendar %s belongs to another tenant", calendar.Id))
+		}
+		saved.CreatedAt = existing.CreatedAt
+	}
+	s.touchCalendar(calendar.Id)
+	s.calendars[calendar.Id] = &saved
+	return nil
+}
+
+func (s *Store) GetCalendarById(ctx context.Context, calendarId string) (*gorundb.JobCalendar, error) {
+	defer s.lock(ctx)()
+	calendar, ok := s.calendars[calendarId]
+	if !ok || !visible(ctx, calendar.TenantId) {
+		return nil, notFound("calendar", calendarId)
+	}
+	c := *calendar
+	return &c, nil
+}
+
+func (s *Store) GetCalendarsByIds(ctx context.Context, calendarIds []string) ([]*gorundb.JobCalendar, error) {
+	defer s.lock(ctx)()
+	var calendars []*gorundb.JobCalendar
+	for _, id := range calendarIds {
+		if calendar, ok := s.calendars[id]; ok && visible(ctx, calendar.TenantId) {
+			c := *calendar
+			calendars = append(calendars, &c)
+		}
+	}
+	return calendars, nil
+}
+
+func (s *Store) ListCalendars(ctx context.Context) ([]*gorundb.JobCalendar, error) {
+	defer s.lock(ctx)()
+	var calendars []*gorundb.JobCalendar
+	for _, id := range sortedKeys(s.calendars) {
+		if calendar := s.calendars[id]; visible(ctx, calendar.TenantId) {
+			c := *calendar
+			calendars = append(calendars, &c)
+		}
+	}
+	return calendars, nil
+}
+
+func (s *Store) DeleteCalendarById(ctx context.Context, calendarId string) error {
+	defer s.lock(ctx)()
+	if calendar, ok := s.calendars[calendarId]; ok && visible(ctx, calendar.TenantId) {
+		s.touchCalendar(calendarId)
+		delete(s.calendars, calendarId)
+	}
+	return nil
+}
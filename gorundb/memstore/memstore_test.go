@@ -0,0 +1,80 @@
+package memstore_test
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/jswidler/gorun/gorundb"
+	"github.com/jswidler/gorun/gorundb/memstore"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestScheduleNewJobsFromTriggerConflicts(t *testing.T) {
+	ctx := context.Background()
+	store := memstore.New()
+	scheduledUntil := time.Now()
+	trigger := &gorundb.JobTrigger{Id: "t1", TriggerType: "repeat", ScheduledUntil: scheduledUntil}
+	err := store.MaybeUpsertTriggerWithJobs(ctx, trigger, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	next := *trigger
+	next.ScheduledUntil = scheduledUntil.Add(time.Minute)
+	job := &gorundb.JobData{Id: "j1", Status: "scheduled", TriggerId: &trigger.Id, RunAt: next.ScheduledUntil}
+	assert.NoError(t, store.ScheduleNewJobsFromTrigger(ctx, &next, scheduledUntil, []*gorundb.JobData{job}))
+
+	// A second process that read the trigger before the first saved its progress must not schedule the job again.
+	again := *trigger
+	again.ScheduledUntil = scheduledUntil.Add(time.Minute)
+	job2 := &gorundb.JobData{Id: "j2", Status: "scheduled", TriggerId: &trigger.Id, RunAt: again.ScheduledUntil}
+	err = store.ScheduleNewJobsFromTrigger(ctx, &again, scheduledUntil, []*gorundb.JobData{job2})
+	assert.ErrorIs(t, err, gorundb.ErrConflict)
+
+	jobs, err := store.ListScheduledJobsForTrigger(ctx, "t1")
+	assert.NoError(t, err)
+	assert.Len(t, jobs, 1)
+}
+
+func TestWithTriggerLockRollsBack(t *testing.T) {
+	ctx := context.Background()
+	store := memstore.New()
+	err := store.MaybeUpsertTriggerWithJobs(ctx, &gorundb.JobTrigger{Id: "t1", TriggerType: "event"}, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = store.InsertJobs(ctx, []*gorundb.JobData{{Id: "j0", Status: "completed"}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = store.WithTriggerLock(ctx, "t1", func(ctx context.Context, trigger *gorundb.JobTrigger) error {
+		err := store.InsertJobs(ctx, []*gorundb.JobData{{Id: "j1", Status: "scheduled", TriggerId: &trigger.Id}})
+		assert.NoError(t, err)
+		assert.NoError(t, store.UpdateJob(ctx, &gorundb.JobData{Id: "j0", Status: "failed"}))
+		assert.NoError(t, store.PauseTrigger(ctx, "t1"))
+		assert.NoError(t, store.PauseJobType(ctx, "test"))
+		return gorundb.ErrDatabaseError
+	})
+	assert.ErrorIs(t, err, gorundb.ErrDatabaseError)
+	_, err = store.GetJobById(ctx, "j1")
+	assert.ErrorIs(t, err, gorundb.ErrNotFound)
+	job, err := store.GetJobById(ctx, "j0")
+	assert.NoError(t, err)
+	assert.Equal(t, "completed", job.Status)
+	trigger, err := store.GetTriggerById(ctx, "t1")
+	assert.NoError(t, err)
+	assert.Nil(t, trigger.PausedAt)
+	paused, err := store.ListPausedJobTypes(ctx)
+	assert.NoError(t, err)
+	assert.Empty(t, paused)
+
+	// The changes are kept when fn succeeds.
+	err = store.WithTriggerLock(ctx, "t1", func(ctx context.Context, trigger *gorundb.JobTrigger) error {
+		return store.UpdateJob(ctx, &gorundb.JobData{Id: "j0", Status: "failed"})
+	})
+	assert.NoError(t, err)
+	job, err = store.GetJobById(ctx, "j0")
+	assert.NoError(t, err)
+	assert.Equal(t, "failed", job.Status)
+}
@@ -1,5 +1,5 @@
 -- +migrate Up
-CREATE TABLE IF NOT EXISTS "gorun_trigger" (
+CREATE TABLE IF NOT EXISTS {trigger} (
   "id" varchar(32) NOT NULL PRIMARY KEY,
   "tenant_id" varchar(128),
   "created_at" timestamp NOT NULL,
@@ -13,9 +13,9 @@ CREATE TABLE IF NOT EXISTS "gorun_trigger" (
   "job_args" jsonb NOT NULL
 );
 
-CREATE INDEX IF NOT EXISTS "gorun_trigger_scheduled_until" ON "gorun_trigger" ("scheduled_until");
+CREATE INDEX IF NOT EXISTS "{prefix}trigger_scheduled_until" ON {trigger} ("scheduled_until");
 
-CREATE TABLE IF NOT EXISTS "gorun_job_data" (
+CREATE TABLE IF NOT EXISTS {job_data} (
   "id" varchar(32) NOT NULL PRIMARY KEY,
   "tenant_id" varchar(128),
   "created_at" timestamp NOT NULL,
@@ -31,10 +31,10 @@ CREATE TABLE IF NOT EXISTS "gorun_job_data" (
   "result" varchar(1000)
 );
 
-CREATE INDEX IF NOT EXISTS "gorun_job_data_status_run_at" ON "gorun_job_data" ("status", "run_at");
-CREATE INDEX IF NOT EXISTS "gorun_job_data_status_updated_at" ON "gorun_job_data" ("status", "updated_at");
+CREATE INDEX IF NOT EXISTS "{prefix}job_data_status_run_at" ON {job_data} ("status", "run_at");
+CREATE INDEX IF NOT EXISTS "{prefix}job_data_status_updated_at" ON {job_data} ("status", "updated_at");
 
 -- +migrate Down
 
-DROP TABLE IF EXISTS "gorun_job_data";
-DROP TABLE IF EXISTS "gorun_trigger";
+DROP TABLE IF EXISTS {job_data};
+DROP TABLE IF EXISTS {trigger};
@@ -0,0 +1,12 @@
+-- +migrate Up
+ALTER TABLE {trigger} ADD COLUMN IF NOT EXISTS "paused_at" timestamp;
+
+CREATE TABLE IF NOT EXISTS {paused_job_type} (
+  "job_type" varchar(32) NOT NULL PRIMARY KEY,
+  "paused_at" timestamp NOT NULL
+);
+
+-- +migrate Down
+
+DROP TABLE IF EXISTS {paused_job_type};
+ALTER TABLE {trigger} DROP COLUMN IF EXISTS "paused_at";
@@ -0,0 +1,19 @@
+-- +migrate Up
+ALTER TABLE {trigger} ADD COLUMN IF NOT EXISTS "version" integer NOT NULL DEFAULT 1;
+
+CREATE TABLE IF NOT EXISTS {trigger_audit} (
+  "id" varchar(32) NOT NULL PRIMARY KEY,
+  "tenant_id" varchar(128),
+  "created_at" timestamp NOT NULL,
+
+  "trigger_id" varchar(32) NOT NULL,
+  "version" integer NOT NULL, -- the version that replaced the previous definition
+  "previous" jsonb NOT NULL
+);
+
+CREATE INDEX IF NOT EXISTS "{prefix}trigger_audit_trigger_id" ON {trigger_audit} ("trigger_id", "version");
+
+-- +migrate Down
+
+DROP TABLE IF EXISTS {trigger_audit};
+ALTER TABLE {trigger} DROP COLUMN IF EXISTS "version";
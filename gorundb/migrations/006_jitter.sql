@@ -0,0 +1,8 @@
+-- +migrate Up
+ALTER TABLE {trigger} ADD COLUMN IF NOT EXISTS "jitter" bigint NOT NULL DEFAULT 0; -- nanoseconds
+ALTER TABLE {trigger} ADD COLUMN IF NOT EXISTS "jitter_mode" varchar(16) NOT NULL DEFAULT 'random';
+
+-- +migrate Down
+
+ALTER TABLE {trigger} DROP COLUMN IF EXISTS "jitter_mode";
+ALTER TABLE {trigger} DROP COLUMN IF EXISTS "jitter";
@@ -0,0 +1,13 @@
+-- +migrate Up
+ALTER TABLE {trigger} ADD COLUMN IF NOT EXISTS "event" varchar(128);
+CREATE INDEX IF NOT EXISTS "{prefix}trigger_event" ON {trigger} ("event");
+
+ALTER TABLE {job_data} ADD COLUMN IF NOT EXISTS "dedup_key" varchar(160);
+CREATE INDEX IF NOT EXISTS "{prefix}job_data_dedup_key_run_at" ON {job_data} ("dedup_key", "run_at");
+
+-- +migrate Down
+
+DROP INDEX IF EXISTS {schema}"{prefix}job_data_dedup_key_run_at";
+ALTER TABLE {job_data} DROP COLUMN IF EXISTS "dedup_key";
+DROP INDEX IF EXISTS {schema}"{prefix}trigger_event";
+ALTER TABLE {trigger} DROP COLUMN IF EXISTS "event";
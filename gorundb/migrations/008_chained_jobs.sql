@@ -0,0 +1,6 @@
+-- +migrate Up
+ALTER TABLE {job_data} ADD COLUMN IF NOT EXISTS "upstream_job_id" varchar(32);
+
+-- +migrate Down
+
+ALTER TABLE {job_data} DROP COLUMN IF EXISTS "upstream_job_id";
@@ -0,0 +1,70 @@
+-- +migrate Up
+ALTER TABLE {trigger}
+  ALTER COLUMN "id" TYPE varchar(128),
+  ALTER COLUMN "trigger_data" TYPE text,
+  ALTER COLUMN "job_type" TYPE text,
+  ALTER COLUMN "event" TYPE text,
+  ALTER COLUMN "created_at" TYPE timestamptz USING "created_at" AT TIME ZONE 'UTC',
+  ALTER COLUMN "updated_at" TYPE timestamptz USING "updated_at" AT TIME ZONE 'UTC',
+  ALTER COLUMN "scheduled_until" TYPE timestamptz USING "scheduled_until" AT TIME ZONE 'UTC',
+  ALTER COLUMN "start_at" TYPE timestamptz USING "start_at" AT TIME ZONE 'UTC',
+  ALTER COLUMN "end_at" TYPE timestamptz USING "end_at" AT TIME ZONE 'UTC',
+  ALTER COLUMN "finished_at" TYPE timestamptz USING "finished_at" AT TIME ZONE 'UTC',
+  ALTER COLUMN "paused_at" TYPE timestamptz USING "paused_at" AT TIME ZONE 'UTC';
+
+ALTER TABLE {job_data}
+  ALTER COLUMN "trigger_id" TYPE varchar(128),
+  ALTER COLUMN "type" TYPE text,
+  ALTER COLUMN "result" TYPE text,
+  ALTER COLUMN "dedup_key" TYPE text,
+  ALTER COLUMN "created_at" TYPE timestamptz USING "created_at" AT TIME ZONE 'UTC',
+  ALTER COLUMN "updated_at" TYPE timestamptz USING "updated_at" AT TIME ZONE 'UTC',
+  ALTER COLUMN "run_at" TYPE timestamptz USING "run_at" AT TIME ZONE 'UTC';
+
+ALTER TABLE {calendar}
+  ALTER COLUMN "created_at" TYPE timestamptz USING "created_at" AT TIME ZONE 'UTC',
+  ALTER COLUMN "updated_at" TYPE timestamptz USING "updated_at" AT TIME ZONE 'UTC';
+
+ALTER TABLE {paused_job_type}
+  ALTER COLUMN "job_type" TYPE text,
+  ALTER COLUMN "paused_at" TYPE timestamptz USING "paused_at" AT TIME ZONE 'UTC';
+
+ALTER TABLE {trigger_audit}
+  ALTER COLUMN "trigger_id" TYPE varchar(128),
+  ALTER COLUMN "created_at" TYPE timestamptz USING "created_at" AT TIME ZONE 'UTC';
+
+-- +migrate Down
+
+ALTER TABLE {trigger_audit}
+  ALTER COLUMN "trigger_id" TYPE varchar(32),
+  ALTER COLUMN "created_at" TYPE timestamp USING "created_at" AT TIME ZONE 'UTC';
+
+ALTER TABLE {paused_job_type}
+  ALTER COLUMN "job_type" TYPE varchar(32),
+  ALTER COLUMN "paused_at" TYPE timestamp USING "paused_at" AT TIME ZONE 'UTC';
+
+ALTER TABLE {calendar}
+  ALTER COLUMN "created_at" TYPE timestamp USING "created_at" AT TIME ZONE 'UTC',
+  ALTER COLUMN "updated_at" TYPE timestamp USING "updated_at" AT TIME ZONE 'UTC';
+
+ALTER TABLE {job_data}
+  ALTER COLUMN "trigger_id" TYPE varchar(32),
+  ALTER COLUMN "type" TYPE varchar(32),
+  ALTER COLUMN "result" TYPE varchar(1000),
+  ALTER COLUMN "dedup_key" TYPE varchar(160),
+  ALTER COLUMN "created_at" TYPE timestamp USING "created_at" AT TIME ZONE 'UTC',
+  ALTER COLUMN "updated_at" TYPE timestamp USING "updated_at" AT TIME ZONE 'UTC',
+  ALTER COLUMN "run_at" TYPE timestamp USING "run_at" AT TIME ZONE 'UTC';
+
+ALTER TABLE {trigger}
+  ALTER COLUMN "id" TYPE varchar(32),
+  ALTER COLUMN "trigger_data" TYPE varchar(128),
+  ALTER COLUMN "job_type" TYPE varchar(32),
+  ALTER COLUMN "event" TYPE varchar(128),
+  ALTER COLUMN "created_at" TYPE timestamp USING "created_at" AT TIME ZONE 'UTC',
+  ALTER COLUMN "updated_at" TYPE timestamp USING "updated_at" AT TIME ZONE 'UTC',
+  ALTER COLUMN "scheduled_until" TYPE timestamp USING "scheduled_until" AT TIME ZONE 'UTC',
+  ALTER COLUMN "start_at" TYPE timestamp USING "start_at" AT TIME ZONE 'UTC',
+  ALTER COLUMN "end_at" TYPE timestamp USING "end_at" AT TIME ZONE 'UTC',
+  ALTER COLUMN "finished_at" TYPE timestamp USING "finished_at" AT TIME ZONE 'UTC',
+  ALTER COLUMN "paused_at" TYPE timestamp USING "paused_at" AT TIME ZONE 'UTC';
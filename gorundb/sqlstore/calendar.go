@@ -0,0 +1,48 @@
+package sqlstore
+
+import (
+	"context"
+
+	"github.com/jswidler/gorun/errors"
+	"github.com/jswidler/gorun/gorundb"
+	"github.com/jswidler/gorun/logger"
+	"github.com/jswidler/gorun/tenantctx"
+)
+
+func (s *Store) UpsertCalendar(ctx context.Context, calendar *gorundb.JobCalendar) error {
+	logger.Ctx(ctx).Info().Str("calendarId", calendar.Id).Msg("upserting calendar")
+	return s.useTx(ctx, func(ctx context.Context) error {
+		existing, err := queryOne[gorundb.JobCalendar](ctx, s.conn(ctx), `SELECT * FROM gorun_calendar WHERE id = ?`+s.dialect.forUpdate, calendar.Id)
+		if err != nil && !errors.Is(err, gorundb.ErrNotFound) {
+			return err
+		}
+		if existing != nil && !sameTenant(existing.TenantId, calendar.TenantId) {
+			return errors.Wrap(gorundb.ErrConflict, errors.WithMessagef("calendar %s belongs to another tenant", calendar.Id))
+		}
+		return upsert(ctx, s, "gorun_calendar", calendar)
+	})
+}
+
+func (s *Store) GetCalendarById(ctx context.Context, calendarId string) (*gorundb.JobCalendar, error) {
+	return byId[gorundb.JobCalendar](ctx, s.conn(ctx), "gorun_calendar", calendarId)
+}
+
+func (s *Store) GetCalendarsByIds(ctx context.Context, calendarIds []string) ([]*gorundb.JobCalendar, error) {
+	return byIds[gorundb.JobCalendar](ctx, s.conn(ctx), "gorun_calendar", calendarIds)
+}
+
+func (s *Store) ListCalendars(ctx context.Context) ([]*gorundb.JobCalendar, error) {
+	tenantId := tenantctx.GetTenant(ctx)
+	if tenantId != "" {
+		return queryMany[gorundb.JobCalendar](ctx, s.conn(ctx), `SELECT * FROM gorun_calendar WHERE tenant_id = ?`, tenantId)
+	}
+	return queryMany[gorundb.JobCalendar](ctx, s.conn(ctx), `SELECT * FROM gorun_calendar`)
+}
+
+func (s *Store) DeleteCalendarById(ctx context.Context, calendarId string) error {
+	return s.deleteById(ctx, "gorun_calendar", calendarId)
+}
+
+func sameTenant(a, b *string) bool {
+	return (a == nil && b == nil) || (a != nil && b != nil && *a == *b)
+}
@@ -0,0 +1,70 @@
+package sqlstore
+
+import (
+	"fmt"
+	"strings"
+)
+
+// dialect holds the SQL that differs between the databases the store supports.  Queries use ? placeholders and
+// unquoted identifiers, which all of them accept.
+type dialect struct {
+	// driver is the name of the database/sql driver.
+	driver string
+	// migrations is the directory of the embedded migrations, and migrateDialect the sql-migrate dialect to run them.
+	migrations     string
+	migrateDialect string
+
+	// forUpdate and skipLocked are appended to a SELECT to lock the rows it returns until the transaction ends.
+	forUpdate  string
+	skipLocked string
+	// nullSafeEq compares two values, treating NULL as equal to NULL.
+	nullSafeEq string
+	// insertIgnore starts an INSERT that skips rows with a duplicate key.
+	insertIgnore string
+	// upsert returns the clause that updates the columns of an existing row with the same primary key.
+	upsert func(key string, cols []string) string
+	// lockKey, if set, holds a lock on the key given as its argument until the transaction ends.
+	lockKey string
+
+	isConflict func(err error) bool
+}
+
+var sqliteDialect = dialect{
+	driver:         "sqlite3",
+	migrations:     "migrations/sqlite",
+	migrateDialect: "sqlite3",
+	// SQLite has no row locks.  Transactions are started with BEGIN IMMEDIATE instead, so a transaction holds the write
+	// lock on the database from the start, and the rows it reads cannot be changed until it ends.
+	forUpdate:    "",
+	skipLocked:   "",
+	nullSafeEq:   "IS",
+	insertIgnore: "INSERT OR IGNORE",
+	upsert: func(key string, cols []string) string {
+		set := make([]string, len(cols))
+		for i, col := range cols {
+			set[i] = fmt.Sprintf("%s = excluded.%s", col, col)
+		}
+		return fmt.Sprintf("ON CONFLICT (%s) DO UPDATE SET %s", key, strings.Join(set, ", "))
+	},
+	isConflict: isSQLiteConflict,
+}
+
+var mysqlDialect = dialect{
+	driver:         "mysql",
+	migrations:     "migrations/mysql",
+	migrateDialect: "mysql",
+	forUpdate:      " FOR UPDATE",
+	skipLocked:     " FOR UPDATE SKIP LOCKED",
+	nullSafeEq:     "<=>",
+	insertIgnore:   "INSERT IGNORE",
+	upsert: func(key string, cols []string) string {
+		set := make([]string, len(cols))
+		for i, col := range cols {
+			set[i] = fmt.Sprintf("%s = VALUES(%s)", col, col)
+		}
+		return "ON DUPLICATE KEY UPDATE " + strings.Join(set, ", ")
+	},
+	// The row for the key is locked until the transaction ends, even when the row already exists.
+	lockKey:    `INSERT INTO gorun_lock (lock_key) VALUES (?) ON DUPLICATE KEY UPDATE lock_key = lock_key`,
+	isConflict: isMySQLConflict,
+}
@@ -0,0 +1,211 @@
+package sqlstore
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/jmoiron/sqlx"
+	"github.com/jswidler/gorun/errors"
+	"github.com/jswidler/gorun/gorundb"
+	"github.com/jswidler/gorun/gorundb/internal/columns"
+	"github.com/jswidler/gorun/tenantctx"
+)
+
+// bindArgs stores times in UTC, so that times saved as text compare in the same order as the times.
+func bindArgs(args []any) []any {
+	for i, arg := range args {
+		switch t := arg.(type) {
+		case time.Time:
+			args[i] = t.UTC()
+		case *time.Time:
+			if t == nil {
+				args[i] = nil
+			} else {
+				args[i] = t.UTC()
+			}
+		}
+	}
+	return args
+}
+
+func (s *Store) wrapErr(err error) error {
+	if s.dialect.isConflict(err) {
+		return errors.Wrap(gorundb.ErrConflict, errors.WithCause(err))
+	}
+	return errors.Wrap(gorundb.ErrDatabaseError, errors.WithCause(err))
+}
+
+func queryOne[T any](ctx context.Context, q sqlx.QueryerContext, query string, args ...any) (*T, error) {
+	var val T
+	err := sqlx.GetContext(ctx, q, &val, query, bindArgs(args)...)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return nil, errors.Wrap(gorundb.ErrNotFound, errors.WithCause(err))
+		}
+		return nil, errors.Wrap(gorundb.ErrDatabaseError, errors.WithCause(err))
+	}
+	return &val, nil
+}
+
+func queryMany[T any](ctx context.Context, q sqlx.QueryerContext, query string, args ...any) ([]*T, error) {
+	var val []*T
+	err := sqlx.SelectContext(ctx, q, &val, query, bindArgs(args)...)
+	if err != nil {
+		return nil, errors.Wrap(gorundb.ErrDatabaseError, errors.WithCause(err))
+	}
+	return val, nil
+}
+
+// exec runs the statement and returns the number of rows it changed.
+func (s *Store) exec(ctx context.Context, query string, args ...any) (int64, error) {
+	r, err := s.conn(ctx).ExecContext(ctx, query, bindArgs(args)...)
+	if err != nil {
+		return 0, s.wrapErr(err)
+	}
+	n, err := r.RowsAffected()
+	if err != nil {
+		return 0, errors.Wrap(gorundb.ErrDatabaseError, errors.WithCause(err))
+	}
+	return n, nil
+}
+
+func byId[T any](ctx context.Context, q sqlx.QueryerContext, table string, id string) (*T, error) {
+	tenantId := tenantctx.GetTenant(ctx)
+	if tenantId == "" {
+		return queryOne[T](ctx, q, fmt.Sprintf(`SELECT * FROM %s WHERE id = ?`, table), id)
+	}
+	return queryOne[T](ctx, q, fmt.Sprintf(`SELECT * FROM %s WHERE tenant_id = ? AND id = ?`, table), tenantId, id)
+}
+
+func byIds[T any](ctx context.Context, q sqlx.QueryerContext, table string, ids []string) ([]*T, error) {
+	if len(ids) == 0 {
+		return nil, nil
+	}
+	var query string
+	var args []any
+	var err error
+	tenantId := tenantctx.GetTenant(ctx)
+	if tenantId == "" {
+		query, args, err = sqlx.In(fmt.Sprintf(`SELECT * FROM %s WHERE id IN (?)`, table), ids)
+	} else {
+		query, args, err = sqlx.In(fmt.Sprintf(`SELECT * FROM %s WHERE tenant_id = ? AND id IN (?)`, table), tenantId, ids)
+	}
+	if err != nil {
+		return nil, errors.Wrap(gorundb.ErrDatabaseError, errors.WithCause(err))
+	}
+	return queryMany[T](ctx, q, query, args...)
+}
+
+func (s *Store) deleteById(ctx context.Context, table string, id string) error {
+	var err error
+	tenantId := tenantctx.GetTenant(ctx)
+	if tenantId == "" {
+		_, err = s.exec(ctx, fmt.Sprintf(`DELETE FROM %s WHERE id = ?`, table), id)
+	} else {
+		_, err = s.exec(ctx, fmt.Sprintf(`DELETE FROM %s WHERE tenant_id = ? AND id = ?`, table), tenantId, id)
+	}
+	return err
+}
+
+// maxParams is the most parameters a statement can have in SQLite, which allows fewer than MySQL.
+const maxParams = 32766
+
+// insertRows inserts the rows, setting their created_at and updated_at columns to now, with as few statements as the
+// number of parameters allows.
+func insertRows[T any](ctx context.Context, s *Store, table string, rows []*T) error {
+	if len(rows) == 0 {
+		return nil
+	}
+	now := time.Now().UTC()
+	var names []string
+	values := make([][]any, 0, len(rows))
+	for _, row := range rows {
+		cols := columns.Of(row)
+		if _, found := cols.Get("created_at"); found {
+			cols.Set("created_at", now)
+		}
+		if _, found := cols.Get("updated_at"); found {
+			cols.Set("updated_at", now)
+		}
+		names = cols.Names()
+		values = append(values, cols.Values())
+	}
+
+	rowPlaceholders := "(" + placeholders(len(names)) + ")"
+	chunkSize := maxParams / len(names)
+	for start := 0; start < len(values); start += chunkSize {
+		chunk := values[start:min(start+chunkSize, len(values))]
+		args := make([]any, 0, len(chunk)*len(names))
+		for _, v := range chunk {
+			args = append(args, v...)
+		}
+		query := fmt.Sprintf(`INSERT INTO %s (%s) VALUES %s`, table, strings.Join(names, ", "),
+			strings.TrimSuffix(strings.Repeat(rowPlaceholders+", ", len(chunk)), ", "))
+		_, err := s.exec(ctx, query, args...)
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// updateById saves every column of the row except its id, tenant_id and created_at, and sets updated_at to now.
+func updateById[T any](ctx context.Context, s *Store, table string, row *T) error {
+	cols := columns.Of(row)
+	cols.Remove("created_at")
+	cols.Remove("tenant_id")
+	if _, found := cols.Get("updated_at"); !found {
+		return errors.Wrap(gorundb.ErrNotUpdateable)
+	}
+	cols.Set("updated_at", time.Now().UTC())
+	id, _ := cols.Get("id")
+	cols.Remove("id")
+
+	set := make([]string, 0, len(cols.Names()))
+	for _, col := range cols.Names() {
+		set = append(set, col+" = ?")
+	}
+	query := fmt.Sprintf(`UPDATE %s SET %s WHERE id = ?`, table, strings.Join(set, ", "))
+	n, err := s.exec(ctx, query, append(cols.Values(), id)...)
+	if err != nil {
+		return err
+	} else if n != 1 {
+		return errors.Wrap(gorundb.ErrDatabaseError, errors.WithMessagef("failed to update %s with id %s", table, id))
+	}
+
+	saved, err := queryOne[T](ctx, s.conn(ctx), fmt.Sprintf(`SELECT * FROM %s WHERE id = ?`, table), id)
+	if err != nil {
+		return err
+	}
+	*row = *saved
+	return nil
+}
+
+// upsert inserts the row, or updates every column of the row with the same id except its tenant_id and created_at.
+func upsert[T any](ctx context.Context, s *Store, table string, row *T) error {
+	now := time.Now().UTC()
+	cols := columns.Of(row)
+	if _, found := cols.Get("updated_at"); !found {
+		return errors.Wrap(gorundb.ErrNotUpdateable)
+	}
+	cols.Set("created_at", now)
+	cols.Set("updated_at", now)
+	names := cols.Names()
+	values := cols.Values()
+
+	cols.Remove("id")
+	cols.Remove("created_at")
+	cols.Remove("tenant_id")
+	query := fmt.Sprintf(`INSERT INTO %s (%s) VALUES (%s) %s`,
+		table, strings.Join(names, ", "), placeholders(len(names)),
+		s.dialect.upsert("id", cols.Names()))
+	_, err := s.exec(ctx, query, values...)
+	return err
+}
+
+func placeholders(n int) string {
+	return strings.TrimSuffix(strings.Repeat("?, ", n), ", ")
+}
@@ -0,0 +1,407 @@
+package sqlstore
+
+import (
+	"context"
+	"encoding/json"
+	"time"
+
+	"github.com/jmoiron/sqlx"
+	"github.com/jswidler/gorun/errors"
+	"github.com/jswidler/gorun/gorundb"
+	"github.com/jswidler/gorun/logger"
+	"github.com/jswidler/gorun/tenantctx"
+	"github.com/jswidler/gorun/ulid"
+)
+
+func (s *Store) AcquireJobsToRun(ctx context.Context, jobLimit int) ([]*gorundb.JobData, error) {
+	var jobs []*gorundb.JobData
+	err := s.useTx(ctx, func(ctx context.Context) error {
+		var err error
+		jobs, err = queryMany[gorundb.JobData](ctx, s.conn(ctx), `SELECT * FROM gorun_job_data WHERE status = 'scheduled' AND run_at < ?
+			AND type NOT IN (SELECT job_type FROM gorun_paused_job_type) ORDER BY run_at LIMIT ?`+s.dialect.skipLocked,
+			time.Now(), jobLimit)
+		if err != nil {
+			return err
+		}
+		return s.setStatus(ctx, jobs, "running", nil)
+	})
+
+	if len(jobs) == jobLimit {
+		// Warn if we hit the limit
+		logger.Ctx(ctx).Warn().Msg("full batch of jobs acquired")
+	}
+
+	return jobs, err
+}
+
+func (s *Store) MarkIncompleteJobs(ctx context.Context, jobTimeout time.Duration) ([]*gorundb.JobData, error) {
+	var jobs []*gorundb.JobData
+	err := s.useTx(ctx, func(ctx context.Context) error {
+		var err error
+		jobs, err = queryMany[gorundb.JobData](ctx, s.conn(ctx), `SELECT * FROM gorun_job_data WHERE status = 'running' AND updated_at < ?`+s.dialect.forUpdate,
+			time.Now().Add(-jobTimeout))
+		if err != nil {
+			return err
+		}
+		result := "job timed out"
+		return s.setStatus(ctx, jobs, "failed", &result)
+	})
+	return jobs, err
+}
+
+// setStatus moves the jobs to the status, with the result if it is not nil.  The jobs must be locked by the
+// transaction.
+func (s *Store) setStatus(ctx context.Context, jobs []*gorundb.JobData, status string, result *string) error {
+	if len(jobs) == 0 {
+		return nil
+	}
+	now := time.Now().UTC()
+	ids := make([]string, len(jobs))
+	for i, job := range jobs {
+		ids[i] = job.Id
+		job.Status = status
+		job.UpdatedAt = now
+		if result != nil {
+			job.Result = result
+		}
+	}
+	query, args, err := sqlx.In(`UPDATE gorun_job_data SET status = ?, updated_at = ?, result = COALESCE(?, result) WHERE id IN (?)`,
+		status, now, result, ids)
+	if err != nil {
+		return errors.Wrap(gorundb.ErrDatabaseError, errors.WithCause(err))
+	}
+	_, err = s.exec(ctx, query, args...)
+	return err
+}
+
+func (s *Store) UpdateJob(ctx context.Context, job *gorundb.JobData) error {
+	return updateById(ctx, s, "gorun_job_data", job)
+}
+
+func (s *Store) InsertJobs(ctx context.Context, jobs []*gorundb.JobData) error {
+	logger.Ctx(ctx).Info().Int("jobCount", len(jobs)).Msg("inserting jobs")
+	return s.useTx(ctx, func(ctx context.Context) error {
+		return insertRows(ctx, s, "gorun_job_data", jobs)
+	})
+}
+
+func (s *Store) GetJobById(ctx context.Context, jobId string) (*gorundb.JobData, error) {
+	return byId[gorundb.JobData](ctx, s.conn(ctx), "gorun_job_data", jobId)
+}
+
+func (s *Store) ListJobs(ctx context.Context, startTime, endTime time.Time) ([]*gorundb.JobData, error) {
+	tenantId := tenantctx.GetTenant(ctx)
+	if tenantId == "" {
+		return queryMany[gorundb.JobData](ctx, s.conn(ctx), `SELECT * FROM gorun_job_data WHERE run_at >= ? AND run_at < ?`, startTime, endTime)
+	}
+	return queryMany[gorundb.JobData](ctx, s.conn(ctx), `SELECT * FROM gorun_job_data WHERE tenant_id = ? AND run_at >= ? AND run_at < ?`, tenantId, startTime, endTime)
+}
+
+func (s *Store) ListScheduledJobsForTrigger(ctx context.Context, triggerId string) ([]*gorundb.JobData, error) {
+	return queryMany[gorundb.JobData](ctx, s.conn(ctx), `SELECT * FROM gorun_job_data WHERE trigger_id = ? AND status = 'scheduled' ORDER BY run_at`, triggerId)
+}
+
+// deleteScheduledJobs deletes the jobs of the trigger that have not started, and returns how many there were.
+func (s *Store) deleteScheduledJobs(ctx context.Context, triggerId string) (int, error) {
+	n, err := s.exec(ctx, `DELETE FROM gorun_job_data WHERE trigger_id = ? AND status = 'scheduled'`, triggerId)
+	return int(n), err
+}
+
+func (s *Store) DebounceJob(ctx context.Context, job *gorundb.JobData) (string, bool, error) {
+	var jobId string
+	created := false
+	err := s.useTx(ctx, func(ctx context.Context) error {
+		pending, err := s.pendingDedupJob(ctx, job)
+		if err != nil {
+			return err
+		}
+		if pending != nil {
+			moved, err := s.exec(ctx, `UPDATE gorun_job_data SET run_at = ?, args = ?, updated_at = ? WHERE id = ? AND status = 'scheduled'`,
+				job.RunAt, job.Args, time.Now(), pending.Id)
+			if err != nil || moved == 1 {
+				jobId = pending.Id
+				return err
+			}
+		}
+		jobId, created = job.Id, true
+		return s.InsertJobs(ctx, []*gorundb.JobData{job})
+	})
+	return jobId, created, err
+}
+
+func (s *Store) ThrottleJob(ctx context.Context, job *gorundb.JobData, window time.Duration) (string, bool, error) {
+	var jobId string
+	created := false
+	err := s.useTx(ctx, func(ctx context.Context) error {
+		pending, err := s.pendingDedupJob(ctx, job)
+		if err != nil {
+			return err
+		}
+		if pending != nil {
+			updated, err := s.exec(ctx, `UPDATE gorun_job_data SET args = ?, updated_at = ? WHERE id = ? AND status = 'scheduled'`,
+				job.Args, time.Now(), pending.Id)
+			if err != nil || updated == 1 {
+				jobId = pending.Id
+				return err
+			}
+		}
+		last, err := queryOne[gorundb.JobData](ctx, s.conn(ctx), `SELECT * FROM gorun_job_data WHERE dedup_key = ? AND tenant_id `+s.dialect.nullSafeEq+` ? ORDER BY run_at DESC LIMIT 1`+s.dialect.forUpdate,
+			job.DedupKey, job.TenantId)
+		if err != nil && !errors.Is(err, gorundb.ErrNotFound) {
+			return err
+		}
+		if last != nil && last.RunAt.Add(window).After(job.RunAt) {
+			job.RunAt = last.RunAt.Add(window)
+		}
+		jobId, created = job.Id, true
+		return s.InsertJobs(ctx, []*gorundb.JobData{job})
+	})
+	return jobId, created, err
+}
+
+// pendingDedupJob holds a lock on the dedup key of the job until the transaction ends, and returns the next job with
+// the key that has not started, if there is one.
+func (s *Store) pendingDedupJob(ctx context.Context, job *gorundb.JobData) (*gorundb.JobData, error) {
+	if job.DedupKey == nil {
+		return nil, errors.New("job does not have a dedup key")
+	}
+	if s.dialect.lockKey != "" {
+		lockKey := *job.DedupKey
+		if job.TenantId != nil {
+			lockKey = *job.TenantId + ":" + lockKey
+		}
+		_, err := s.exec(ctx, s.dialect.lockKey, lockKey)
+		if err != nil {
+			return nil, err
+		}
+	}
+	pending, err := queryOne[gorundb.JobData](ctx, s.conn(ctx), `SELECT * FROM gorun_job_data WHERE dedup_key = ? AND tenant_id `+s.dialect.nullSafeEq+` ? AND status = 'scheduled' ORDER BY run_at LIMIT 1`+s.dialect.forUpdate,
+		job.DedupKey, job.TenantId)
+	if errors.Is(err, gorundb.ErrNotFound) {
+		return nil, nil
+	}
+	return pending, err
+}
+
+func (s *Store) GetTriggerById(ctx context.Context, triggerId string) (*gorundb.JobTrigger, error) {
+	return byId[gorundb.JobTrigger](ctx, s.conn(ctx), "gorun_trigger", triggerId)
+}
+
+func (s *Store) ListTriggers(ctx context.Context) ([]*gorundb.JobTrigger, error) {
+	tenantId := tenantctx.GetTenant(ctx)
+	if tenantId != "" {
+		return queryMany[gorundb.JobTrigger](ctx, s.conn(ctx), `SELECT * FROM gorun_trigger WHERE tenant_id = ?`, tenantId)
+	}
+	return queryMany[gorundb.JobTrigger](ctx, s.conn(ctx), `SELECT * FROM gorun_trigger`)
+}
+
+func (s *Store) GetTriggersToUpdate(ctx context.Context, t time.Time) ([]*gorundb.JobTrigger, error) {
+	return queryMany[gorundb.JobTrigger](ctx, s.conn(ctx), `SELECT * FROM gorun_trigger WHERE scheduled_until < ? AND finished_at IS NULL AND paused_at IS NULL AND event IS NULL`, t)
+}
+
+func (s *Store) ListEventTriggers(ctx context.Context, event string) ([]*gorundb.JobTrigger, error) {
+	tenantId := tenantctx.GetTenant(ctx)
+	if tenantId != "" {
+		return queryMany[gorundb.JobTrigger](ctx, s.conn(ctx), `SELECT * FROM gorun_trigger WHERE tenant_id = ? AND event = ? AND finished_at IS NULL AND paused_at IS NULL`, tenantId, event)
+	}
+	return queryMany[gorundb.JobTrigger](ctx, s.conn(ctx), `SELECT * FROM gorun_trigger WHERE event = ? AND finished_at IS NULL AND paused_at IS NULL`, event)
+}
+
+func (s *Store) MaybeUpsertTriggerWithJobs(ctx context.Context, jobTrigger *gorundb.JobTrigger, jobs []*gorundb.JobData) error {
+	return s.useTx(ctx, func(ctx context.Context) error {
+		trig, err := queryOne[gorundb.JobTrigger](ctx, s.conn(ctx), `SELECT * FROM gorun_trigger WHERE id = ?`+s.dialect.forUpdate, jobTrigger.Id)
+		if errors.Is(err, gorundb.ErrNotFound) {
+			logger.Ctx(ctx).Info().Str("triggerId", jobTrigger.Id).
+				Str("jobType", jobTrigger.JobType).
+				Str("triggerType", jobTrigger.TriggerType).
+				Msg("inserting job trigger")
+			err = insertRows(ctx, s, "gorun_trigger", []*gorundb.JobTrigger{jobTrigger})
+			if err != nil {
+				return err
+			}
+			return s.InsertJobs(ctx, jobs)
+		} else if err != nil {
+			return err
+		}
+
+		if trig.SameDefinition(jobTrigger) {
+			return nil
+		}
+		// Changing a paused trigger does not resume it.
+		jobTrigger.PausedAt = trig.PausedAt
+		jobTrigger.Version = trig.Version + 1
+		if trig.SameSchedule(jobTrigger) {
+			// The schedule is the same, so keep its phase.  The jobs already scheduled keep their run times, with the new
+			// arguments.
+			_, err = s.exec(ctx, `UPDATE gorun_job_data SET args = ?, updated_at = ? WHERE trigger_id = ? AND status = 'scheduled'`,
+				jobTrigger.JobArgs, time.Now(), jobTrigger.Id)
+			if err != nil {
+				return err
+			}
+			jobTrigger.ScheduledUntil = trig.ScheduledUntil
+			jobTrigger.RunCount = trig.RunCount
+			jobTrigger.FinishedAt = trig.FinishedAt
+			jobs = nil
+		} else {
+			replaced, err := s.deleteScheduledJobs(ctx, jobTrigger.Id)
+			if err != nil {
+				return err
+			}
+			if trig.PausedAt != nil {
+				// The first job is scheduled when the trigger is resumed.
+				jobs = nil
+			}
+			jobTrigger.RunCount = max(trig.RunCount-replaced, 0) + len(jobs)
+		}
+		err = s.insertTriggerAudit(ctx, trig, jobTrigger.Version)
+		if err != nil {
+			return err
+		}
+		err = updateById(ctx, s, "gorun_trigger", jobTrigger)
+		if err != nil {
+			return err
+		}
+		return s.InsertJobs(ctx, jobs)
+	})
+}
+
+func (s *Store) UpdateTrigger(ctx context.Context, jobTrigger *gorundb.JobTrigger, prev *gorundb.JobTrigger, jobs []*gorundb.JobData) error {
+	return s.useTx(ctx, func(ctx context.Context) error {
+		n, err := s.exec(ctx, `UPDATE gorun_trigger SET version = version + 1 WHERE id = ? AND version = ? AND scheduled_until = ?`,
+			prev.Id, prev.Version, prev.ScheduledUntil)
+		if err != nil {
+			return err
+		} else if n != 1 {
+			return errors.Wrap(gorundb.ErrConflict, errors.WithMessagef("trigger %s was changed by another process", prev.Id))
+		}
+
+		replaced, err := s.deleteScheduledJobs(ctx, prev.Id)
+		if err != nil {
+			return err
+		}
+
+		jobTrigger.Version = prev.Version + 1
+		jobTrigger.RunCount = max(prev.RunCount-replaced, 0) + len(jobs)
+		err = s.insertTriggerAudit(ctx, prev, jobTrigger.Version)
+		if err != nil {
+			return err
+		}
+		err = updateById(ctx, s, "gorun_trigger", jobTrigger)
+		if err != nil {
+			return err
+		}
+		return s.InsertJobs(ctx, jobs)
+	})
+}
+
+func (s *Store) insertTriggerAudit(ctx context.Context, prev *gorundb.JobTrigger, version int) error {
+	previous, err := json.Marshal(prev)
+	if err != nil {
+		return errors.Wrap(err)
+	}
+	return insertRows(ctx, s, "gorun_trigger_audit", []*gorundb.TriggerAudit{{
+		Id:        ulid.New(),
+		TenantId:  prev.TenantId,
+		TriggerId: prev.Id,
+		Version:   version,
+		Previous:  string(previous),
+	}})
+}
+
+func (s *Store) ListTriggerAudits(ctx context.Context, triggerId string) ([]*gorundb.TriggerAudit, error) {
+	tenantId := tenantctx.GetTenant(ctx)
+	if tenantId != "" {
+		return queryMany[gorundb.TriggerAudit](ctx, s.conn(ctx), `SELECT * FROM gorun_trigger_audit WHERE tenant_id = ? AND trigger_id = ? ORDER BY version`, tenantId, triggerId)
+	}
+	return queryMany[gorundb.TriggerAudit](ctx, s.conn(ctx), `SELECT * FROM gorun_trigger_audit WHERE trigger_id = ? ORDER BY version`, triggerId)
+}
+
+// ScheduleNewJobsFromTrigger saves the progress of the trigger along with its new jobs.  A trigger that has finished
+// and is set to be deleted when finished is deleted, but its jobs are left to run.
+func (s *Store) ScheduleNewJobsFromTrigger(ctx context.Context, jobTrigger *gorundb.JobTrigger, prevScheduleUntil time.Time, jobs []*gorundb.JobData) error {
+	return s.useTx(ctx, func(ctx context.Context) error {
+		n, err := s.exec(ctx, `UPDATE gorun_trigger SET scheduled_until = ?, run_count = ?, finished_at = ?, updated_at = ? WHERE id = ? AND scheduled_until = ? AND paused_at IS NULL`,
+			jobTrigger.ScheduledUntil, jobTrigger.RunCount, jobTrigger.FinishedAt, time.Now(), jobTrigger.Id, prevScheduleUntil)
+		if err != nil {
+			return err
+		} else if n != 1 {
+			return errors.Wrap(gorundb.ErrConflict, errors.WithMessage("trigger was updated or paused by another process"))
+		}
+		err = s.InsertJobs(ctx, jobs)
+		if err != nil {
+			return err
+		}
+		if jobTrigger.FinishedAt != nil && jobTrigger.DeleteWhenFinished {
+			_, err = s.exec(ctx, `DELETE FROM gorun_trigger WHERE id = ?`, jobTrigger.Id)
+		}
+		return err
+	})
+}
+
+// WithTriggerLock calls fn with the trigger in a transaction that holds a lock on it, so that other processes wait to
+// change the trigger until fn returns.
+func (s *Store) WithTriggerLock(ctx context.Context, triggerId string, fn func(ctx context.Context, trigger *gorundb.JobTrigger) error) error {
+	return s.useTx(ctx, func(ctx context.Context) error {
+		trigger, err := queryOne[gorundb.JobTrigger](ctx, s.conn(ctx), `SELECT * FROM gorun_trigger WHERE id = ?`+s.dialect.forUpdate, triggerId)
+		if err != nil {
+			return err
+		}
+		return fn(ctx, trigger)
+	})
+}
+
+func (s *Store) DeleteTriggerById(ctx context.Context, triggerId string) error {
+	return s.useTx(ctx, func(ctx context.Context) error {
+		// Attempt to stop any jobs from running that are scheduled by the trigger, but have run yet.
+		_, err := s.deleteScheduledJobs(ctx, triggerId)
+		if err != nil {
+			return err
+		}
+		_, err = s.exec(ctx, `DELETE FROM gorun_trigger WHERE id = ?`, triggerId)
+		return err
+	})
+}
+
+// PauseTrigger stops a trigger from scheduling jobs and removes the jobs it has scheduled that have not started.  The
+// removed jobs no longer count towards the run count of the trigger.
+func (s *Store) PauseTrigger(ctx context.Context, triggerId string) error {
+	return s.useTx(ctx, func(ctx context.Context) error {
+		removed, err := s.deleteScheduledJobs(ctx, triggerId)
+		if err != nil {
+			return err
+		}
+		now := time.Now()
+		_, err = s.exec(ctx, `UPDATE gorun_trigger SET paused_at = COALESCE(paused_at, ?), run_count = CASE WHEN run_count > ? THEN run_count - ? ELSE 0 END, updated_at = ? WHERE id = ?`,
+			now, removed, removed, now, triggerId)
+		return err
+	})
+}
+
+// ResumeTrigger lets a paused trigger schedule jobs again, starting from scheduledUntil.
+func (s *Store) ResumeTrigger(ctx context.Context, triggerId string, scheduledUntil time.Time) error {
+	_, err := s.exec(ctx, `UPDATE gorun_trigger SET paused_at = NULL, scheduled_until = ?, updated_at = ? WHERE id = ? AND paused_at IS NOT NULL`,
+		scheduledUntil, time.Now(), triggerId)
+	return err
+}
+
+// PauseJobType stops jobs of the type from being acquired to run until the type is resumed.
+func (s *Store) PauseJobType(ctx context.Context, jobType string) error {
+	logger.Ctx(ctx).Info().Str("jobType", jobType).Msg("pausing job type")
+	_, err := s.exec(ctx, s.dialect.insertIgnore+` INTO gorun_paused_job_type (job_type, paused_at) VALUES (?, ?)`, jobType, time.Now())
+	return err
+}
+
+func (s *Store) ResumeJobType(ctx context.Context, jobType string) error {
+	logger.Ctx(ctx).Info().Str("jobType", jobType).Msg("resuming job type")
+	_, err := s.exec(ctx, `DELETE FROM gorun_paused_job_type WHERE job_type = ?`, jobType)
+	return err
+}
+
+func (s *Store) ListPausedJobTypes(ctx context.Context) ([]string, error) {
+	var jobTypes []string
+	err := sqlx.SelectContext(ctx, s.conn(ctx), &jobTypes, `SELECT job_type FROM gorun_paused_job_type ORDER BY job_type`)
+	if err != nil {
+		return nil, errors.Wrap(gorundb.ErrDatabaseError, errors.WithCause(err))
+	}
+	return jobTypes, nil
+}
@@ -0,0 +1,111 @@
+package sqlstore
+
+import (
+	"context"
+	"strings"
+	"time"
+
+	"github.com/jswidler/gorun/gorundb"
+	"github.com/jswidler/gorun/tenantctx"
+)
+
+func (s *Store) QueryJobs(ctx context.Context, query gorundb.JobQuery) (*gorundb.JobPage, error) {
+	query, err := query.Normalize()
+	if err != nil {
+		return nil, err
+	}
+
+	var where []string
+	var args []any
+	filter := func(cond string, arg ...any) {
+		where = append(where, cond)
+		args = append(args, arg...)
+	}
+	in := func(column string, values []string) {
+		filter(column+" IN ("+placeholders(len(values))+")", anySlice(values)...)
+	}
+	between := func(column string, from, until time.Time) {
+		if !from.IsZero() {
+			filter(column+" >= ?", from)
+		}
+		if !until.IsZero() {
+			filter(column+" < ?", until)
+		}
+	}
+	if tenantId := tenantctx.GetTenant(ctx); tenantId != "" {
+		filter("tenant_id = ?", tenantId)
+	}
+	if query.TenantId != "" {
+		filter("tenant_id = ?", query.TenantId)
+	}
+	if len(query.Statuses) > 0 {
+		in("status", query.Statuses)
+	}
+	if len(query.Types) > 0 {
+		in("type", query.Types)
+	}
+	if query.TriggerId != "" {
+		filter("trigger_id = ?", query.TriggerId)
+	}
+	between("created_at", query.CreatedFrom, query.CreatedUntil)
+	between("updated_at", query.UpdatedFrom, query.UpdatedUntil)
+	between("run_at", query.RunFrom, query.RunUntil)
+	if query.ResultContains != "" {
+		filter("LOWER(result) LIKE ? ESCAPE '!'", "%"+gorundb.EscapeLike(strings.ToLower(query.ResultContains))+"%")
+	}
+
+	page := &gorundb.JobPage{}
+	err = s.useTx(ctx, func(ctx context.Context) error {
+		total, err := queryOne[int](ctx, s.conn(ctx), sqlWhere(`SELECT COUNT(*) FROM gorun_job_data`, where, ""), args...)
+		if err != nil {
+			return err
+		}
+		page.Total = *total
+
+		column, cmp, dir := string(query.OrderBy), ">", "ASC"
+		if query.Descending {
+			cmp, dir = "<", "DESC"
+		}
+		if query.After != "" {
+			value, id, err := query.ParseCursor()
+			if err != nil {
+				return err
+			}
+			if query.OrderBy == gorundb.OrderById {
+				filter("id "+cmp+" ?", id)
+			} else {
+				filter("("+column+" "+cmp+" ? OR ("+column+" = ? AND id "+cmp+" ?))", value, value, id)
+			}
+		}
+		order := " ORDER BY id " + dir
+		if query.OrderBy != gorundb.OrderById {
+			order = " ORDER BY " + column + " " + dir + ", id " + dir
+		}
+		page.Jobs, err = queryMany[gorundb.JobData](ctx, s.conn(ctx), sqlWhere(`SELECT * FROM gorun_job_data`, where, order+" LIMIT ?"), append(args, query.Limit+1)...)
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+	if len(page.Jobs) > query.Limit {
+		page.Jobs = page.Jobs[:query.Limit]
+		page.NextCursor = query.Cursor(page.Jobs[query.Limit-1])
+	}
+	return page, nil
+}
+
+// sqlWhere returns the statement with the conditions and suffix.
+func sqlWhere(statement string, where []string, suffix string) string {
+	if len(where) > 0 {
+		statement += " WHERE " + strings.Join(where, " AND ")
+	}
+	return statement + suffix
+}
+
+func anySlice(values []string) []any {
+	args := make([]any, len(values))
+	for i, v := range values {
+		args[i] = v
+	}
+	return args
+}
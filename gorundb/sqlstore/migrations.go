@@ -0,0 +1,61 @@
+package sqlstore
+
+import (
+	"embed"
+	"io/fs"
+	"net/http"
+
+	"github.com/jswidler/gorun/errors"
+	"github.com/jswidler/gorun/gorundb"
+	"github.com/jswidler/gorun/logger"
+	migrate "github.com/rubenv/sql-migrate"
+)
+
+// Each dialect has its own migrations, since the column types differ between databases.
+//
+//go:embed migrations/*
+var migrationFiles embed.FS
+
+// MigrateUp migrates the database to the latest version
+func (s *Store) MigrateUp() error {
+	logger.Default().Info().Msg("checking database is up to date")
+	n, err := migrate.Exec(s.db.DB, s.dialect.migrateDialect, s.migrations(), migrate.Up)
+	if err != nil {
+		return errors.Wrap(gorundb.ErrDbMigrationFailed, errors.WithCause(err))
+	}
+
+	if n > 0 {
+		logger.Default().Info().Int("numMigrations", n).Msgf("applied %d migrations", n)
+	} else {
+		logger.Default().Info().Msg("database was up to date")
+	}
+
+	return nil
+}
+
+// MigrateDown will roll back the database at most `max` migrations. USE CAREFULLY!!! Pass 0 for no limit.
+func (s *Store) MigrateDown(max int) error {
+	logger.Default().Info().Msg("rolling back a database migration")
+
+	n, err := migrate.ExecMax(s.db.DB, s.dialect.migrateDialect, s.migrations(), migrate.Down, max)
+	if err != nil {
+		return errors.Wrap(gorundb.ErrDbMigrationFailed, errors.WithCause(err))
+	}
+	if n > 0 {
+		logger.Default().Info().Msgf("rolled back %d migrations", n)
+	} else {
+		logger.Default().Info().Msg("no migrations to roll back")
+	}
+
+	return nil
+}
+
+func (s *Store) migrations() *migrate.HttpFileSystemMigrationSource {
+	fsys, err := fs.Sub(migrationFiles, s.dialect.migrations)
+	if err != nil {
+		panic(err)
+	}
+	return &migrate.HttpFileSystemMigrationSource{
+		FileSystem: http.FS(fsys),
+	}
+}
@@ -0,0 +1,99 @@
+-- +migrate Up
+CREATE TABLE IF NOT EXISTS gorun_trigger (
+  id varchar(32) NOT NULL PRIMARY KEY,
+  tenant_id varchar(128),
+  created_at datetime(6) NOT NULL,
+  updated_at datetime(6) NOT NULL,
+
+  trigger_type varchar(32) NOT NULL,
+  trigger_data text NOT NULL,
+  scheduled_until datetime(6) NOT NULL,
+
+  job_type varchar(32) NOT NULL,
+  job_args json NOT NULL,
+
+  calendars json NOT NULL,
+
+  start_at datetime(6),
+  end_at datetime(6),
+  max_runs integer,
+  run_count integer NOT NULL DEFAULT 0,
+  finished_at datetime(6),
+  delete_when_finished boolean NOT NULL DEFAULT false,
+
+  paused_at datetime(6),
+
+  jitter bigint NOT NULL DEFAULT 0, -- nanoseconds
+  jitter_mode varchar(16) NOT NULL DEFAULT 'random',
+
+  event varchar(128),
+
+  version integer NOT NULL DEFAULT 1,
+
+  INDEX gorun_trigger_scheduled_until (scheduled_until),
+  INDEX gorun_trigger_event (event)
+);
+
+CREATE TABLE IF NOT EXISTS gorun_job_data (
+  id varchar(32) NOT NULL PRIMARY KEY,
+  tenant_id varchar(128),
+  created_at datetime(6) NOT NULL,
+  updated_at datetime(6) NOT NULL,
+  status varchar(32) NOT NULL,
+
+  trigger_id varchar(32),
+
+  run_at datetime(6) NOT NULL,
+
+  type varchar(32) NOT NULL,
+  args json NOT NULL,
+  result text,
+
+  dedup_key varchar(160),
+  upstream_job_id varchar(32),
+
+  INDEX gorun_job_data_status_run_at (status, run_at),
+  INDEX gorun_job_data_status_updated_at (status, updated_at),
+  INDEX gorun_job_data_trigger_id (trigger_id, status),
+  INDEX gorun_job_data_dedup_key_run_at (dedup_key, run_at)
+);
+
+CREATE TABLE IF NOT EXISTS gorun_calendar (
+  id varchar(128) NOT NULL PRIMARY KEY,
+  tenant_id varchar(128),
+  created_at datetime(6) NOT NULL,
+  updated_at datetime(6) NOT NULL,
+
+  calendar_data json NOT NULL
+);
+
+CREATE TABLE IF NOT EXISTS gorun_paused_job_type (
+  job_type varchar(32) NOT NULL PRIMARY KEY,
+  paused_at datetime(6) NOT NULL
+);
+
+CREATE TABLE IF NOT EXISTS gorun_trigger_audit (
+  id varchar(32) NOT NULL PRIMARY KEY,
+  tenant_id varchar(128),
+  created_at datetime(6) NOT NULL,
+
+  trigger_id varchar(32) NOT NULL,
+  version integer NOT NULL,
+  previous json NOT NULL,
+
+  INDEX gorun_trigger_audit_trigger_id (trigger_id, version)
+);
+
+-- Rows locked to serialize the jobs that are debounced or throttled with the same dedup key.
+CREATE TABLE IF NOT EXISTS gorun_lock (
+  lock_key varchar(300) NOT NULL PRIMARY KEY
+);
+
+-- +migrate Down
+
+DROP TABLE IF EXISTS gorun_lock;
+DROP TABLE IF EXISTS gorun_trigger_audit;
+DROP TABLE IF EXISTS gorun_paused_job_type;
+DROP TABLE IF EXISTS gorun_calendar;
+DROP TABLE IF EXISTS gorun_job_data;
+DROP TABLE IF EXISTS gorun_trigger;
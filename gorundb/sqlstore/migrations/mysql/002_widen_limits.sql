@@ -0,0 +1,36 @@
+-- +migrate Up
+ALTER TABLE gorun_trigger
+  MODIFY id varchar(128) NOT NULL,
+  MODIFY job_type varchar(255) NOT NULL,
+  MODIFY event varchar(300);
+
+ALTER TABLE gorun_job_data
+  MODIFY trigger_id varchar(128),
+  MODIFY type varchar(255) NOT NULL,
+  MODIFY result mediumtext,
+  MODIFY dedup_key varchar(400);
+
+ALTER TABLE gorun_paused_job_type
+  MODIFY job_type varchar(255) NOT NULL;
+
+ALTER TABLE gorun_trigger_audit
+  MODIFY trigger_id varchar(128) NOT NULL;
+
+-- +migrate Down
+
+ALTER TABLE gorun_trigger_audit
+  MODIFY trigger_id varchar(32) NOT NULL;
+
+ALTER TABLE gorun_paused_job_type
+  MODIFY job_type varchar(32) NOT NULL;
+
+ALTER TABLE gorun_job_data
+  MODIFY trigger_id varchar(32),
+  MODIFY type varchar(32) NOT NULL,
+  MODIFY result text,
+  MODIFY dedup_key varchar(160);
+
+ALTER TABLE gorun_trigger
+  MODIFY id varchar(32) NOT NULL,
+  MODIFY job_type varchar(32) NOT NULL,
+  MODIFY event varchar(128);
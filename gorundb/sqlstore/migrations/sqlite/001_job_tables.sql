@@ -0,0 +1,92 @@
+-- +migrate Up
+CREATE TABLE IF NOT EXISTS gorun_trigger (
+  id varchar(32) NOT NULL PRIMARY KEY,
+  tenant_id varchar(128),
+  created_at timestamp NOT NULL,
+  updated_at timestamp NOT NULL,
+
+  trigger_type varchar(32) NOT NULL,
+  trigger_data text NOT NULL,
+  scheduled_until timestamp NOT NULL,
+
+  job_type varchar(32) NOT NULL,
+  job_args text NOT NULL,
+
+  calendars text NOT NULL DEFAULT '[]',
+
+  start_at timestamp,
+  end_at timestamp,
+  max_runs integer,
+  run_count integer NOT NULL DEFAULT 0,
+  finished_at timestamp,
+  delete_when_finished boolean NOT NULL DEFAULT false,
+
+  paused_at timestamp,
+
+  jitter bigint NOT NULL DEFAULT 0, -- nanoseconds
+  jitter_mode varchar(16) NOT NULL DEFAULT 'random',
+
+  event varchar(128),
+
+  version integer NOT NULL DEFAULT 1
+);
+
+CREATE INDEX IF NOT EXISTS gorun_trigger_scheduled_until ON gorun_trigger (scheduled_until);
+CREATE INDEX IF NOT EXISTS gorun_trigger_event ON gorun_trigger (event);
+
+CREATE TABLE IF NOT EXISTS gorun_job_data (
+  id varchar(32) NOT NULL PRIMARY KEY,
+  tenant_id varchar(128),
+  created_at timestamp NOT NULL,
+  updated_at timestamp NOT NULL,
+  status varchar(32) NOT NULL,
+
+  trigger_id varchar(32),
+
+  run_at timestamp NOT NULL,
+
+  type varchar(32) NOT NULL,
+  args text NOT NULL,
+  result text,
+
+  dedup_key varchar(160),
+  upstream_job_id varchar(32)
+);
+
+CREATE INDEX IF NOT EXISTS gorun_job_data_status_run_at ON gorun_job_data (status, run_at);
+CREATE INDEX IF NOT EXISTS gorun_job_data_status_updated_at ON gorun_job_data (status, updated_at);
+CREATE INDEX IF NOT EXISTS gorun_job_data_dedup_key_run_at ON gorun_job_data (dedup_key, run_at);
+
+CREATE TABLE IF NOT EXISTS gorun_calendar (
+  id varchar(128) NOT NULL PRIMARY KEY,
+  tenant_id varchar(128),
+  created_at timestamp NOT NULL,
+  updated_at timestamp NOT NULL,
+
+  calendar_data text NOT NULL
+);
+
+CREATE TABLE IF NOT EXISTS gorun_paused_job_type (
+  job_type varchar(32) NOT NULL PRIMARY KEY,
+  paused_at timestamp NOT NULL
+);
+
+CREATE TABLE IF NOT EXISTS gorun_trigger_audit (
+  id varchar(32) NOT NULL PRIMARY KEY,
+  tenant_id varchar(128),
+  created_at timestamp NOT NULL,
+
+  trigger_id varchar(32) NOT NULL,
+  version integer NOT NULL,
+  previous text NOT NULL
+);
+
+CREATE INDEX IF NOT EXISTS gorun_trigger_audit_trigger_id ON gorun_trigger_audit (trigger_id, version);
+
+-- +migrate Down
+
+DROP TABLE IF EXISTS gorun_trigger_audit;
+DROP TABLE IF EXISTS gorun_paused_job_type;
+DROP TABLE IF EXISTS gorun_calendar;
+DROP TABLE IF EXISTS gorun_job_data;
+DROP TABLE IF EXISTS gorun_trigger;
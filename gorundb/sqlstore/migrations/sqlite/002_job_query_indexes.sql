@@ -0,0 +1,10 @@
+-- +migrate Up
+CREATE INDEX IF NOT EXISTS gorun_job_data_run_at ON gorun_job_data (run_at, id);
+CREATE INDEX IF NOT EXISTS gorun_job_data_tenant_id ON gorun_job_data (tenant_id, id);
+CREATE INDEX IF NOT EXISTS gorun_job_data_type ON gorun_job_data (type, id);
+
+-- +migrate Down
+
+DROP INDEX IF EXISTS gorun_job_data_type;
+DROP INDEX IF EXISTS gorun_job_data_tenant_id;
+DROP INDEX IF EXISTS gorun_job_data_run_at;
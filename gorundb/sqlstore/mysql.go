@@ -0,0 +1,62 @@
+package sqlstore
+
+import (
+	"database/sql"
+	"net"
+	"time"
+
+	"github.com/go-sql-driver/mysql"
+	"github.com/jswidler/gorun/errors"
+	"github.com/jswidler/gorun/gorundb"
+	"github.com/jswidler/gorun/logger"
+)
+
+const duplicateEntryErr = 1062
+
+// OpenMySQL connects to the MySQL or MariaDB database and migrates it to the latest version.  Times are read and
+// written in UTC, and updates report the rows they match rather than the rows they change, which the store relies on.
+func OpenMySQL(cfg *mysql.Config) (*Store, error) {
+	cfg = cfg.Clone()
+	cfg.ParseTime = true
+	cfg.ClientFoundRows = true
+	cfg.Loc = time.UTC
+
+	logger.Default().Info().
+		Str("dbHost", cfg.Addr).
+		Str("dbName", cfg.DBName).
+		Msg("connecting to mysql")
+	connector, err := mysql.NewConnector(cfg)
+	if err != nil {
+		return nil, errors.Wrap(err, errors.WithMessage("failed to connect to mysql"))
+	}
+	return NewMySQL(sql.OpenDB(connector))
+}
+
+// NewMySQL returns a store that uses the MySQL or MariaDB database, after migrating it to the latest version.  The
+// database must be opened with the parseTime and clientFoundRows options, as OpenMySQL does.  MySQL 8.0.1 or MariaDB
+// 10.6 is needed to acquire jobs with SKIP LOCKED.
+func NewMySQL(db *sql.DB) (*Store, error) {
+	return newStore(db, mysqlDialect)
+}
+
+// MySQLConfig returns the MySQL connection settings from the database config.
+func MySQLConfig(c gorundb.DatabaseConfig) *mysql.Config {
+	cfg := mysql.NewConfig()
+	cfg.User = c.User
+	cfg.Passwd = c.Password
+	cfg.Net = "tcp"
+	port := c.Port
+	if port == "" {
+		port = "3306"
+	}
+	cfg.Addr = net.JoinHostPort(c.Host, port)
+	cfg.DBName = c.DatabaseName
+	cfg.Timeout = 10 * time.Second
+	return cfg
+}
+
+func isMySQLConflict(err error) bool {
+	var mysqlErr *mysql.MySQLError
+	ok := errors.As(err, &mysqlErr)
+	return ok && mysqlErr.Number == duplicateEntryErr
+}
@@ -0,0 +1,18 @@
+package sqlstore_test
+
+import (
+	"testing"
+
+	"github.com/jswidler/gorun/gorundb"
+	"github.com/jswidler/gorun/gorundb/sqlstore"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestMySQLConfig(t *testing.T) {
+	cfg := sqlstore.MySQLConfig(gorundb.DatabaseConfig{Driver: gorundb.DriverMySQL, User: "gorun", Host: "db", DatabaseName: "jobs"})
+	assert.Equal(t, "db:3306", cfg.Addr)
+	assert.Equal(t, "gorun@tcp(db:3306)/jobs?timeout=10s", cfg.FormatDSN())
+
+	cfg = sqlstore.MySQLConfig(gorundb.DatabaseConfig{Driver: gorundb.DriverMySQL, Host: "db", Port: "3307"})
+	assert.Equal(t, "db:3307", cfg.Addr)
+}
@@ -0,0 +1,44 @@
+package sqlstore
+
+import (
+	"database/sql"
+	"net/url"
+
+	"github.com/jswidler/gorun/errors"
+	"github.com/jswidler/gorun/logger"
+	"github.com/mattn/go-sqlite3"
+)
+
+// OpenSQLite opens the SQLite database file at path, creating it if it does not exist, and migrates it to the latest
+// version.
+func OpenSQLite(path string) (*Store, error) {
+	logger.Default().Info().Str("dbPath", path).Msg("opening sqlite database")
+	db, err := sql.Open(sqliteDialect.driver, SQLiteDSN(path))
+	if err != nil {
+		return nil, errors.Wrap(err, errors.WithMessage("failed to open sqlite database"))
+	}
+	return NewSQLite(db)
+}
+
+// SQLiteDSN returns the data source name to open the SQLite database file at path with.  Transactions take the write
+// lock when they begin, so that two processes cannot both read a row and then fail to update it, and wait up to five
+// seconds for another process to release the lock.
+func SQLiteDSN(path string) string {
+	params := url.Values{}
+	params.Set("_txlock", "immediate")
+	params.Set("_busy_timeout", "5000")
+	params.Set("_journal_mode", "WAL")
+	return "file:" + path + "?" + params.Encode()
+}
+
+// NewSQLite returns a store that uses the SQLite database, after migrating it to the latest version.  The database
+// should be opened with the data source name from SQLiteDSN.
+func NewSQLite(db *sql.DB) (*Store, error) {
+	return newStore(db, sqliteDialect)
+}
+
+func isSQLiteConflict(err error) bool {
+	var sqliteErr sqlite3.Error
+	ok := errors.As(err, &sqliteErr)
+	return ok && (sqliteErr.ExtendedCode == sqlite3.ErrConstraintPrimaryKey || sqliteErr.ExtendedCode == sqlite3.ErrConstraintUnique)
+}
@@ -0,0 +1,275 @@
+package sqlstore_test
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/go-sql-driver/mysql"
+	"github.com/jswidler/gorun/gorundb"
+	"github.com/jswidler/gorun/gorundb/sqlstore"
+	"github.com/jswidler/gorun/tenantctx"
+	"github.com/stretchr/testify/assert"
+)
+
+// forEachStore runs the test against SQLite, and against MySQL when GORUN_TEST_MYSQL_DSN is set to the DSN of a
+// database the test can empty, such as "gorun:gorun@tcp(localhost:3306)/gorun_test".
+func forEachStore(t *testing.T, test func(t *testing.T, store *sqlstore.Store)) {
+	t.Run("sqlite", func(t *testing.T) {
+		test(t, openSQLite(t))
+	})
+	t.Run("mysql", func(t *testing.T) {
+		test(t, openMySQL(t))
+	})
+}
+
+func openMySQL(t *testing.T) *sqlstore.Store {
+	dsn := os.Getenv("GORUN_TEST_MYSQL_DSN")
+	if dsn == "" {
+		t.Skip("GORUN_TEST_MYSQL_DSN is not set")
+	}
+	cfg, err := mysql.ParseDSN(dsn)
+	if err != nil {
+		t.Fatal(err)
+	}
+	store, err := sqlstore.OpenMySQL(cfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { store.Close() })
+
+	// Every test starts with empty tables.
+	connector, err := mysql.NewConnector(cfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	db := sql.OpenDB(connector)
+	defer db.Close()
+	for _, table := range []string{"gorun_job_data", "gorun_trigger_audit", "gorun_trigger", "gorun_calendar", "gorun_paused_job_type", "gorun_lock"} {
+		if _, err := db.Exec("DELETE FROM " + table); err != nil {
+			t.Fatal(err)
+		}
+	}
+	return store
+}
+
+func openSQLite(t *testing.T) *sqlstore.Store {
+	store, err := sqlstore.OpenSQLite(filepath.Join(t.TempDir(), "gorun.db"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { store.Close() })
+	return store
+}
+
+func TestAcquireJobsToRun(t *testing.T) {
+	forEachStore(t, func(t *testing.T, store *sqlstore.Store) {
+		ctx := context.Background()
+		now := time.Now()
+		var jobs []*gorundb.JobData
+		for _, id := range []string{"j1", "j2", "j3", "j4"} {
+			jobs = append(jobs, &gorundb.JobData{Id: id, Status: "scheduled", Type: "test", Args: "{}", RunAt: now.Add(-time.Minute)})
+		}
+		jobs[3].RunAt = now.Add(time.Hour)
+		assert.NoError(t, store.InsertJobs(ctx, jobs))
+
+		// Every due job is acquired once, however many callers are acquiring jobs.
+		var mu sync.Mutex
+		acquired := map[string]int{}
+		var wg sync.WaitGroup
+		for i := 0; i < 4; i++ {
+			wg.Add(1)
+			go func() {
+				defer wg.Done()
+				jobs, err := store.AcquireJobsToRun(ctx, 2)
+				assert.NoError(t, err)
+				mu.Lock()
+				defer mu.Unlock()
+				for _, job := range jobs {
+					assert.Equal(t, "running", job.Status)
+					acquired[job.Id]++
+				}
+			}()
+		}
+		wg.Wait()
+		assert.Equal(t, map[string]int{"j1": 1, "j2": 1, "j3": 1}, acquired)
+
+		job, err := store.GetJobById(ctx, "j1")
+		assert.NoError(t, err)
+		assert.Equal(t, "running", job.Status)
+		assert.WithinDuration(t, now.Add(-time.Minute), job.RunAt, time.Millisecond)
+	})
+}
+
+func TestScheduleNewJobsFromTriggerConflicts(t *testing.T) {
+	forEachStore(t, func(t *testing.T, store *sqlstore.Store) {
+		ctx := context.Background()
+		scheduledUntil := time.Now()
+		trigger := &gorundb.JobTrigger{Id: "t1", TriggerType: "repeat", ScheduledUntil: scheduledUntil, JobArgs: "{}", JitterMode: "random", Version: 1}
+		err := store.MaybeUpsertTriggerWithJobs(ctx, trigger, nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+		saved, err := store.GetTriggerById(ctx, "t1")
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		next := *saved
+		next.ScheduledUntil = scheduledUntil.Add(time.Minute)
+		job := &gorundb.JobData{Id: "j1", Status: "scheduled", TriggerId: &trigger.Id, Args: "{}", RunAt: next.ScheduledUntil}
+		assert.NoError(t, store.ScheduleNewJobsFromTrigger(ctx, &next, saved.ScheduledUntil, []*gorundb.JobData{job}))
+
+		// A second process that read the trigger before the first saved its progress must not schedule the job again.
+		again := *saved
+		again.ScheduledUntil = scheduledUntil.Add(time.Minute)
+		job2 := &gorundb.JobData{Id: "j2", Status: "scheduled", TriggerId: &trigger.Id, Args: "{}", RunAt: again.ScheduledUntil}
+		err = store.ScheduleNewJobsFromTrigger(ctx, &again, saved.ScheduledUntil, []*gorundb.JobData{job2})
+		assert.ErrorIs(t, err, gorundb.ErrConflict)
+
+		jobs, err := store.ListScheduledJobsForTrigger(ctx, "t1")
+		assert.NoError(t, err)
+		assert.Len(t, jobs, 1)
+	})
+}
+
+func TestWithTriggerLockRollsBack(t *testing.T) {
+	forEachStore(t, func(t *testing.T, store *sqlstore.Store) {
+		ctx := context.Background()
+		err := store.MaybeUpsertTriggerWithJobs(ctx, &gorundb.JobTrigger{Id: "t1", TriggerType: "event", JobArgs: "{}", JitterMode: "random"}, nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+		err = store.WithTriggerLock(ctx, "t1", func(ctx context.Context, trigger *gorundb.JobTrigger) error {
+			err := store.InsertJobs(ctx, []*gorundb.JobData{{Id: "j1", Status: "scheduled", TriggerId: &trigger.Id, Args: "{}"}})
+			assert.NoError(t, err)
+			return gorundb.ErrDatabaseError
+		})
+		assert.ErrorIs(t, err, gorundb.ErrDatabaseError)
+		_, err = store.GetJobById(ctx, "j1")
+		assert.ErrorIs(t, err, gorundb.ErrNotFound)
+	})
+}
+
+func TestThrottleJob(t *testing.T) {
+	forEachStore(t, func(t *testing.T, store *sqlstore.Store) {
+		ctx := context.Background()
+		key := "key:test:k"
+		now := time.Now()
+		first := &gorundb.JobData{Id: "j1", Status: "completed", Args: "{}", RunAt: now, DedupKey: &key}
+		assert.NoError(t, store.InsertJobs(ctx, []*gorundb.JobData{first}))
+
+		jobId, created, err := store.ThrottleJob(ctx, &gorundb.JobData{Id: "j2", Status: "scheduled", Args: "{}", RunAt: now, DedupKey: &key}, time.Minute)
+		assert.NoError(t, err)
+		assert.True(t, created)
+		assert.Equal(t, "j2", jobId)
+		job, err := store.GetJobById(ctx, "j2")
+		assert.NoError(t, err)
+		assert.WithinDuration(t, now.Add(time.Minute), job.RunAt, time.Millisecond)
+
+		jobId, created, err = store.ThrottleJob(ctx, &gorundb.JobData{Id: "j3", Status: "scheduled", Args: `{"n":3}`, RunAt: now, DedupKey: &key}, time.Minute)
+		assert.NoError(t, err)
+		assert.False(t, created)
+		assert.Equal(t, "j2", jobId)
+		job, err = store.GetJobById(ctx, "j2")
+		assert.NoError(t, err)
+		assert.Equal(t, `{"n":3}`, job.Args)
+	})
+}
+
+func TestDebounceJobConcurrently(t *testing.T) {
+	forEachStore(t, func(t *testing.T, store *sqlstore.Store) {
+		ctx := context.Background()
+		key := "key:test:k"
+		// Only one job is scheduled for the key, however many callers debounce it at once.
+		var mu sync.Mutex
+		jobIds := map[string]int{}
+		var wg sync.WaitGroup
+		for i := 0; i < 4; i++ {
+			wg.Add(1)
+			go func() {
+				defer wg.Done()
+				job := &gorundb.JobData{Id: fmt.Sprintf("j%d", i), Status: "scheduled", Args: "{}", RunAt: time.Now().Add(time.Minute), DedupKey: &key}
+				jobId, _, err := store.DebounceJob(ctx, job)
+				assert.NoError(t, err)
+				mu.Lock()
+				defer mu.Unlock()
+				jobIds[jobId]++
+			}()
+		}
+		wg.Wait()
+		assert.Len(t, jobIds, 1)
+		page, err := store.QueryJobs(ctx, gorundb.JobQuery{Statuses: []string{"scheduled"}})
+		assert.NoError(t, err)
+		assert.Equal(t, 1, page.Total)
+	})
+}
+
+func TestInsertJobsConflict(t *testing.T) {
+	forEachStore(t, func(t *testing.T, store *sqlstore.Store) {
+		ctx := context.Background()
+		job := &gorundb.JobData{Id: "j1", Status: "scheduled", Args: "{}", RunAt: time.Now()}
+		assert.NoError(t, store.InsertJobs(ctx, []*gorundb.JobData{job}))
+		err := store.InsertJobs(ctx, []*gorundb.JobData{job})
+		assert.ErrorIs(t, err, gorundb.ErrConflict)
+	})
+}
+
+func TestCalendarsAreSeparateByTenant(t *testing.T) {
+	forEachStore(t, func(t *testing.T, store *sqlstore.Store) {
+		tenantA := tenantctx.WithTenant(context.Background(), "a")
+		tenantB := tenantctx.WithTenant(context.Background(), "b")
+		tenant := "a"
+		assert.NoError(t, store.UpsertCalendar(tenantA, &gorundb.JobCalendar{Id: "holidays", TenantId: &tenant, CalendarData: "{}"}))
+		assert.NoError(t, store.UpsertCalendar(tenantA, &gorundb.JobCalendar{Id: "holidays", TenantId: &tenant, CalendarData: `{"rule":"skip"}`}))
+
+		calendars, err := store.GetCalendarsByIds(tenantA, []string{"holidays", "other"})
+		assert.NoError(t, err)
+		if assert.Len(t, calendars, 1) {
+			assert.Equal(t, `{"rule":"skip"}`, calendars[0].CalendarData)
+		}
+		_, err = store.GetCalendarById(tenantB, "holidays")
+		assert.ErrorIs(t, err, gorundb.ErrNotFound)
+
+		// Another tenant can not replace the calendar.
+		other := "b"
+		err = store.UpsertCalendar(tenantB, &gorundb.JobCalendar{Id: "holidays", TenantId: &other, CalendarData: "{}"})
+		assert.ErrorIs(t, err, gorundb.ErrConflict)
+		err = store.UpsertCalendar(context.Background(), &gorundb.JobCalendar{Id: "holidays", CalendarData: "{}"})
+		assert.ErrorIs(t, err, gorundb.ErrConflict)
+		calendar, err := store.GetCalendarById(tenantA, "holidays")
+		assert.NoError(t, err)
+		assert.Equal(t, `{"rule":"skip"}`, calendar.CalendarData)
+		assert.Equal(t, &tenant, calendar.TenantId)
+	})
+}
+
+func TestInsertManyJobs(t *testing.T) {
+	forEachStore(t, func(t *testing.T, store *sqlstore.Store) {
+		ctx := context.Background()
+		now := time.Now()
+		// More jobs than fit in the parameters of one statement.
+		jobs := make([]*gorundb.JobData, 5000)
+		for i := range jobs {
+			jobs[i] = &gorundb.JobData{Id: fmt.Sprintf("j%d", i), Status: "scheduled", Type: "test", Args: "{}", RunAt: now}
+		}
+		assert.NoError(t, store.InsertJobs(ctx, jobs))
+		saved, err := store.ListJobs(ctx, now.Add(-time.Minute), now.Add(time.Minute))
+		assert.NoError(t, err)
+		assert.Len(t, saved, len(jobs))
+
+		// The jobs are saved together, so none are saved when one of them conflicts.
+		err = store.InsertJobs(ctx, []*gorundb.JobData{
+			{Id: "new", Status: "scheduled", Type: "test", Args: "{}", RunAt: now},
+			{Id: "j1", Status: "scheduled", Type: "test", Args: "{}", RunAt: now},
+		})
+		assert.ErrorIs(t, err, gorundb.ErrConflict)
+		_, err = store.GetJobById(ctx, "new")
+		assert.ErrorIs(t, err, gorundb.ErrNotFound)
+	})
+}
@@ -0,0 +1,93 @@
+// Package sqlstore keeps jobs and triggers in a SQL database other than Postgres.  It has the same semantics as the
+// Postgres store in gorundb, and the same tables.
+package sqlstore
+
+import (
+	"context"
+	"database/sql"
+
+	"github.com/jmoiron/sqlx"
+	"github.com/jswidler/gorun/errors"
+	"github.com/jswidler/gorun/gorundb"
+	"github.com/jswidler/gorun/logger"
+)
+
+// Store is a gorundb.Store backed by a SQL database.
+type Store struct {
+	db      *sqlx.DB
+	dialect dialect
+}
+
+// Verify Store satisfies the Store interface.
+var _ gorundb.Store = (*Store)(nil)
+
+func newStore(db *sql.DB, d dialect) (*Store, error) {
+	s := &Store{
+		db:      sqlx.NewDb(db, d.driver),
+		dialect: d,
+	}
+	return s, s.MigrateUp()
+}
+
+func (s *Store) Close() error {
+	return s.db.Close()
+}
+
+// querier runs statements either in a transaction or directly on the database.
+type querier interface {
+	sqlx.QueryerContext
+	sqlx.ExecerContext
+}
+
+// conn returns the transaction in the context, or the database if there is none.
+func (s *Store) conn(ctx context.Context) querier {
+	if tx := getTx(ctx); tx != nil {
+		return tx
+	}
+	return s.db
+}
+
+// useTx calls stmts in a transaction, which is committed if it returns no error.  Calls made in a transaction already
+// in the context are part of that transaction.
+func (s *Store) useTx(ctx context.Context, stmts func(ctx context.Context) error) (err error) {
+	if getTx(ctx) != nil {
+		return stmts(ctx)
+	}
+
+	tx, err := s.db.BeginTxx(ctx, nil)
+	if err != nil {
+		return errors.Wrap(gorundb.ErrDatabaseError, errors.WithCause(err))
+	}
+	ctx = setTx(ctx, tx)
+
+	defer func() {
+		if err == nil {
+			err = tx.Commit()
+			if err != nil {
+				err = errors.Wrap(gorundb.ErrDatabaseError, errors.WithCause(err))
+			}
+		} else {
+			err2 := tx.Rollback()
+			if err2 != nil {
+				logger.Ctx(ctx).Warn().
+					Err(errors.Wrap(gorundb.ErrDatabaseError, errors.WithCause(err2))).
+					Msg("db rollback failed")
+			}
+		}
+	}()
+
+	return stmts(ctx)
+}
+
+type txKeyType int
+
+const txKey txKeyType = iota
+
+func setTx(ctx context.Context, tx *sqlx.Tx) context.Context {
+	return context.WithValue(ctx, txKey, tx)
+}
+
+func getTx(ctx context.Context) *sqlx.Tx {
+	tx, _ := ctx.Value(txKey).(*sqlx.Tx)
+	return tx
+}
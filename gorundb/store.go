@@ -0,0 +1,74 @@
+package gorundb
+
+import (
+	"context"
+	"time"
+)
+
+// Store is the storage the job runner needs.  JobView is the Postgres implementation, and the sqlstore package has
+// implementations for other SQL databases.
+//
+// Methods that look up triggers, jobs and calendars only see those of the tenant in the context, if there is one.
+// Methods that change several rows make all the changes or none of them.
+type Store interface {
+	// AcquireJobsToRun marks up to jobLimit scheduled jobs that are due as running and returns them.  Jobs of paused
+	// types are not acquired, and no job is acquired by more than one caller.
+	AcquireJobsToRun(ctx context.Context, jobLimit int) ([]*JobData, error)
+	// MarkIncompleteJobs marks the jobs that have been running for longer than jobTimeout as failed and returns them.
+	MarkIncompleteJobs(ctx context.Context, jobTimeout time.Duration) ([]*JobData, error)
+	UpdateJob(ctx context.Context, job *JobData) error
+	InsertJobs(ctx context.Context, jobs []*JobData) error
+	GetJobById(ctx context.Context, jobId string) (*JobData, error)
+	ListJobs(ctx context.Context, startTime, endTime time.Time) ([]*JobData, error)
+	// QueryJobs returns a page of the jobs that match the query, or ErrInvalidQuery if the query is not valid.
+	QueryJobs(ctx context.Context, query JobQuery) (*JobPage, error)
+	// ListScheduledJobsForTrigger returns the jobs of a trigger that have not started running, in the order they will
+	// run.
+	ListScheduledJobsForTrigger(ctx context.Context, triggerId string) ([]*JobData, error)
+	// DebounceJob saves the job, unless a job with the same dedup key has not started, which is moved to the run time
+	// of job and given its arguments instead.  It returns the id of the job that will run, and whether it is new.
+	DebounceJob(ctx context.Context, job *JobData) (string, bool, error)
+	// ThrottleJob saves the job to run no sooner than window after the last job with the same dedup key, unless a job
+	// with the key has not started, which is given the arguments of job instead.  It returns the id of the job that
+	// will run, and whether it is new.
+	ThrottleJob(ctx context.Context, job *JobData, window time.Duration) (string, bool, error)
+
+	GetTriggerById(ctx context.Context, triggerId string) (*JobTrigger, error)
+	ListTriggers(ctx context.Context) ([]*JobTrigger, error)
+	// GetTriggersToUpdate returns the triggers that fire on a schedule, are not finished or paused, and are scheduled
+	// until before t.
+	GetTriggersToUpdate(ctx context.Context, t time.Time) ([]*JobTrigger, error)
+	// ListEventTriggers returns the triggers fired by the event that are not finished or paused.
+	ListEventTriggers(ctx context.Context, event string) ([]*JobTrigger, error)
+	// MaybeUpsertTriggerWithJobs saves a new trigger with its jobs, or replaces the definition of an existing trigger
+	// if it changed.
+	MaybeUpsertTriggerWithJobs(ctx context.Context, jobTrigger *JobTrigger, jobs []*JobData) error
+	// UpdateTrigger replaces the definition of a trigger and its jobs that have not started, provided it is still at
+	// prev.Version and prev.ScheduledUntil, otherwise it returns ErrConflict.
+	UpdateTrigger(ctx context.Context, jobTrigger *JobTrigger, prev *JobTrigger, jobs []*JobData) error
+	// ScheduleNewJobsFromTrigger saves the progress of the trigger with its new jobs, provided its scheduled_until is
+	// still prevScheduleUntil and it is not paused, otherwise it returns ErrConflict.
+	ScheduleNewJobsFromTrigger(ctx context.Context, jobTrigger *JobTrigger, prevScheduleUntil time.Time, jobs []*JobData) error
+	// WithTriggerLock calls fn with the trigger, while other callers wait to change it.  Calls to the store made with
+	// the context passed to fn are part of the same change.
+	WithTriggerLock(ctx context.Context, triggerId string, fn func(ctx context.Context, trigger *JobTrigger) error) error
+	DeleteTriggerById(ctx context.Context, triggerId string) error
+	ListTriggerAudits(ctx context.Context, triggerId string) ([]*TriggerAudit, error)
+
+	PauseTrigger(ctx context.Context, triggerId string) error
+	ResumeTrigger(ctx context.Context, triggerId string, scheduledUntil time.Time) error
+	PauseJobType(ctx context.Context, jobType string) error
+	ResumeJobType(ctx context.Context, jobType string) error
+	ListPausedJobTypes(ctx context.Context) ([]string, error)
+
+	// UpsertCalendar saves the calendar, or returns ErrConflict if a calendar with the same id belongs to another
+	// tenant.
+	UpsertCalendar(ctx context.Context, calendar *JobCalendar) error
+	GetCalendarById(ctx context.Context, calendarId string) (*JobCalendar, error)
+	GetCalendarsByIds(ctx context.Context, calendarIds []string) ([]*JobCalendar, error)
+	ListCalendars(ctx context.Context) ([]*JobCalendar, error)
+	DeleteCalendarById(ctx context.Context, calendarId string) error
+}
+
+// Verify JobView satisfies the Store interface.
+var _ Store = JobView{}
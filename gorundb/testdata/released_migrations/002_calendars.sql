@@ -0,0 +1,16 @@
+-- +migrate Up
+CREATE TABLE IF NOT EXISTS "gorun_calendar" (
+  "id" varchar(128) NOT NULL PRIMARY KEY,
+  "tenant_id" varchar(128),
+  "created_at" timestamp NOT NULL,
+  "updated_at" timestamp NOT NULL,
+
+  "calendar_data" jsonb NOT NULL -- {"rule": "skip", "dates": ["2024-12-25"]}
+);
+
+ALTER TABLE "gorun_trigger" ADD COLUMN IF NOT EXISTS "calendars" jsonb NOT NULL DEFAULT '[]';
+
+-- +migrate Down
+
+ALTER TABLE "gorun_trigger" DROP COLUMN IF EXISTS "calendars";
+DROP TABLE IF EXISTS "gorun_calendar";
@@ -0,0 +1,16 @@
+-- +migrate Up
+ALTER TABLE "gorun_trigger" ADD COLUMN IF NOT EXISTS "start_at" timestamp;
+ALTER TABLE "gorun_trigger" ADD COLUMN IF NOT EXISTS "end_at" timestamp;
+ALTER TABLE "gorun_trigger" ADD COLUMN IF NOT EXISTS "max_runs" integer;
+ALTER TABLE "gorun_trigger" ADD COLUMN IF NOT EXISTS "run_count" integer NOT NULL DEFAULT 0;
+ALTER TABLE "gorun_trigger" ADD COLUMN IF NOT EXISTS "finished_at" timestamp;
+ALTER TABLE "gorun_trigger" ADD COLUMN IF NOT EXISTS "delete_when_finished" boolean NOT NULL DEFAULT false;
+
+-- +migrate Down
+
+ALTER TABLE "gorun_trigger" DROP COLUMN IF EXISTS "delete_when_finished";
+ALTER TABLE "gorun_trigger" DROP COLUMN IF EXISTS "finished_at";
+ALTER TABLE "gorun_trigger" DROP COLUMN IF EXISTS "run_count";
+ALTER TABLE "gorun_trigger" DROP COLUMN IF EXISTS "max_runs";
+ALTER TABLE "gorun_trigger" DROP COLUMN IF EXISTS "end_at";
+ALTER TABLE "gorun_trigger" DROP COLUMN IF EXISTS "start_at";
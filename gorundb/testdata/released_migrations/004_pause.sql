@@ -0,0 +1,12 @@
+-- +migrate Up
+ALTER TABLE "gorun_trigger" ADD COLUMN IF NOT EXISTS "paused_at" timestamp;
+
+CREATE TABLE IF NOT EXISTS "gorun_paused_job_type" (
+  "job_type" varchar(32) NOT NULL PRIMARY KEY,
+  "paused_at" timestamp NOT NULL
+);
+
+-- +migrate Down
+
+DROP TABLE IF EXISTS "gorun_paused_job_type";
+ALTER TABLE "gorun_trigger" DROP COLUMN IF EXISTS "paused_at";
@@ -0,0 +1,19 @@
+-- +migrate Up
+ALTER TABLE "gorun_trigger" ADD COLUMN IF NOT EXISTS "version" integer NOT NULL DEFAULT 1;
+
+CREATE TABLE IF NOT EXISTS "gorun_trigger_audit" (
+  "id" varchar(32) NOT NULL PRIMARY KEY,
+  "tenant_id" varchar(128),
+  "created_at" timestamp NOT NULL,
+
+  "trigger_id" varchar(32) NOT NULL,
+  "version" integer NOT NULL, -- the version that replaced the previous definition
+  "previous" jsonb NOT NULL
+);
+
+CREATE INDEX IF NOT EXISTS "gorun_trigger_audit_trigger_id" ON "gorun_trigger_audit" ("trigger_id", "version");
+
+-- +migrate Down
+
+DROP TABLE IF EXISTS "gorun_trigger_audit";
+ALTER TABLE "gorun_trigger" DROP COLUMN IF EXISTS "version";
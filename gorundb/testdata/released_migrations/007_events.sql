@@ -0,0 +1,13 @@
+-- +migrate Up
+ALTER TABLE "gorun_trigger" ADD COLUMN IF NOT EXISTS "event" varchar(128);
+CREATE INDEX IF NOT EXISTS "gorun_trigger_event" ON "gorun_trigger" ("event");
+
+ALTER TABLE "gorun_job_data" ADD COLUMN IF NOT EXISTS "dedup_key" varchar(160);
+CREATE INDEX IF NOT EXISTS "gorun_job_data_dedup_key_run_at" ON "gorun_job_data" ("dedup_key", "run_at");
+
+-- +migrate Down
+
+DROP INDEX IF EXISTS "gorun_job_data_dedup_key_run_at";
+ALTER TABLE "gorun_job_data" DROP COLUMN IF EXISTS "dedup_key";
+DROP INDEX IF EXISTS "gorun_trigger_event";
+ALTER TABLE "gorun_trigger" DROP COLUMN IF EXISTS "event";
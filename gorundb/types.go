@@ -0,0 +1,59 @@
+package gorundb
+
+import (
+	"database/sql/driver"
+	"encoding/json"
+	"strings"
+
+	"github.com/jswidler/gorun/errors"
+)
+
+// StringList is a list of strings stored as a JSON array.
+type StringList []string
+
+func (l StringList) Value() (driver.Value, error) {
+	if l == nil {
+		return "[]", nil
+	}
+	data, err := json.Marshal([]string(l))
+	return string(data), errors.Wrap(err)
+}
+
+func (l *StringList) Scan(src any) error {
+	var data []byte
+	switch v := src.(type) {
+	case nil:
+		*l = nil
+		return nil
+	case string:
+		data = []byte(v)
+	case []byte:
+		data = v
+	default:
+		return errors.Newf("cannot scan %T into StringList", src)
+	}
+	return errors.Wrap(json.Unmarshal(data, (*[]string)(l)))
+}
+
+// textArray binds a list of strings as a Postgres array, which both lib/pq and pgx send as text.
+type textArray []string
+
+func (a textArray) Value() (driver.Value, error) {
+	var b strings.Builder
+	b.WriteByte('{')
+	for i, s := range a {
+		if i > 0 {
+			b.WriteByte(',')
+		}
+		b.WriteByte('"')
+		for _, r := range s {
+			if r == '"' || r == '\\' {
+				b.WriteByte('\\')
+			}
+			b.WriteRune(r)
+		}
+		b.WriteByte('"')
+	}
+	b.WriteByte('}')
+	return b.String(), nil
+}
@@ -0,0 +1,53 @@
+package triggers
+
+import (
+	"time"
+
+	"github.com/jswidler/gorun/errors"
+)
+
+// BoundedTrigger wraps a Trigger so that it only fires between StartAt and EndAt.  Either bound may be zero.  Like
+// CalendarTrigger, it is not saved, the bounds are applied again each time the trigger is loaded.
+type BoundedTrigger struct {
+	Trigger
+	StartAt time.Time
+	EndAt   time.Time
+}
+
+// Verify BoundedTrigger satisfies the Trigger interface.
+var _ Trigger = (*BoundedTrigger)(nil)
+
+// WithBounds returns the trigger limited to fire between startAt and endAt.
+func WithBounds(trigger Trigger, startAt, endAt time.Time) *BoundedTrigger {
+	return &BoundedTrigger{
+		Trigger: trigger,
+		StartAt: startAt,
+		EndAt:   endAt,
+	}
+}
+
+// NextFireTime returns the next time at which the wrapped Trigger is scheduled to fire within the bounds.  Before
+// StartAt, the trigger fires as though it had been scheduled at StartAt, so a repeat trigger first fires one interval
+// after it.  An InclusiveStarter, such as a cron or rrule trigger, first fires at or after it, like EndAt is inclusive.
+func (bt *BoundedTrigger) NextFireTime(prev time.Time) (time.Time, error) {
+	if prev.Before(bt.StartAt) {
+		prev = bt.StartAt
+		if inclusiveStart(bt.Trigger) {
+			// The search of the trigger starts after prev, so a fire time at StartAt would be skipped.
+			prev = prev.Add(-time.Nanosecond)
+		}
+	}
+	next, err := bt.Trigger.NextFireTime(prev)
+	if err != nil {
+		return next, err
+	}
+	if !bt.EndAt.IsZero() && next.After(bt.EndAt) {
+		return time.Time{}, errors.Wrap(ErrTriggerExpired, errors.WithMessagef("trigger ended at %s", bt.EndAt))
+	}
+	return next, nil
+}
+
+// InclusiveStart returns whether the wrapped trigger includes its start, so bounds can be nested.
+func (bt *BoundedTrigger) InclusiveStart() bool {
+	return inclusiveStart(bt.Trigger)
+}
@@ -0,0 +1,82 @@
+package triggers_test
+
+import (
+	"testing"
+	"time"
+
+	"github.com/jswidler/gorun/triggers"
+	"github.com/jswidler/gorun/triggers/crontrigger"
+	"github.com/jswidler/gorun/triggers/rruletrigger"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestBoundedTrigger(t *testing.T) {
+	start := time.Date(2024, 6, 1, 12, 0, 0, 0, time.UTC)
+	end := time.Date(2024, 6, 3, 12, 0, 0, 0, time.UTC)
+	prev := time.Date(2024, 5, 1, 0, 0, 0, 0, time.UTC)
+
+	cronTrigger, err := crontrigger.New("0 0 9 * * ?")
+	if err != nil {
+		t.Fatal(err)
+	}
+	fireTimes, err := triggers.NextFireTimes(triggers.WithBounds(cronTrigger, start, end), prev, 5)
+	assert.NoError(t, err)
+	assert.Equal(t, []time.Time{
+		time.Date(2024, 6, 2, 9, 0, 0, 0, time.UTC),
+		time.Date(2024, 6, 3, 9, 0, 0, 0, time.UTC),
+	}, fireTimes)
+
+	// A fire time at the start is included, like one at the end.
+	fireTimes, err = triggers.NextFireTimes(triggers.WithBounds(cronTrigger, time.Date(2024, 6, 1, 9, 0, 0, 0, time.UTC), end), prev, 5)
+	assert.NoError(t, err)
+	assert.Equal(t, []time.Time{
+		time.Date(2024, 6, 1, 9, 0, 0, 0, time.UTC),
+		time.Date(2024, 6, 2, 9, 0, 0, 0, time.UTC),
+		time.Date(2024, 6, 3, 9, 0, 0, 0, time.UTC),
+	}, fireTimes)
+
+	// Also for an rrule trigger, and through the calendars of a trigger.
+	startAt := time.Date(2024, 6, 1, 9, 0, 0, 0, time.UTC)
+	rruleTrigger, err := rruletrigger.New("DTSTART:20240101T090000Z\nRRULE:FREQ=DAILY")
+	if err != nil {
+		t.Fatal(err)
+	}
+	fireTimes, err = triggers.NextFireTimes(triggers.WithBounds(rruleTrigger, startAt, end), prev, 5)
+	assert.NoError(t, err)
+	assert.Equal(t, time.Date(2024, 6, 1, 9, 0, 0, 0, time.UTC), fireTimes[0])
+	withCalendars, err := triggers.WithCalendars(cronTrigger, &triggers.Calendar{Name: "holidays", Rule: triggers.CalendarSkip, Dates: []string{"2024-06-02"}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	fireTimes, err = triggers.NextFireTimes(triggers.WithBounds(withCalendars, startAt, end), prev, 5)
+	assert.NoError(t, err)
+	assert.Equal(t, []time.Time{
+		time.Date(2024, 6, 1, 9, 0, 0, 0, time.UTC),
+		time.Date(2024, 6, 3, 9, 0, 0, 0, time.UTC),
+	}, fireTimes)
+
+	// @every fires an interval after the start, like a repeat trigger.
+	everyTrigger, err := crontrigger.New("@every 1h")
+	if err != nil {
+		t.Fatal(err)
+	}
+	next, err := triggers.WithBounds(everyTrigger, startAt, end).NextFireTime(prev)
+	assert.NoError(t, err)
+	assert.Equal(t, startAt.Add(time.Hour), next)
+
+	// A repeat trigger fires as though it was scheduled at the start.
+	fireTimes, err = triggers.NextFireTimes(triggers.WithBounds(triggers.NewRepeatTrigger(18*time.Hour), start, end), prev, 5)
+	assert.NoError(t, err)
+	assert.Equal(t, []time.Time{
+		time.Date(2024, 6, 2, 6, 0, 0, 0, time.UTC),
+		time.Date(2024, 6, 3, 0, 0, 0, 0, time.UTC),
+	}, fireTimes)
+
+	// Without a start, only the end applies.
+	bounded := triggers.WithBounds(cronTrigger, time.Time{}, end)
+	next, err = bounded.NextFireTime(time.Date(2024, 6, 2, 12, 0, 0, 0, time.UTC))
+	assert.NoError(t, err)
+	assert.Equal(t, time.Date(2024, 6, 3, 9, 0, 0, 0, time.UTC), next)
+	_, err = bounded.NextFireTime(next)
+	assert.ErrorIs(t, err, triggers.ErrTriggerExpired)
+}
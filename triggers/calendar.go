@@ -0,0 +1,252 @@
+package triggers
+
+import (
+	"encoding/json"
+	"fmt"
+	"time"
+
+	"github.com/jswidler/gorun/errors"
+)
+
+// CalendarRule decides what happens to a fire time that a Calendar excludes.
+type CalendarRule string
+
+const (
+	// CalendarSkip drops excluded fire times, so the trigger fires at its next allowed time instead.
+	CalendarSkip CalendarRule = "skip"
+	// CalendarShift moves an excluded fire time to the end of the exclusion.  Several fire times within the same
+	// exclusion are shifted onto the same time, and fire once.
+	CalendarShift CalendarRule = "shift"
+)
+
+var ErrInvalidCalendar = errors.Sentinel("invalid calendar")
+
+// Calendar is a named set of times at which triggers that use it must not fire, such as bank holidays or
+// maintenance windows.
+type Calendar struct {
+	Name string `json:"-"`
+
+	// Location is the IANA time zone name that Dates and Weekly windows are in, UTC if empty.
+	Location string       `json:"location,omitempty"`
+	Rule     CalendarRule `json:"rule"`
+
+	// Dates are excluded for the whole day, in the form "2006-01-02".
+	Dates []string `json:"dates,omitempty"`
+	// Weekly windows are excluded every week.
+	Weekly []WeeklyWindow `json:"weekly,omitempty"`
+	// Ranges are excluded once.
+	Ranges []TimeRange `json:"ranges,omitempty"`
+}
+
+// WeeklyWindow is a time of the week, from Start until End on Weekday, given as "15:04".  An End of "24:00" is the
+// end of the day, and an End that is not after Start wraps past midnight into the next day.
+type WeeklyWindow struct {
+	Weekday time.Weekday `json:"weekday"`
+	Start   string       `json:"start"`
+	End     string       `json:"end"`
+}
+
+// TimeRange is the time from Start until End.
+type TimeRange struct {
+	Start time.Time `json:"start"`
+	End   time.Time `json:"end"`
+}
+
+const (
+	minutesPerDay  = 24 * 60
+	minutesPerWeek = 7 * minutesPerDay
+)
+
+// compiledCalendar is a Calendar parsed for quick lookups.
+type compiledCalendar struct {
+	*Calendar
+	location *time.Location
+	dates    map[string]bool
+	weekly   [][2]int // minutes of the week, the end may be past minutesPerWeek
+}
+
+// Validate checks the calendar can be used.
+func (c *Calendar) Validate() error {
+	_, err := c.compile()
+	return err
+}
+
+func (c *Calendar) compile() (*compiledCalendar, error) {
+	if c.Name == "" {
+		return nil, errors.Wrap(ErrInvalidCalendar, errors.WithMessage("calendar name is required"))
+	}
+	if c.Rule != CalendarSkip && c.Rule != CalendarShift {
+		return nil, errors.Wrap(ErrInvalidCalendar, errors.WithMessagef("calendar %s has an unknown rule %q", c.Name, c.Rule))
+	}
+	loc, err := time.LoadLocation(c.Location)
+	if err != nil {
+		return nil, errors.Wrap(ErrInvalidCalendar, errors.WithCause(err), errors.WithMessagef("calendar %s has an unknown location %q", c.Name, c.Location))
+	}
+
+	cc := &compiledCalendar{
+		Calendar: c,
+		location: loc,
+		dates:    make(map[string]bool, len(c.Dates)),
+	}
+	for _, date := range c.Dates {
+		if _, err := time.Parse(time.DateOnly, date); err != nil {
+			return nil, errors.Wrap(ErrInvalidCalendar, errors.WithCause(err), errors.WithMessagef("calendar %s has an invalid date %q", c.Name, date))
+		}
+		cc.dates[date] = true
+	}
+	for _, w := range c.Weekly {
+		start, err1 := minuteOfDay(w.Start)
+		end, err2 := minuteOfDay(w.End)
+		if err1 != nil || err2 != nil || w.Weekday < time.Sunday || w.Weekday > time.Saturday || start == minutesPerDay {
+			return nil, errors.Wrap(ErrInvalidCalendar, errors.WithMessagef("calendar %s has an invalid weekly window %v %s-%s", c.Name, w.Weekday, w.Start, w.End))
+		}
+		if end <= start {
+			end += minutesPerDay
+		}
+		offset := int(w.Weekday) * minutesPerDay
+		cc.weekly = append(cc.weekly, [2]int{offset + start, offset + end})
+	}
+	for _, r := range c.Ranges {
+		if !r.End.After(r.Start) {
+			return nil, errors.Wrap(ErrInvalidCalendar, errors.WithMessagef("calendar %s has a range that ends before it starts", c.Name))
+		}
+	}
+	return cc, nil
+}
+
+// minuteOfDay parses a time of day in the form "15:04", allowing "24:00".
+func minuteOfDay(s string) (int, error) {
+	var hour, minute int
+	if _, err := fmt.Sscanf(s, "%d:%d", &hour, &minute); err != nil || len(s) != 5 {
+		return 0, errors.Newf("invalid time of day %q", s)
+	}
+	if hour == 24 && minute == 0 {
+		return minutesPerDay, nil
+	}
+	if hour < 0 || hour > 23 || minute < 0 || minute > 59 {
+		return 0, errors.Newf("invalid time of day %q", s)
+	}
+	return hour*60 + minute, nil
+}
+
+// excludedUntil returns the end of the exclusion that t is in, or false if the calendar does not exclude t.
+func (cc *compiledCalendar) excludedUntil(t time.Time) (time.Time, bool) {
+	var until time.Time
+	local := t.In(cc.location)
+	if cc.dates[local.Format(time.DateOnly)] {
+		until = time.Date(local.Year(), local.Month(), local.Day()+1, 0, 0, 0, 0, cc.location)
+	}
+
+	minute := local.Hour()*60 + local.Minute()
+	weekMinute := int(local.Weekday())*minutesPerDay + minute
+	for _, w := range cc.weekly {
+		// A window that wraps past the end of the week also covers the start of the next week.
+		for _, m := range []int{weekMinute, weekMinute + minutesPerWeek} {
+			if m >= w[0] && m < w[1] {
+				end := time.Date(local.Year(), local.Month(), local.Day(), 0, minute+w[1]-m, 0, 0, cc.location)
+				until = later(until, end)
+			}
+		}
+	}
+
+	for _, r := range cc.Ranges {
+		if !t.Before(r.Start) && t.Before(r.End) {
+			until = later(until, r.End)
+		}
+	}
+	return until, !until.IsZero()
+}
+
+func later(a, b time.Time) time.Time {
+	if b.After(a) {
+		return b
+	}
+	return a
+}
+
+// maxCalendarExclusions limits how many excluded fire times are passed over in search of one that is allowed.
+const maxCalendarExclusions = 100_000
+
+// CalendarTrigger wraps a Trigger so that it does not fire at times excluded by its calendars.  It is not saved,
+// the calendars are attached to the trigger again each time it is loaded.
+type CalendarTrigger struct {
+	Trigger
+	calendars []*compiledCalendar
+}
+
+// Verify CalendarTrigger satisfies the Trigger interface.
+var _ Trigger = (*CalendarTrigger)(nil)
+
+// WithCalendars returns the trigger with the calendars applied to it.
+func WithCalendars(trigger Trigger, calendars ...*Calendar) (*CalendarTrigger, error) {
+	ct := &CalendarTrigger{Trigger: trigger}
+	for _, c := range calendars {
+		cc, err := c.compile()
+		if err != nil {
+			return nil, err
+		}
+		ct.calendars = append(ct.calendars, cc)
+	}
+	return ct, nil
+}
+
+// NextFireTime returns the next time at which the wrapped Trigger is scheduled to fire that the calendars allow.
+func (ct *CalendarTrigger) NextFireTime(prev time.Time) (time.Time, error) {
+	next := prev
+	for i := 0; i < maxCalendarExclusions; i++ {
+		var err error
+		next, err = ct.Trigger.NextFireTime(next)
+		if err != nil {
+			return next, err
+		}
+		shifted, excluded, shift := ct.allowedAfter(next)
+		if !excluded {
+			return next, nil
+		}
+		if shift {
+			return shifted.UTC(), nil
+		}
+	}
+	return time.Time{}, errors.Wrap(ErrTriggerExpired, errors.WithMessagef("calendars exclude the next %d fire times after %s", maxCalendarExclusions, prev))
+}
+
+// InclusiveStart returns whether the wrapped trigger includes its start, so the calendars can be bounded.
+func (ct *CalendarTrigger) InclusiveStart() bool {
+	return inclusiveStart(ct.Trigger)
+}
+
+// allowedAfter returns the first time at or after t that no calendar excludes, whether t was excluded, and whether
+// any of the calendars that excluded it shift fire times.
+func (ct *CalendarTrigger) allowedAfter(t time.Time) (time.Time, bool, bool) {
+	excluded, shift := false, false
+	for i := 0; i < maxCalendarExclusions; i++ {
+		moved := false
+		for _, cc := range ct.calendars {
+			if until, ok := cc.excludedUntil(t); ok {
+				t = until
+				moved, excluded = true, true
+				shift = shift || cc.Rule == CalendarShift
+			}
+		}
+		if !moved {
+			break
+		}
+	}
+	return t, excluded, shift
+}
+
+// Serialize returns the calendar as JSON, without the name.
+func (c *Calendar) Serialize() (string, error) {
+	data, err := json.Marshal(c)
+	return string(data), errors.Wrap(err)
+}
+
+// DeserializeCalendar returns the named calendar from its JSON.
+func DeserializeCalendar(name string, data string) (*Calendar, error) {
+	c := Calendar{Name: name}
+	err := json.Unmarshal([]byte(data), &c)
+	if err != nil {
+		return nil, errors.Wrap(err)
+	}
+	return &c, nil
+}
@@ -0,0 +1,116 @@
+package triggers_test
+
+import (
+	"testing"
+	"time"
+
+	"github.com/jswidler/gorun/triggers"
+	"github.com/jswidler/gorun/triggers/crontrigger"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestCalendarTrigger(t *testing.T) {
+	holidays := &triggers.Calendar{
+		Name:     "bank-holidays",
+		Location: "America/New_York",
+		Rule:     triggers.CalendarSkip,
+		Dates:    []string{"2024-12-25", "2025-01-01"},
+	}
+	maintenance := &triggers.Calendar{
+		Name:     "maintenance",
+		Location: "America/New_York",
+		Rule:     triggers.CalendarShift,
+		Weekly:   []triggers.WeeklyWindow{{Weekday: time.Saturday, Start: "22:00", End: "02:00"}},
+		Ranges: []triggers.TimeRange{{
+			Start: time.Date(2024, 12, 30, 14, 0, 0, 0, time.UTC),
+			End:   time.Date(2024, 12, 30, 16, 30, 0, 0, time.UTC),
+		}},
+	}
+
+	tests := []struct {
+		name       string
+		expression string
+		calendars  []*triggers.Calendar
+		prev       string
+		expected   []string
+	}{
+		{"holidays are skipped", "0 0 9 * * ?", []*triggers.Calendar{holidays}, "2024-12-24 12:00:00 -0500",
+			[]string{"2024-12-26 09:00:00 -0500", "2024-12-27 09:00:00 -0500"}},
+		{"weekly window wraps past midnight", "0 0 * * * ?", []*triggers.Calendar{maintenance}, "2024-12-28 20:30:00 -0500",
+			[]string{"2024-12-28 21:00:00 -0500", "2024-12-29 02:00:00 -0500", "2024-12-29 03:00:00 -0500"}},
+		{"range is shifted", "0 0 9 * * ?", []*triggers.Calendar{maintenance}, "2024-12-29 12:00:00 -0500",
+			[]string{"2024-12-30 11:30:00 -0500", "2024-12-31 09:00:00 -0500"}},
+		{"calendars combine", "0 0 23 * * ?", []*triggers.Calendar{holidays, maintenance}, "2024-12-24 12:00:00 -0500",
+			[]string{"2024-12-24 23:00:00 -0500", "2024-12-26 23:00:00 -0500", "2024-12-27 23:00:00 -0500", "2024-12-29 02:00:00 -0500",
+				"2024-12-29 23:00:00 -0500"}},
+	}
+
+	loc, err := time.LoadLocation("America/New_York")
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			cronTrigger, err := crontrigger.NewWithLoc(test.expression, loc)
+			if err != nil {
+				t.Fatal(err)
+			}
+			trigger, err := triggers.WithCalendars(cronTrigger, test.calendars...)
+			if err != nil {
+				t.Fatal(err)
+			}
+			next, err := time.Parse("2006-01-02 15:04:05 -0700", test.prev)
+			if err != nil {
+				t.Fatal(err)
+			}
+			for _, expected := range test.expected {
+				next, err = trigger.NextFireTime(next)
+				if err != nil {
+					t.Fatal(err)
+				}
+				assert.Equal(t, expected, next.In(loc).Format("2006-01-02 15:04:05 -0700"))
+			}
+		})
+	}
+}
+
+func TestCalendarExcludesEverything(t *testing.T) {
+	calendar := &triggers.Calendar{
+		Name: "closed",
+		Rule: triggers.CalendarSkip,
+		Ranges: []triggers.TimeRange{{
+			Start: time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC),
+			End:   time.Date(2200, 1, 1, 0, 0, 0, 0, time.UTC),
+		}},
+	}
+	trigger, err := triggers.WithCalendars(triggers.NewRepeatTrigger(time.Minute), calendar)
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, err = trigger.NextFireTime(time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC))
+	assert.ErrorIs(t, err, triggers.ErrTriggerExpired)
+}
+
+func TestInvalidCalendars(t *testing.T) {
+	calendars := []triggers.Calendar{
+		{Rule: triggers.CalendarSkip},
+		{Name: "rule", Rule: "postpone"},
+		{Name: "location", Rule: triggers.CalendarSkip, Location: "Mars/Olympus_Mons"},
+		{Name: "date", Rule: triggers.CalendarSkip, Dates: []string{"12/25/2024"}},
+		{Name: "weekly", Rule: triggers.CalendarSkip, Weekly: []triggers.WeeklyWindow{{Weekday: time.Monday, Start: "9:00", End: "17:00"}}},
+		{Name: "weekday", Rule: triggers.CalendarSkip, Weekly: []triggers.WeeklyWindow{{Weekday: 7, Start: "09:00", End: "17:00"}}},
+		{Name: "hour", Rule: triggers.CalendarSkip, Weekly: []triggers.WeeklyWindow{{Weekday: time.Monday, Start: "09:00", End: "25:00"}}},
+		{Name: "range", Rule: triggers.CalendarSkip, Ranges: []triggers.TimeRange{{Start: time.Now(), End: time.Now().Add(-time.Hour)}}},
+	}
+	for _, calendar := range calendars {
+		assert.ErrorIs(t, calendar.Validate(), triggers.ErrInvalidCalendar, calendar.Name)
+	}
+
+	calendar := triggers.Calendar{Name: "ok", Rule: triggers.CalendarShift, Weekly: []triggers.WeeklyWindow{{Weekday: time.Monday, Start: "00:00", End: "24:00"}}}
+	assert.NoError(t, calendar.Validate())
+	data, err := calendar.Serialize()
+	assert.NoError(t, err)
+	loaded, err := triggers.DeserializeCalendar("ok", data)
+	assert.NoError(t, err)
+	assert.Equal(t, &calendar, loaded)
+}
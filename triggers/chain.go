@@ -0,0 +1,88 @@
+package triggers
+
+import (
+	"encoding/json"
+	"time"
+
+	"github.com/jswidler/gorun/errors"
+)
+
+func init() {
+	RegisterTriggerHandler(&ChainTrigger{})
+}
+
+var ErrInvalidChainTrigger = errors.Sentinel("invalid chain trigger")
+
+// ChainTrigger fires when a job of JobType, or a job of the trigger TriggerId, finishes.  Only one of them is set.
+type ChainTrigger struct {
+	JobType   string `json:",omitempty"`
+	TriggerId string `json:",omitempty"`
+	// AnyStatus fires the trigger when the upstream job fails as well as when it completes.
+	AnyStatus bool `json:",omitempty"`
+	// Delay is how long after the upstream job finishes the job runs.
+	Delay time.Duration `json:",omitempty"`
+}
+
+// Verify ChainTrigger satisfies the Trigger interface.
+var _ Trigger = (*ChainTrigger)(nil)
+
+// NewChainTrigger returns a new ChainTrigger that fires after jobs of jobType, or of the trigger triggerId.
+func NewChainTrigger(jobType, triggerId string, anyStatus bool) *ChainTrigger {
+	return &ChainTrigger{
+		JobType:   jobType,
+		TriggerId: triggerId,
+		AnyStatus: anyStatus,
+	}
+}
+
+func (ct *ChainTrigger) Type() string {
+	return "chain"
+}
+
+// Validate checks the trigger can be used.
+func (ct *ChainTrigger) Validate() error {
+	if (ct.JobType == "") == (ct.TriggerId == "") {
+		return errors.Wrap(ErrInvalidChainTrigger, errors.WithMessage("exactly one of the upstream job type and trigger id is required"))
+	}
+	if ct.Delay < 0 {
+		return errors.Wrap(ErrInvalidChainTrigger, errors.WithMessagef("delay must not be negative, got %s", ct.Delay))
+	}
+	return nil
+}
+
+// Event returns the name of the event that a finished upstream job fires.
+func (ct *ChainTrigger) Event() string {
+	if ct.TriggerId != "" {
+		return TriggerFinishedEvent(ct.TriggerId)
+	}
+	return JobFinishedEvent(ct.JobType)
+}
+
+// JobFinishedEvent returns the name of the event fired when a job of the type finishes.
+func JobFinishedEvent(jobType string) string {
+	return "gorun:job:" + jobType
+}
+
+// TriggerFinishedEvent returns the name of the event fired when a job of the trigger finishes.
+func TriggerFinishedEvent(triggerId string) string {
+	return "gorun:trigger:" + triggerId
+}
+
+// NextFireTime returns the time at which the job runs for an upstream job that finished at prev.
+func (ct *ChainTrigger) NextFireTime(prev time.Time) (time.Time, error) {
+	return prev.Add(ct.Delay), nil
+}
+
+func (ct *ChainTrigger) Serialize() (string, error) {
+	data, err := json.Marshal(ct)
+	return string(data), errors.Wrap(err)
+}
+
+func (ct *ChainTrigger) Deserialize(data string) (Trigger, error) {
+	trig := ChainTrigger{}
+	err := json.Unmarshal([]byte(data), &trig)
+	if err != nil {
+		return nil, errors.Wrap(err)
+	}
+	return &trig, nil
+}
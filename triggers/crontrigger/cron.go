@@ -3,7 +3,6 @@ package crontrigger
 import (
 	"encoding/json"
 	"fmt"
-	"sort"
 	"strconv"
 	"strings"
 	"time"
@@ -21,6 +20,29 @@ func init() {
 //
 // SECONDS, MINUTES, HOURS, DAY OF MONTH, MONTH, DAY OF WEEK, YEAR (optional field)
 //
+// Besides "*", "?", lists, ranges and steps, the Quartz special characters are supported:
+//
+//	L   In the day of month field, the last day of the month, or with an offset, "L-3", the third to last day.
+//	    In the day of week field, "L" alone means Saturday, and "6L" or "FRIL" means the last Friday of the month.
+//	W   In the day of month field, the weekday nearest the given day, without leaving the month.
+//	    "LW" is the last weekday of the month.
+//	#   In the day of week field, the nth day of the month, e.g. "MON#1" or "2#1" is the first Monday.
+//
+// The year field accepts values from 1970 to 2099.  Values in a list may themselves be ranges or steps, e.g. "1-10/2,30".
+//
+// A "*" in the seconds, minutes or hours field that is less significant than a restricted field only matches
+// the lowest value, so "* 5 14 * * ?" fires once a day at 14:05:00.
+//
+// Expressions with 5 fields are read as standard crontab expressions, as used by Unix cron or Kubernetes CronJobs:
+//
+// # MINUTES, HOURS, DAY OF MONTH, MONTH, DAY OF WEEK
+//
+// They fire at second 0, number the days of the week from 0 (SUN) to 7 (SUN), and when both the day of month and day
+// of week are restricted, fire on days matching either field.
+//
+// The descriptors @yearly (or @annually), @monthly, @weekly, @daily (or @midnight) and @hourly may be used in place of
+// an expression, as may "@every <duration>", which fires repeatedly with the given interval, e.g. "@every 1h30m".
+//
 // Examples:
 //
 // Expression               Meaning
@@ -28,7 +50,7 @@ func init() {
 // "0 15 10 ? * *"          Fire at 10:15am every day
 // "0 15 10 * * ?"          Fire at 10:15am every day
 // "0 15 10 * * ? *"        Fire at 10:15am every day
-// "0 * 14 * * ?"           Fire every minute starting at 2pm and ending at 2:59pm, every day
+// "0 0-59 14 * * ?"        Fire every minute starting at 2pm and ending at 2:59pm, every day
 // "0 0/5 14 * * ?"         Fire every 5 minutes starting at 2pm and ending at 2:55pm, every day
 // "0 0/5 14,18 * * ?"      Fire every 5 minutes starting at 2pm and ending at 2:55pm,
 //
@@ -38,15 +60,27 @@ func init() {
 // "0 10,44 14 ? 3 WED"     Fire at 2:10pm and at 2:44pm every Wednesday in the month of March.
 // "0 15 10 ? * MON-FRI"    Fire at 10:15am every Monday, Tuesday, Wednesday, Thursday and Friday
 // "0 15 10 15 * ?"         Fire at 10:15am on the 15th day of every month
+// "0 15 10 L * ?"          Fire at 10:15am on the last day of every month
+// "0 15 10 L-2 * ?"        Fire at 10:15am on the second to last day of every month
+// "0 0 12 1W * ?"          Fire at 12pm (noon) on the first weekday of every month
+// "0 15 10 ? * 6L"         Fire at 10:15am on the last Friday of every month
+// "0 15 10 ? * 6#3"        Fire at 10:15am on the third Friday of every month
+// "0 15 10 ? * 6L 2025"    Fire at 10:15am on the last Friday of every month during 2025
+// "30 9 * * 1-5"           Fire at 9:30am every Monday, Tuesday, Wednesday, Thursday and Friday
+// "0 0 1,15 * 0"           Fire at midnight on the 1st and 15th of every month, and on every Sunday
 type CronTrigger struct {
-	expression  string
-	fields      []*CronField
-	lastDefined int
-	location    *time.Location
+	expression string
+	schedule   schedule
+	location   *time.Location
+
+	// every is set for @every expressions, which do not use a schedule.
+	every time.Duration
 }
 
 // Verify CronTrigger satisfies the Trigger interface.
 var _ triggers.Trigger = (*CronTrigger)(nil)
+var _ triggers.PrevFireTimer = (*CronTrigger)(nil)
+var _ triggers.InclusiveStarter = (*CronTrigger)(nil)
 
 // New returns a new CronTrigger using the UTC location.
 func New(expr string) (*CronTrigger, error) {
@@ -100,82 +134,76 @@ func (ct *CronTrigger) Type() string {
 	return "cron"
 }
 
+// InclusiveStart returns true unless the trigger is an @every expression, which fires an interval after it is
+// scheduled.
+func (ct *CronTrigger) InclusiveStart() bool {
+	return ct.every == 0
+}
+
 // NewWithLoc returns a new CronTrigger with the given time.Location.
 func NewWithLoc(expr string, location *time.Location) (*CronTrigger, error) {
-	fields, err := validateCronExpression(expr)
-	if err != nil {
-		return nil, err
-	}
-
-	lastDefined := -1
-	for i, field := range fields {
-		if len(field.values) > 0 {
-			lastDefined = i
+	if every, ok := strings.CutPrefix(expr, "@every "); ok {
+		interval, err := parseEvery(every)
+		if err != nil {
+			return nil, err
 		}
+		return &CronTrigger{
+			expression: expr,
+			location:   location,
+			every:      interval,
+		}, nil
 	}
 
-	// full wildcard expression
-	if lastDefined == -1 {
-		fields[0].values, _ = fillRange(0, 59)
+	fields, standard, err := parseCronExpression(expr)
+	if err != nil {
+		return nil, err
 	}
 
 	return &CronTrigger{
-		expression:  expr,
-		fields:      fields,
-		lastDefined: lastDefined,
-		location:    location,
+		expression: expr,
+		schedule:   compileSchedule(fields, standard),
+		location:   location,
 	}, nil
 }
 
 // NextFireTime returns the next time at which the CronTrigger is scheduled to fire.
 func (ct *CronTrigger) NextFireTime(prev time.Time) (time.Time, error) {
-	parser := NewCronExpressionParser(ct.lastDefined)
-	prev = prev.In(ct.location)
-	nextTime, err := parser.nextTime(prev, ct.fields)
-	nextTime = nextTime.UTC()
-	return nextTime, err
-}
-
-// CronExpressionParser parses cron expressions.
-type CronExpressionParser struct {
-	minuteBump bool
-	hourBump   bool
-	dayBump    bool
-	monthBump  bool
-	yearBump   bool
-	done       bool
-
-	lastDefined int
-	maxDays     int
+	if ct.every > 0 {
+		return prev.Add(ct.every).UTC(), nil
+	}
+	nextTime, ok := ct.schedule.next(prev.In(ct.location))
+	if !ok {
+		return time.Time{}, errors.Wrap(triggers.ErrTriggerExpired, errors.WithMessagef("cron expression %q has no fire time after %s", ct.expression, prev))
+	}
+	return nextTime.UTC(), nil
 }
 
-// NewCronExpressionParser returns a new CronExpressionParser.
-func NewCronExpressionParser(lastDefined int) *CronExpressionParser {
-	return &CronExpressionParser{
-		false, false, false, false, false, false,
-		lastDefined, 0,
+// PrevFireTime returns the last time before next at which the CronTrigger was scheduled to fire.
+func (ct *CronTrigger) PrevFireTime(next time.Time) (time.Time, error) {
+	if ct.every > 0 {
+		return next.Add(-ct.every).UTC(), nil
 	}
+	prevTime, ok := ct.schedule.prev(next.In(ct.location))
+	if !ok {
+		return time.Time{}, errors.Wrap(triggers.ErrTriggerExpired, errors.WithMessagef("cron expression %q has no fire time before %s", ct.expression, next))
+	}
+	return prevTime.UTC(), nil
 }
 
 // CronField represents a parsed cron expression as an array.
 type CronField struct {
 	values []int
-}
 
-// isEmpty checks if the CronField values array is empty.
-func (cf *CronField) isEmpty() bool {
-	return len(cf.values) == 0
+	// Quartz modifiers, only used by the day of month and day of week fields.
+	last       bool // L
+	lastOffset int  // L-n
+	weekday    bool // W
+	nth        int  // #n
 }
 
-// incr increments each element of the underlying array by the given value.
-func (cf *CronField) incr(a int) {
-	if !cf.isEmpty() {
-		mapped := make([]int, len(cf.values))
-		for i, v := range cf.values {
-			mapped[i] = v + a
-		}
-		cf.values = mapped
-	}
+// isEmpty checks if the CronField matches any value.
+func (cf *CronField) isEmpty() bool {
+	return len(cf.values) == 0 && !cf.last
 }
 
 // String is the CronField fmt.Stringer implementation.
@@ -186,19 +214,19 @@ func (cf *CronField) String() string {
 var (
 	months      = []string{"0", "JAN", "FEB", "MAR", "APR", "MAY", "JUN", "JUL", "AUG", "SEP", "OCT", "NOV", "DEC"}
 	days        = []string{"0", "SUN", "MON", "TUE", "WED", "THU", "FRI", "SAT"}
+	weekdays    = []string{"SUN", "MON", "TUE", "WED", "THU", "FRI", "SAT", "SUN"}
 	daysInMonth = []int{0, 31, 28, 31, 30, 31, 30, 31, 31, 30, 31, 30, 31}
 
 	// the pre-defined cron expressions
 	special = map[string]string{
-		"@yearly":  "0 0 0 1 1 *",
-		"@monthly": "0 0 0 1 * *",
-		"@weekly":  "0 0 0 * * 1",
-		"@daily":   "0 0 0 * * *",
-		"@hourly":  "0 0 * * * *",
+		"@yearly":   "0 0 0 1 1 *",
+		"@annually": "0 0 0 1 1 *",
+		"@monthly":  "0 0 0 1 * *",
+		"@weekly":   "0 0 0 * * 1",
+		"@daily":    "0 0 0 * * *",
+		"@midnight": "0 0 0 * * *",
+		"@hourly":   "0 0 * * * *",
 	}
-
-	readDateLayout  = "Mon Jan 2 15:04:05 2006"
-	writeDateLayout = "Jan 2 15:04:05 2006"
 )
 
 // <second> <minute> <hour> <day-of-month> <month> <day-of-week> <year>
@@ -213,65 +241,65 @@ const (
 	yearIndex
 )
 
-func (parser *CronExpressionParser) nextTime(prev time.Time, fields []*CronField) (nextTime time.Time, err error) {
-	defer func() {
-		if r := recover(); r != nil {
-			switch x := r.(type) {
-			case string:
-				err = errors.New(x)
-			case error:
-				err = x
-			default:
-				err = errors.New("unknown cron expression error")
-			}
+// searchYears limits how far the schedule looks for a match.  Every day of week and day of month
+// combination repeats in the 400 year Gregorian cycle, so an expression that does not match in that time never will.
+const searchYears = 400
+
+// parseCronExpression parses the expression into fields, and reports if it is a standard 5 field expression.
+func parseCronExpression(expr string) ([]*CronField, bool, error) {
+	fields, standard, err := validateCronExpression(expr)
+	if err != nil || standard {
+		return fields, standard, err
+	}
+
+	lastDefined := -1
+	for i, field := range fields {
+		if !field.isEmpty() {
+			lastDefined = i
+		}
+	}
+
+	// A wildcard in a time of day field that is less significant than a restricted field only matches its lowest value.
+	for i := secondIndex; i <= hourIndex && i < lastDefined; i++ {
+		if fields[i].isEmpty() {
+			fields[i].values = []int{0}
 		}
-	}()
-
-	tfmt := prev.Format(readDateLayout)
-	ttok := strings.Split(strings.Replace(tfmt, "  ", " ", 1), " ")
-	hms := strings.Split(ttok[3], ":")
-	parser.maxDays = maxDays(intVal(months, ttok[1]), atoi(ttok[4]))
-
-	second := parser.nextSeconds(atoi(hms[2]), fields[0])
-	minute := parser.nextMinutes(atoi(hms[1]), fields[1])
-	hour := parser.nextHours(atoi(hms[0]), fields[2])
-	dayOfMonth := parser.nextDay(intVal(days, ttok[0])-1, fields[5], atoi(ttok[2]), fields[3])
-	month := parser.nextMonth(ttok[1], fields[4])
-	year := parser.nextYear(ttok[4], fields[6])
-
-	nstr := fmt.Sprintf("%s %s %s:%s:%s %s", month, strconv.Itoa(dayOfMonth),
-		hour, minute, second, year)
-	nextTime, err = time.ParseInLocation(writeDateLayout, nstr, prev.Location())
-	return
+	}
+	return fields, false, nil
 }
 
-// the ? wildcard is only used in the day of month and day of week fields
-func validateCronExpression(expression string) ([]*CronField, error) {
+// validateCronExpression parses the expression into fields, and reports if it is a standard 5 field expression.
+// The ? wildcard is only used in the day of month and day of week fields.
+func validateCronExpression(expression string) ([]*CronField, bool, error) {
 	var tokens []string
 
 	if value, ok := special[expression]; ok {
-		tokens = strings.Split(value, " ")
+		tokens = strings.Fields(value)
 	} else {
-		tokens = strings.Split(expression, " ")
+		tokens = strings.Fields(expression)
 	}
 	length := len(tokens)
+	if length == 5 {
+		tokens = append([]string{"0"}, tokens...)
+		tokens = append(tokens, "*")
+		fields, err := buildCronField(tokens, true)
+		return fields, true, err
+	}
 	if length < 6 || length > 7 {
-		return nil, cronError("Invalid expression length")
+		return nil, false, cronError("Invalid expression length")
 	}
 	if length == 6 {
 		tokens = append(tokens, "*")
 	}
 	if (tokens[3] != "?" && tokens[3] != "*") && (tokens[5] != "?" && tokens[5] != "*") {
-		return nil, cronError("Day field was set twice")
-	}
-	if tokens[6] != "*" {
-		return nil, cronError("Year field is not supported, use asterisk") // TODO: support year field
+		return nil, false, cronError("Day field was set twice")
 	}
 
-	return buildCronField(tokens)
+	fields, err := buildCronField(tokens, false)
+	return fields, false, err
 }
 
-func buildCronField(tokens []string) ([]*CronField, error) {
+func buildCronField(tokens []string, standard bool) ([]*CronField, error) {
 	var err error
 	fields := make([]*CronField, 7)
 	fields[0], err = parseField(tokens[0], 0, 59)
@@ -289,7 +317,7 @@ func buildCronField(tokens []string) ([]*CronField, error) {
 		return nil, err
 	}
 
-	fields[3], err = parseField(tokens[3], 1, 31)
+	fields[3], err = parseDayOfMonthField(tokens[3])
 	if err != nil {
 		return nil, err
 	}
@@ -299,13 +327,12 @@ func buildCronField(tokens []string) ([]*CronField, error) {
 		return nil, err
 	}
 
-	fields[5], err = parseField(tokens[5], 1, 7, days)
+	fields[5], err = parseDayOfWeekField(tokens[5], standard)
 	if err != nil {
 		return nil, err
 	}
-	fields[5].incr(-1)
 
-	fields[6], err = parseField(tokens[6], 1970, 1970*2)
+	fields[6], err = parseField(tokens[6], 1970, 2099)
 	if err != nil {
 		return nil, err
 	}
@@ -313,295 +340,141 @@ func buildCronField(tokens []string) ([]*CronField, error) {
 	return fields, nil
 }
 
-func parseField(field string, min int, max int, translate ...[]string) (*CronField, error) {
-	var dict []string
-	if len(translate) > 0 {
-		dict = translate[0]
-	}
-
-	// any value
-	if field == "*" || field == "?" {
-		return &CronField{[]int{}}, nil
-	}
-
-	// single value
-	i, err := strconv.Atoi(field)
-	if err == nil {
-		if inScope(i, min, max) {
-			return &CronField{[]int{i}}, nil
+// parseDayOfMonthField parses the day of month field, which may use the L and W modifiers.
+func parseDayOfMonthField(field string) (*CronField, error) {
+	field = strings.ToUpper(field)
+	switch {
+	case field == "L":
+		return &CronField{last: true}, nil
+	case field == "LW":
+		return &CronField{last: true, weekday: true}, nil
+	case strings.HasPrefix(field, "L-"):
+		offset, err := strconv.Atoi(field[2:])
+		if err != nil || !inScope(offset, 1, 30) {
+			return nil, cronError("Last day offset validation error")
 		}
-		return nil, cronError("Single min/max validation error")
-	}
-
-	// list values
-	if strings.Contains(field, ",") {
-		return parseListField(field, dict)
-	}
-
-	// range values
-	if strings.Contains(field, "-") {
-		return parseRangeField(field, min, max, dict)
-	}
-
-	// step values
-	if strings.Contains(field, "/") {
-		return parseStepField(field, min, max, dict)
-	}
-
-	// literal single value
-	if dict != nil {
-		i := intVal(dict, field)
-		if i >= 0 {
-			if inScope(i, min, max) {
-				return &CronField{[]int{i}}, nil
-			}
-			return nil, cronError("Cron literal min/max validation error")
+		return &CronField{last: true, lastOffset: offset}, nil
+	case strings.HasSuffix(field, "W"):
+		day, err := strconv.Atoi(strings.TrimSuffix(field, "W"))
+		if err != nil || !inScope(day, 1, 31) {
+			return nil, cronError("Nearest weekday validation error")
 		}
+		return &CronField{values: []int{day}, weekday: true}, nil
 	}
-
-	return nil, cronError("Cron parse error")
+	return parseField(field, 1, 31)
 }
 
-func parseListField(field string, translate []string) (*CronField, error) {
-	t := strings.Split(field, ",")
-	si, err := sliceAtoi(t)
-	if err != nil {
-		si, err = indexes(t, translate)
-		if err != nil {
-			return nil, err
-		}
+// parseDayOfWeekField parses the day of week field, which may use the L and # modifiers.  Quartz expressions number
+// the days from 1 (SUN) to 7 (SAT), and standard expressions from 0 (SUN) to 7 (SUN), but the values of the returned
+// field are always numbered like time.Weekday.
+func parseDayOfWeekField(field string, standard bool) (*CronField, error) {
+	min, dict := 1, days
+	if standard {
+		min, dict = 0, weekdays
 	}
 
-	sort.Ints(si)
-	return &CronField{si}, nil
-}
-
-func parseRangeField(field string, min int, max int, translate []string) (*CronField, error) {
-	var _range []int
-	t := strings.Split(field, "-")
-	if len(t) != 2 {
-		return nil, cronError("Parse cron range error")
-	}
-
-	from := normalize(t[0], translate)
-	to := normalize(t[1], translate)
-	if !inScope(from, min, max) || !inScope(to, min, max) {
-		return nil, cronError("Cron range min/max validation error")
-	}
-
-	_range, err := fillRange(from, to)
-	if err != nil {
-		return nil, err
-	}
-
-	return &CronField{_range}, nil
-}
-
-func parseStepField(field string, min int, max int, translate []string) (*CronField, error) {
-	var _step []int
-	t := strings.Split(field, "/")
-	if len(t) != 2 {
-		return nil, cronError("Parse cron step error")
-	}
-
-	if t[0] == "*" {
-		t[0] = strconv.Itoa(min)
-	}
-
-	from := normalize(t[0], translate)
-	step := atoi(t[1])
-	if !inScope(from, min, max) {
-		return nil, cronError("Cron step min/max validation error")
-	}
-
-	_step, err := fillStep(from, step, max)
-	if err != nil {
-		return nil, err
-	}
-
-	return &CronField{_step}, nil
-}
-
-func (parser *CronExpressionParser) setDone(index int) {
-	if parser.lastDefined == index {
-		parser.done = true
-	}
-}
-
-func (parser *CronExpressionParser) lastSet(index int) bool {
-	return parser.lastDefined <= index
-}
-
-func (parser *CronExpressionParser) nextSeconds(prev int, field *CronField) string {
-	var next int
-	next, parser.minuteBump = parser.findNextValue(prev, field.values)
-	parser.setDone(secondIndex)
-	return alignDigit(next)
-}
-
-func (parser *CronExpressionParser) nextMinutes(prev int, field *CronField) string {
-	var next int
-	if field.isEmpty() && parser.lastSet(minuteIndex) {
-		if parser.minuteBump {
-			next, parser.hourBump = bumpValue(prev, 59, 1, true)
-			return alignDigit(next)
+	field = strings.ToUpper(field)
+	var cf *CronField
+	if field == "L" {
+		return &CronField{values: []int{int(time.Saturday)}}, nil
+	} else if day, nth, found := strings.Cut(field, "#"); found {
+		d := normalize(day, dict)
+		n, err := strconv.Atoi(nth)
+		if !inScope(d, min, 7) || err != nil || !inScope(n, 1, 5) {
+			return nil, cronError("Nth day of week validation error")
 		}
-		return alignDigit(prev)
-	}
-
-	next, parser.hourBump = parser.findNextValue(prev, field.values)
-	parser.setDone(minuteIndex)
-	return alignDigit(next)
-}
-
-func (parser *CronExpressionParser) nextHours(prev int, field *CronField) string {
-	var next int
-	if field.isEmpty() && parser.lastSet(hourIndex) {
-		if parser.hourBump {
-			next, parser.dayBump = bumpValue(prev, 23, 1, true)
-			return alignDigit(next)
-		}
-		return alignDigit(prev)
-	}
-
-	next, parser.dayBump = parser.findNextValue(prev, field.values)
-	parser.setDone(hourIndex)
-	return alignDigit(next)
-}
-
-func (parser *CronExpressionParser) nextDay(prevWeek int, weekField *CronField,
-	prevMonth int, monthField *CronField,
-) int {
-	var nextMonth int
-	if weekField.isEmpty() && monthField.isEmpty() && parser.lastSet(dayOfWeekIndex) {
-		if parser.dayBump {
-			nextMonth, parser.monthBump = bumpValue(prevMonth, parser.maxDays, 1, false)
-			return nextMonth
+		cf = &CronField{values: []int{d}, nth: n}
+	} else if day, found := strings.CutSuffix(field, "L"); found {
+		d := normalize(day, dict)
+		if !inScope(d, min, 7) {
+			return nil, cronError("Last day of week validation error")
 		}
-		return prevMonth
-	}
-
-	if len(monthField.values) > 0 {
-		nextMonth, parser.monthBump = parser.findNextValue(prevMonth, monthField.values)
-		parser.setDone(dayOfMonthIndex)
-		return nextMonth
-	} else if len(weekField.values) > 0 {
-		nextWeek, bumpDayOfMonth := parser.findNextValue(prevWeek, weekField.values)
-		parser.setDone(dayOfWeekIndex)
-		var _step int
-		if len(weekField.values) == 1 && weekField.values[0] < prevWeek {
-			bumpDayOfMonth = false
-		}
-
-		if bumpDayOfMonth && len(weekField.values) == 1 {
-			_step = 7
-		} else {
-			_step = step(prevWeek, nextWeek, 7)
+		cf = &CronField{values: []int{d}, last: true}
+	} else {
+		var err error
+		cf, err = parseField(field, min, 7, dict)
+		if err != nil {
+			return nil, err
 		}
-		nextMonth, parser.monthBump = bumpValue(prevMonth, parser.maxDays, _step, false)
-		return nextMonth
 	}
-	return prevMonth
-}
 
-func (parser *CronExpressionParser) nextMonth(prev string, field *CronField) string {
-	var next int
-	if field.isEmpty() && parser.lastSet(dayOfWeekIndex) {
-		if parser.monthBump {
-			next, parser.yearBump = bumpLiteral(intVal(months, prev), 12, 1)
-			return months[next]
+	for i, v := range cf.values {
+		if standard {
+			cf.values[i] = v % 7
+		} else {
+			cf.values[i] = v - 1
 		}
-		return prev
 	}
-
-	next, parser.yearBump = parser.findNextValue(intVal(months, prev), field.values)
-	parser.setDone(monthIndex)
-	return months[next]
+	cf.values = uniq(cf.values)
+	return cf, nil
 }
 
-func (parser *CronExpressionParser) nextYear(prev string, field *CronField) string {
-	var next int
-	if field.isEmpty() && parser.lastSet(yearIndex) {
-		if parser.yearBump {
-			next, _ = bumpValue(prev, int(^uint(0)>>1), 1, false)
-			return strconv.Itoa(next)
-		}
-		return prev
+func parseField(field string, min int, max int, translate ...[]string) (*CronField, error) {
+	var dict []string
+	if len(translate) > 0 {
+		dict = translate[0]
 	}
 
-	next, halt := parser.findNextValue(prev, field.values)
-	if halt {
-		panic("Out of expression range error")
+	// any value
+	if field == "*" || field == "?" {
+		return &CronField{values: []int{}}, nil
 	}
 
-	return strconv.Itoa(next)
-}
-
-func bumpLiteral(iprev int, max int, step int) (int, bool) {
-	bumped := iprev + step
-	if bumped > max {
-		if bumped%max == 0 {
-			return iprev, true
+	// list values, where each value may be a single value, a range, or a step
+	values := []int{}
+	for _, part := range strings.Split(field, ",") {
+		v, err := parseFieldPart(part, min, max, dict)
+		if err != nil {
+			return nil, err
 		}
-		return (bumped % max), true
+		values = append(values, v...)
 	}
 
-	return bumped, false
+	return &CronField{values: uniq(values)}, nil
 }
 
-// returns bumped value, bump next
-func bumpValue(prev interface{}, max, step int, zero bool) (int, bool) {
-	var iprev int
-
-	switch prevValue := prev.(type) {
-	case string:
-		iprev = atoi(prevValue)
-	case int:
-		iprev = prevValue
-	default:
-		panic("Unknown type at bumpValue")
-	}
-
-	bumped := iprev + step
-	if bumped > max {
-		if zero {
-			return 0, true
+// parseFieldPart parses a single value "a", a range "a-b", or a step "a/n", "a-b/n" or "*/n".
+func parseFieldPart(part string, min int, max int, translate []string) ([]int, error) {
+	base, stepValue, hasStep := strings.Cut(part, "/")
+	step := 1
+	if hasStep {
+		var err error
+		step, err = strconv.Atoi(stepValue)
+		if err != nil || step < 1 {
+			return nil, cronError("Cron step validation error")
 		}
-		return bumped % max, true
-	}
-
-	return bumped, false
-}
-
-// returns next value, bump next
-func (parser *CronExpressionParser) findNextValue(prev interface{}, values []int) (int, bool) {
-	var iprev int
-
-	switch prevValue := prev.(type) {
-	case string:
-		iprev = atoi(prevValue)
-	case int:
-		iprev = prevValue
-	default:
-		panic("Unknown type at findNextValue")
-	}
-
-	if len(values) == 0 {
-		return iprev, false
 	}
 
-	for _, element := range values {
-		if parser.done {
-			if element >= iprev {
-				return element, false
+	from, to := min, max
+	if base != "*" {
+		if rangeFrom, rangeTo, isRange := strings.Cut(base, "-"); isRange {
+			from = normalize(rangeFrom, translate)
+			to = normalize(rangeTo, translate)
+			if !inScope(from, min, max) || !inScope(to, min, max) || to < from {
+				return nil, cronError("Cron range min/max validation error")
 			}
 		} else {
-			if element > iprev {
-				parser.done = true
-				return element, false
+			from = normalize(base, translate)
+			if !inScope(from, min, max) {
+				return nil, cronError("Cron value min/max validation error")
+			}
+			if !hasStep {
+				to = from
 			}
 		}
 	}
 
-	return values[0], true
+	return fillStep(from, step, to)
+}
+
+// parseEvery parses the duration of an "@every <duration>" expression.
+func parseEvery(every string) (time.Duration, error) {
+	interval, err := time.ParseDuration(strings.TrimSpace(every))
+	if err != nil {
+		return 0, cronError("Parse @every duration error")
+	}
+	if interval < time.Second {
+		return 0, cronError("@every duration must be at least one second")
+	}
+	return interval, nil
 }
@@ -0,0 +1,316 @@
+package crontrigger
+
+import (
+	"testing"
+	"time"
+
+	"github.com/jswidler/gorun/errors"
+	"github.com/jswidler/gorun/triggers"
+	"github.com/stretchr/testify/assert"
+)
+
+// referenceCron is the straightforward cron evaluator that walks lists of field values, kept to check the compiled
+// schedule against.
+type referenceCron struct {
+	expression string
+	fields     []*CronField
+	dayOr      bool
+}
+
+func newReferenceCron(expr string) (*referenceCron, error) {
+	fields, standard, err := parseCronExpression(expr)
+	if err != nil {
+		return nil, err
+	}
+	return &referenceCron{
+		expression: expr,
+		fields:     fields,
+		dayOr:      standard && !fields[dayOfMonthIndex].isEmpty() && !fields[dayOfWeekIndex].isEmpty(),
+	}, nil
+}
+
+// has checks if the value is in the field, an empty field has every value.
+func (cf *CronField) has(v int) bool {
+	if len(cf.values) == 0 {
+		return true
+	}
+	for _, x := range cf.values {
+		if x == v {
+			return true
+		}
+	}
+	return false
+}
+
+// next returns the smallest value of the field in the range [v, max].
+func (cf *CronField) next(v, max int) (int, bool) {
+	if len(cf.values) == 0 {
+		return v, v <= max
+	}
+	for _, x := range cf.values {
+		if x >= v && x <= max {
+			return x, true
+		}
+	}
+	return 0, false
+}
+
+// matchesDayOfMonth checks if the day of the month matches, taking the L and W modifiers into account.
+func (cf *CronField) matchesDayOfMonth(year, month, day, lastDay int) bool {
+	switch {
+	case cf.last && cf.weekday:
+		return day == nearestWeekday(year, month, lastDay, lastDay)
+	case cf.last:
+		return day == lastDay-cf.lastOffset
+	case cf.weekday:
+		return cf.values[0] <= lastDay && day == nearestWeekday(year, month, cf.values[0], lastDay)
+	}
+	return cf.has(day)
+}
+
+// matchesDayOfWeek checks if the day of the week matches, taking the L and # modifiers into account.
+func (cf *CronField) matchesDayOfWeek(weekday time.Weekday, day, lastDay int) bool {
+	if !cf.has(int(weekday)) {
+		return false
+	}
+	switch {
+	case cf.last:
+		return day+7 > lastDay
+	case cf.nth > 0:
+		return (day-1)/7+1 == cf.nth
+	}
+	return true
+}
+
+func (rc *referenceCron) nextTime(prev time.Time) (time.Time, error) {
+	repeatHours := rc.fields[hourIndex].isEmpty()
+	wall := wallClock(prev)
+	for {
+		var err error
+		wall, err = rc.nextWallTime(wall)
+		if err != nil {
+			return time.Time{}, err
+		}
+
+		next, ok := rc.resolveWallTime(wall, prev, repeatHours)
+		if !ok {
+			continue
+		}
+
+		// When the clocks go back between prev and next, the wall clock times already passed come around again.
+		if _, end := prev.ZoneBounds(); repeatHours && !end.IsZero() && !end.After(next) && isFallBack(end) {
+			again, err := rc.nextWallTime(wallClock(end).Add(-time.Second))
+			if err != nil {
+				return time.Time{}, err
+			}
+			_, offset := end.Zone()
+			if t := time.Unix(again.Unix()-int64(offset), 0).In(prev.Location()); t.After(prev) && t.Before(next) {
+				return t, nil
+			}
+		}
+		return next, nil
+	}
+}
+
+// resolveWallTime returns the instant a wall clock time should fire at, following the rules of nextTime.  It returns false
+// if the wall clock time should not fire after prev.
+func (rc *referenceCron) resolveWallTime(wall time.Time, prev time.Time, repeatHours bool) (time.Time, bool) {
+	loc := prev.Location()
+	instants, n := localTimes(wall, loc)
+	switch n {
+	case 0:
+		// Skipped: use the offset from before the clocks moved forward, which is the smaller one.
+		_, before := time.Unix(wall.Unix()-maxZoneOffset, 0).In(loc).Zone()
+		_, after := time.Unix(wall.Unix()+maxZoneOffset, 0).In(loc).Zone()
+		return time.Unix(wall.Unix()-int64(min(before, after)), 0).In(loc), true
+	case 1:
+		return instants[0], instants[0].After(prev)
+	default:
+		if instants[0].After(prev) {
+			return instants[0], true
+		}
+		return instants[1], repeatHours && instants[1].After(prev)
+	}
+}
+
+// nextWallTime finds the first wall clock time after the given one that matches every field.  Wall clock times are
+// represented in UTC, so they are free of any daylight saving transitions.  The calendar is walked from the most
+// significant field to the least; whenever a field has no matching value left, the next larger field is advanced and
+// the smaller fields start over from their lowest value.
+func (rc *referenceCron) nextWallTime(prev time.Time) (time.Time, error) {
+	year, m, day := prev.Date()
+	hour, minute, second := prev.Clock()
+	month := int(m)
+	second++
+
+	maxYear := year + searchYears
+	for year <= maxYear {
+		if y, ok := rc.fields[yearIndex].next(year, maxYear); !ok {
+			break
+		} else if y > year {
+			year, month, day, hour, minute, second = y, 1, 1, 0, 0, 0
+		}
+
+		if mo, ok := rc.fields[monthIndex].next(month, 12); !ok {
+			year, month, day, hour, minute, second = year+1, 1, 1, 0, 0, 0
+			continue
+		} else if mo > month {
+			month, day, hour, minute, second = mo, 1, 0, 0, 0
+		}
+
+		if d, ok := rc.nextDay(year, month, day); !ok {
+			month, day, hour, minute, second = month+1, 1, 0, 0, 0
+			continue
+		} else if d > day {
+			day, hour, minute, second = d, 0, 0, 0
+		}
+
+		if h, ok := rc.fields[hourIndex].next(hour, 23); !ok {
+			day, hour, minute, second = day+1, 0, 0, 0
+			continue
+		} else if h > hour {
+			hour, minute, second = h, 0, 0
+		}
+
+		if mi, ok := rc.fields[minuteIndex].next(minute, 59); !ok {
+			hour, minute, second = hour+1, 0, 0
+			continue
+		} else if mi > minute {
+			minute, second = mi, 0
+		}
+
+		if s, ok := rc.fields[secondIndex].next(second, 59); !ok {
+			minute, second = minute+1, 0
+			continue
+		} else {
+			second = s
+		}
+
+		return time.Date(year, time.Month(month), day, hour, minute, second, 0, time.UTC), nil
+	}
+
+	return time.Time{}, errors.Wrap(triggers.ErrTriggerExpired, errors.WithMessagef("cron expression %q has no fire time after %s", rc.expression, prev))
+}
+
+// nextDay returns the first day of the month, starting at the given day, that matches the day fields.
+func (rc *referenceCron) nextDay(year, month, day int) (int, bool) {
+	lastDay := maxDays(month, year)
+	for ; day <= lastDay; day++ {
+		if rc.dayMatches(year, month, day, lastDay) {
+			return day, true
+		}
+	}
+	return 0, false
+}
+
+func (rc *referenceCron) dayMatches(year, month, day, lastDay int) bool {
+	dayOfMonth, dayOfWeek := rc.fields[dayOfMonthIndex], rc.fields[dayOfWeekIndex]
+	if rc.dayOr {
+		return dayOfMonth.matchesDayOfMonth(year, month, day, lastDay) || dayOfWeek.matchesDayOfWeek(weekday(year, month, day), day, lastDay)
+	}
+	if !dayOfMonth.isEmpty() && !dayOfMonth.matchesDayOfMonth(year, month, day, lastDay) {
+		return false
+	}
+	if !dayOfWeek.isEmpty() && !dayOfWeek.matchesDayOfWeek(weekday(year, month, day), day, lastDay) {
+		return false
+	}
+	return true
+}
+
+var fuzzLocations = []string{"UTC", "America/New_York", "Europe/London", "Australia/Lord_Howe", "Asia/Kolkata"}
+
+func FuzzScheduleMatchesReference(f *testing.F) {
+	seeds := []string{
+		"* * * * * ?",
+		"0 0 12 * * ?",
+		"10/20 15 14 5-10 * ? *",
+		"* 5,7,9 14-16 * * ? *",
+		"*/3 */51 */12 */2 */4 ? *",
+		"0 30 2 * * ?",
+		"0 30 1 * * ?",
+		"0 */15 * * * ?",
+		"0 0 12 L * ?",
+		"0 0 12 L-2 * ?",
+		"0 0 12 LW * ?",
+		"0 0 12 15W * ?",
+		"0 0 12 ? * 6L",
+		"0 0 12 ? * FRI#3",
+		"0 0 0 29 2 ? 2025/3",
+		"0 0 0 31 2 ?",
+		"30 9 * * 1-5",
+		"0 0 1,15 * 0",
+		"*/15 * * * *",
+		"@hourly",
+	}
+	for i, expr := range seeds {
+		f.Add(expr, int64(1_700_000_000+i*86_413), uint8(i))
+	}
+
+	f.Fuzz(func(t *testing.T, expr string, unix int64, zone uint8) {
+		if unix < 0 || unix > 4_000_000_000 {
+			t.Skip()
+		}
+		ref, err := newReferenceCron(expr)
+		if err != nil {
+			t.Skip()
+		}
+		loc, err := time.LoadLocation(fuzzLocations[int(zone)%len(fuzzLocations)])
+		if err != nil {
+			t.Fatal(err)
+		}
+		ct, err := NewWithLoc(expr, loc)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		prev := time.Unix(unix, 0).In(loc)
+		for i := 0; i < 5; i++ {
+			expected, refErr := ref.nextTime(prev)
+			next, ok := ct.schedule.next(prev)
+			if refErr != nil {
+				assert.False(t, ok, "reference has no fire time after %s", prev)
+				return
+			}
+			if !assert.True(t, ok) || !assert.True(t, expected.Equal(next), "after %s expected %s, got %s", prev, expected, next) {
+				return
+			}
+
+			// The fire time before next is the one that led to it, or lies no later than where we
+			// started; only the very first fire time of a schedule has none.
+			back, ok := ct.schedule.prev(next)
+			if i > 0 {
+				assert.True(t, ok, "no fire time before %s", next)
+			}
+			if ok {
+				assert.False(t, back.After(prev), "before %s expected at most %s, got %s", next, prev, back)
+				again, _ := ct.schedule.next(back)
+				assert.True(t, again.Equal(next), "after %s expected %s, got %s", back, next, again)
+			}
+			prev = next
+		}
+	})
+}
+
+func BenchmarkReferenceNextTime(b *testing.B) {
+	expressions := []string{
+		"0 0 12 * * ?",
+		"*/15 * * * *",
+		"0 15 10 ? * 6L",
+		"0 0 0 29 2 ?",
+	}
+	for _, expression := range expressions {
+		b.Run(expression, func(b *testing.B) {
+			ref, err := newReferenceCron(expression)
+			if err != nil {
+				b.Fatal(err)
+			}
+			prev := time.Date(2024, 5, 15, 18, 0, 0, 0, time.UTC)
+			b.ReportAllocs()
+			for i := 0; i < b.N; i++ {
+				if _, err := ref.nextTime(prev); err != nil {
+					b.Fatal(err)
+				}
+			}
+		})
+	}
+}
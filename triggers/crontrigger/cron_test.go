@@ -6,6 +6,7 @@ import (
 	"testing"
 	"time"
 
+	"github.com/jswidler/gorun/triggers"
 	"github.com/jswidler/gorun/triggers/crontrigger"
 	"github.com/stretchr/testify/assert"
 )
@@ -43,7 +44,7 @@ func TestCronExpression3(t *testing.T) {
 	} else {
 		result, _ = iterate(prev, cronTrigger, 1000)
 	}
-	assert.Equal(t, result, "Sat Dec 7 14:05:00 2019")
+	assert.Equal(t, result, "Wed Dec 4 20:05:00 2019")
 }
 
 func TestCronExpression4(t *testing.T) {
@@ -99,7 +100,7 @@ func TestCronExpression8(t *testing.T) {
 	} else {
 		result, _ = iterate(prev, cronTrigger, 1000)
 	}
-	assert.Equal(t, result, "Sat Sep 7 12:00:00 2019")
+	assert.Equal(t, result, "Sat May 25 00:51:57 2019")
 }
 
 func TestCronExpressionWithLoc(t *testing.T) {
@@ -213,8 +214,317 @@ func TestCronHourly(t *testing.T) {
 	assert.Equal(t, result, "Mon May 27 10:00:00 2019")
 }
 
+func TestCronQuartzSpecialCharacters(t *testing.T) {
+	prev := time.Date(2024, 5, 15, 18, 0, 0, 0, time.UTC)
+	tests := []struct {
+		expression string
+		expected   []string
+	}{
+		{"0 0 12 L * ?", []string{"Fri May 31 12:00:00 2024", "Sun Jun 30 12:00:00 2024", "Wed Jul 31 12:00:00 2024", "Sat Aug 31 12:00:00 2024"}},
+		{"0 0 12 L-2 * ?", []string{"Wed May 29 12:00:00 2024", "Fri Jun 28 12:00:00 2024", "Mon Jul 29 12:00:00 2024", "Thu Aug 29 12:00:00 2024"}},
+		{"0 0 12 LW * ?", []string{"Fri May 31 12:00:00 2024", "Fri Jun 28 12:00:00 2024", "Wed Jul 31 12:00:00 2024", "Fri Aug 30 12:00:00 2024"}},
+		{"0 0 12 1W * ?", []string{"Mon Jun 3 12:00:00 2024", "Mon Jul 1 12:00:00 2024", "Thu Aug 1 12:00:00 2024", "Mon Sep 2 12:00:00 2024"}},
+		{"0 0 12 15W * ?", []string{"Fri Jun 14 12:00:00 2024", "Mon Jul 15 12:00:00 2024", "Thu Aug 15 12:00:00 2024", "Mon Sep 16 12:00:00 2024"}},
+		{"0 0 12 ? * 6L", []string{"Fri May 31 12:00:00 2024", "Fri Jun 28 12:00:00 2024", "Fri Jul 26 12:00:00 2024", "Fri Aug 30 12:00:00 2024"}},
+		{"0 0 12 ? * friL", []string{"Fri May 31 12:00:00 2024", "Fri Jun 28 12:00:00 2024", "Fri Jul 26 12:00:00 2024", "Fri Aug 30 12:00:00 2024"}},
+		{"0 0 12 ? * FRI#3", []string{"Fri May 17 12:00:00 2024", "Fri Jun 21 12:00:00 2024", "Fri Jul 19 12:00:00 2024", "Fri Aug 16 12:00:00 2024"}},
+		{"0 0 12 ? * 2#1", []string{"Mon Jun 3 12:00:00 2024", "Mon Jul 1 12:00:00 2024", "Mon Aug 5 12:00:00 2024", "Mon Sep 2 12:00:00 2024"}},
+		{"0 0 12 ? * SUN#5", []string{"Sun Jun 30 12:00:00 2024", "Sun Sep 29 12:00:00 2024", "Sun Dec 29 12:00:00 2024", "Sun Mar 30 12:00:00 2025"}},
+		{"0 0 12 ? * L", []string{"Sat May 18 12:00:00 2024", "Sat May 25 12:00:00 2024", "Sat Jun 1 12:00:00 2024", "Sat Jun 8 12:00:00 2024"}},
+		{"0 0 12 1 1 ? 2030,2032", []string{"Tue Jan 1 12:00:00 2030", "Thu Jan 1 12:00:00 2032"}},
+		{"0 0 0 29 2 ? 2025/3", []string{"Tue Feb 29 00:00:00 2028", "Wed Feb 29 00:00:00 2040", "Thu Feb 29 00:00:00 2052", "Fri Feb 29 00:00:00 2064"}},
+		{"0 0 0 1 1 ? 2024", []string{}},
+	}
+
+	for _, test := range tests {
+		t.Run(test.expression, func(t *testing.T) {
+			cronTrigger, err := crontrigger.New(test.expression)
+			if err != nil {
+				t.Fatal(err)
+			}
+			next := prev
+			for _, expected := range test.expected {
+				next, err = cronTrigger.NextFireTime(next)
+				if err != nil {
+					t.Fatal(err)
+				}
+				assert.Equal(t, expected, next.Format(readDateLayout))
+			}
+			if len(test.expected) < 4 {
+				_, err = cronTrigger.NextFireTime(next)
+				assert.ErrorIs(t, err, triggers.ErrTriggerExpired)
+			}
+		})
+	}
+}
+
+func TestCronQuartzInvalidExpressions(t *testing.T) {
+	expressions := []string{
+		"0 0 0 L,5 * ?",
+		"0 0 0 L-31 * ?",
+		"0 0 0 32W * ?",
+		"0 0 0 1-5W * ?",
+		"0 0 0 ? * MON#6",
+		"0 0 0 ? * MON#0",
+		"0 0 0 ? * 8L",
+		"0 0 0 ? * XL",
+		"0 0 0 * * ? 1969",
+		"0 0 0 * * ? 2100",
+	}
+	for _, expression := range expressions {
+		_, err := crontrigger.New(expression)
+		assert.Error(t, err, expression)
+	}
+}
+
+func TestCronStandardAndDescriptorExpressions(t *testing.T) {
+	prev := time.Date(2024, 5, 17, 18, 0, 0, 0, time.UTC)
+	tests := []struct {
+		expression string
+		expected   []string
+	}{
+		{"30 9 * * 1-5", []string{"Mon May 20 09:30:00 2024", "Tue May 21 09:30:00 2024", "Wed May 22 09:30:00 2024", "Thu May 23 09:30:00 2024"}},
+		{"30 9 * * MON-FRI", []string{"Mon May 20 09:30:00 2024", "Tue May 21 09:30:00 2024", "Wed May 22 09:30:00 2024", "Thu May 23 09:30:00 2024"}},
+		{"0 0 1,15 * 0", []string{"Sun May 19 00:00:00 2024", "Sun May 26 00:00:00 2024", "Sat Jun 1 00:00:00 2024", "Sun Jun 2 00:00:00 2024"}},
+		{"0 0 * * 7", []string{"Sun May 19 00:00:00 2024", "Sun May 26 00:00:00 2024", "Sun Jun 2 00:00:00 2024", "Sun Jun 9 00:00:00 2024"}},
+		{"*/15 * * * *", []string{"Fri May 17 18:15:00 2024", "Fri May 17 18:30:00 2024", "Fri May 17 18:45:00 2024", "Fri May 17 19:00:00 2024"}},
+		{"* 9 * * *", []string{"Sat May 18 09:00:00 2024", "Sat May 18 09:01:00 2024", "Sat May 18 09:02:00 2024", "Sat May 18 09:03:00 2024"}},
+		{"1-10/4 12 * * *", []string{"Sat May 18 12:01:00 2024", "Sat May 18 12:05:00 2024", "Sat May 18 12:09:00 2024", "Sun May 19 12:01:00 2024"}},
+		{"0 1-10/4,30 12 * * ?", []string{"Sat May 18 12:01:00 2024", "Sat May 18 12:05:00 2024", "Sat May 18 12:09:00 2024", "Sat May 18 12:30:00 2024"}},
+		{"0 0 0 ? * MON-WED/2,SAT", []string{"Sat May 18 00:00:00 2024", "Mon May 20 00:00:00 2024", "Wed May 22 00:00:00 2024", "Sat May 25 00:00:00 2024"}},
+		{"@annually", []string{"Wed Jan 1 00:00:00 2025", "Thu Jan 1 00:00:00 2026", "Fri Jan 1 00:00:00 2027", "Sat Jan 1 00:00:00 2028"}},
+		{"@midnight", []string{"Sat May 18 00:00:00 2024", "Sun May 19 00:00:00 2024", "Mon May 20 00:00:00 2024", "Tue May 21 00:00:00 2024"}},
+		{"@every 1h30m", []string{"Fri May 17 19:30:00 2024", "Fri May 17 21:00:00 2024", "Fri May 17 22:30:00 2024", "Sat May 18 00:00:00 2024"}},
+	}
+
+	for _, test := range tests {
+		t.Run(test.expression, func(t *testing.T) {
+			cronTrigger, err := crontrigger.New(test.expression)
+			if err != nil {
+				t.Fatal(err)
+			}
+			next := prev
+			for _, expected := range test.expected {
+				next, err = cronTrigger.NextFireTime(next)
+				if err != nil {
+					t.Fatal(err)
+				}
+				assert.Equal(t, expected, next.Format(readDateLayout))
+			}
+		})
+	}
+}
+
+func TestCronInvalidStandardAndDescriptorExpressions(t *testing.T) {
+	expressions := []string{
+		"* * * *",
+		"*/0 * * * *",
+		"1-10/ * * * *",
+		"10-1 * * * *",
+		"0 0 * * 8",
+		"0 1-10/0 * * * ?",
+		"0 1-10-20 * * * ?",
+		"@every 500ms",
+		"@every fortnight",
+		"@sometimes",
+	}
+	for _, expression := range expressions {
+		_, err := crontrigger.New(expression)
+		assert.Error(t, err, expression)
+	}
+}
+
+func TestCronDaylightSavingTransitions(t *testing.T) {
+	tests := []struct {
+		name       string
+		zone       string
+		expression string
+		prev       string
+		expected   []string
+	}{
+		{"skipped time fires after the gap", "America/New_York", "0 30 2 * * ?", "2024-03-09 12:00:00 -0500",
+			[]string{"2024-03-10 03:30:00 -0400", "2024-03-11 02:30:00 -0400"}},
+		{"skipped times fire once", "America/New_York", "0 */30 * * * ?", "2024-03-10 01:00:00 -0500",
+			[]string{"2024-03-10 01:30:00 -0500", "2024-03-10 03:00:00 -0400", "2024-03-10 03:30:00 -0400"}},
+		{"repeated time fires once", "America/New_York", "0 30 1 * * ?", "2024-11-02 12:00:00 -0400",
+			[]string{"2024-11-03 01:30:00 -0400", "2024-11-04 01:30:00 -0500"}},
+		{"repeated time already fired", "America/New_York", "0 30 1 * * ?", "2024-11-03 01:10:00 -0500",
+			[]string{"2024-11-04 01:30:00 -0500"}},
+		{"repeated hour fires twice with wildcard hours", "America/New_York", "*/30 * * * *", "2024-11-03 00:00:00 -0400",
+			[]string{"2024-11-03 00:30:00 -0400", "2024-11-03 01:00:00 -0400", "2024-11-03 01:30:00 -0400",
+				"2024-11-03 01:00:00 -0500", "2024-11-03 01:30:00 -0500", "2024-11-03 02:00:00 -0500"}},
+		{"hourly keeps firing every hour", "America/New_York", "@hourly", "2024-11-03 00:00:00 -0400",
+			[]string{"2024-11-03 01:00:00 -0400", "2024-11-03 01:00:00 -0500", "2024-11-03 02:00:00 -0500"}},
+		{"london spring forward", "Europe/London", "0 30 1 * * ?", "2024-03-30 12:00:00 +0000",
+			[]string{"2024-03-31 02:30:00 +0100", "2024-04-01 01:30:00 +0100"}},
+		{"london fall back", "Europe/London", "0 15 1 * * ?", "2024-10-26 12:00:00 +0100",
+			[]string{"2024-10-27 01:15:00 +0100", "2024-10-28 01:15:00 +0000"}},
+		{"sydney fall back", "Australia/Sydney", "0 */30 * * * ?", "2024-04-07 02:00:00 +1100",
+			[]string{"2024-04-07 02:30:00 +1100", "2024-04-07 02:00:00 +1000", "2024-04-07 02:30:00 +1000", "2024-04-07 03:00:00 +1000"}},
+		{"half hour spring forward", "Australia/Lord_Howe", "0 0/15 2 * * ?", "2024-10-05 12:00:00 +1030",
+			[]string{"2024-10-06 02:30:00 +1100", "2024-10-06 02:45:00 +1100", "2024-10-07 02:00:00 +1100"}},
+		{"half hour fall back", "Australia/Lord_Howe", "0 */15 * * * ?", "2024-04-07 01:00:00 +1100",
+			[]string{"2024-04-07 01:15:00 +1100", "2024-04-07 01:30:00 +1100", "2024-04-07 01:45:00 +1100",
+				"2024-04-07 01:30:00 +1030", "2024-04-07 01:45:00 +1030", "2024-04-07 02:00:00 +1030"}},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			loc, err := time.LoadLocation(test.zone)
+			if err != nil {
+				t.Fatal(err)
+			}
+			cronTrigger, err := crontrigger.NewWithLoc(test.expression, loc)
+			if err != nil {
+				t.Fatal(err)
+			}
+			next, err := time.Parse(zoneDateLayout, test.prev)
+			if err != nil {
+				t.Fatal(err)
+			}
+			for _, expected := range test.expected {
+				next, err = cronTrigger.NextFireTime(next)
+				if err != nil {
+					t.Fatal(err)
+				}
+				assert.Equal(t, expected, next.In(loc).Format(zoneDateLayout))
+			}
+		})
+	}
+}
+
+func TestCronPrevFireTime(t *testing.T) {
+	tests := []struct {
+		name       string
+		zone       string
+		expression string
+		next       string
+		expected   []string
+	}{
+		{"daily", "UTC", "0 15 10 * * ?", "2024-03-02 10:15:00 +0000",
+			[]string{"2024-03-01 10:15:00 +0000", "2024-02-29 10:15:00 +0000"}},
+		{"last friday", "UTC", "0 15 10 ? * 6L", "2024-06-01 00:00:00 +0000",
+			[]string{"2024-05-31 10:15:00 +0000", "2024-04-26 10:15:00 +0000"}},
+		{"standard", "UTC", "30 9 * * 1-5", "2024-05-20 09:30:00 +0000",
+			[]string{"2024-05-17 09:30:00 +0000", "2024-05-16 09:30:00 +0000"}},
+		{"every", "UTC", "@every 1h30m", "2024-05-20 09:30:00 +0000",
+			[]string{"2024-05-20 08:00:00 +0000", "2024-05-20 06:30:00 +0000"}},
+		{"skipped time", "America/New_York", "0 30 2 * * ?", "2024-03-11 00:00:00 -0400",
+			[]string{"2024-03-10 03:30:00 -0400", "2024-03-09 02:30:00 -0500"}},
+		{"repeated hour with wildcard hours", "America/New_York", "0 */30 * * * ?", "2024-11-03 02:00:00 -0500",
+			[]string{"2024-11-03 01:30:00 -0500", "2024-11-03 01:00:00 -0500", "2024-11-03 01:30:00 -0400"}},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			loc, err := time.LoadLocation(test.zone)
+			if err != nil {
+				t.Fatal(err)
+			}
+			cronTrigger, err := crontrigger.NewWithLoc(test.expression, loc)
+			if err != nil {
+				t.Fatal(err)
+			}
+			prev, err := time.Parse(zoneDateLayout, test.next)
+			if err != nil {
+				t.Fatal(err)
+			}
+			for _, expected := range test.expected {
+				prev, err = cronTrigger.PrevFireTime(prev)
+				if err != nil {
+					t.Fatal(err)
+				}
+				assert.Equal(t, expected, prev.In(loc).Format(zoneDateLayout))
+			}
+		})
+	}
+
+	cronTrigger, err := crontrigger.New("0 0 0 1 1 ? 2024")
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, err = cronTrigger.PrevFireTime(time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC))
+	assert.ErrorIs(t, err, triggers.ErrTriggerExpired)
+}
+
+func TestNextFireTimes(t *testing.T) {
+	prev := time.Date(2024, 5, 15, 18, 0, 0, 0, time.UTC)
+	cronTrigger, err := crontrigger.New("0 0 12 ? * MON")
+	if err != nil {
+		t.Fatal(err)
+	}
+	fireTimes, err := triggers.NextFireTimes(cronTrigger, prev, 3)
+	assert.NoError(t, err)
+	assert.Equal(t, []time.Time{
+		time.Date(2024, 5, 20, 12, 0, 0, 0, time.UTC),
+		time.Date(2024, 5, 27, 12, 0, 0, 0, time.UTC),
+		time.Date(2024, 6, 3, 12, 0, 0, 0, time.UTC),
+	}, fireTimes)
+
+	// Triggers that expire return the fire times they have left.
+	cronTrigger, err = crontrigger.New("0 0 12 1 1 ? 2025-2026")
+	if err != nil {
+		t.Fatal(err)
+	}
+	fireTimes, err = triggers.NextFireTimes(cronTrigger, prev, 5)
+	assert.NoError(t, err)
+	assert.Equal(t, []time.Time{
+		time.Date(2025, 1, 1, 12, 0, 0, 0, time.UTC),
+		time.Date(2026, 1, 1, 12, 0, 0, 0, time.UTC),
+	}, fireTimes)
+
+	everyMinute, err := crontrigger.New("0 * * * * ?")
+	if err != nil {
+		t.Fatal(err)
+	}
+	fireTimes, err = triggers.NextFireTimes(everyMinute, prev, triggers.MaxFireTimes)
+	assert.NoError(t, err)
+	assert.Len(t, fireTimes, triggers.MaxFireTimes)
+	_, err = triggers.NextFireTimes(everyMinute, prev, triggers.MaxFireTimes+1)
+	assert.ErrorIs(t, err, triggers.ErrTooManyFireTimes)
+}
+
+func BenchmarkCronNextFireTime(b *testing.B) {
+	expressions := []string{
+		"0 0 12 * * ?",
+		"*/15 * * * *",
+		"0 15 10 ? * 6L",
+		"0 0 0 29 2 ?",
+	}
+	loc, err := time.LoadLocation("America/New_York")
+	if err != nil {
+		b.Fatal(err)
+	}
+	for _, expression := range expressions {
+		b.Run(expression, func(b *testing.B) {
+			cronTrigger, err := crontrigger.NewWithLoc(expression, loc)
+			if err != nil {
+				b.Fatal(err)
+			}
+			prev := time.Date(2024, 5, 15, 18, 0, 0, 0, time.UTC)
+			b.ReportAllocs()
+			for i := 0; i < b.N; i++ {
+				if _, err := cronTrigger.NextFireTime(prev); err != nil {
+					b.Fatal(err)
+				}
+			}
+		})
+	}
+}
+
+func BenchmarkCronParse(b *testing.B) {
+	b.ReportAllocs()
+	for i := 0; i < b.N; i++ {
+		if _, err := crontrigger.New("0 0/5 14,18 ? JAN-MAR MON-FRI"); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
+
 var readDateLayout = "Mon Jan 2 15:04:05 2006"
 
+var zoneDateLayout = "2006-01-02 15:04:05 -0700"
+
 func iterate(prev time.Time, cronTrigger *crontrigger.CronTrigger, iterations int) (string, error) {
 	var err error
 	for i := 0; i < iterations; i++ {
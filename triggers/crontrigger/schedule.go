@@ -0,0 +1,414 @@
+package crontrigger
+
+import (
+	"math/bits"
+	"time"
+)
+
+const (
+	minYear = 1970
+	maxYear = 2099
+)
+
+// schedule is the compiled form of a cron expression.  The values of each field are kept as a bitset, so finding the
+// next or previous fire time is a handful of bit operations per field and does not allocate.
+type schedule struct {
+	seconds     uint64 // 0-59
+	minutes     uint64 // 0-59
+	hours       uint64 // 0-23
+	daysOfMonth uint64 // 1-31
+	months      uint64 // 1-12
+	daysOfWeek  uint64 // 0-6, numbered like time.Weekday
+	years       [3]uint64
+	anyYear     bool
+
+	// anyHour is set when the hours field is "*", which changes how repeated wall clock times fire.
+	anyHour bool
+	// dayOr is set for standard expressions that restrict both day fields, which match a day if either field does.
+	dayOr bool
+
+	// Quartz day modifiers, see CronField.
+	lastDayOfMonth   bool
+	lastDayOffset    int
+	nearestWeekday   bool
+	lastDayOfWeek    bool
+	nthDayOfWeek     int
+	nearestWeekdayTo int
+}
+
+func compileSchedule(fields []*CronField, standard bool) schedule {
+	dayOfMonth, dayOfWeek := fields[dayOfMonthIndex], fields[dayOfWeekIndex]
+	s := schedule{
+		seconds:     bitset(fields[secondIndex].values, 0, 59),
+		minutes:     bitset(fields[minuteIndex].values, 0, 59),
+		hours:       bitset(fields[hourIndex].values, 0, 23),
+		daysOfMonth: bitset(dayOfMonth.values, 1, 31),
+		months:      bitset(fields[monthIndex].values, 1, 12),
+		daysOfWeek:  bitset(dayOfWeek.values, 0, 6),
+		anyYear:     fields[yearIndex].isEmpty(),
+		anyHour:     fields[hourIndex].isEmpty(),
+		dayOr:       standard && !dayOfMonth.isEmpty() && !dayOfWeek.isEmpty(),
+
+		lastDayOfMonth: dayOfMonth.last,
+		lastDayOffset:  dayOfMonth.lastOffset,
+		nearestWeekday: dayOfMonth.weekday,
+		lastDayOfWeek:  dayOfWeek.last,
+		nthDayOfWeek:   dayOfWeek.nth,
+	}
+	if dayOfMonth.weekday && !dayOfMonth.last {
+		s.nearestWeekdayTo = dayOfMonth.values[0]
+	}
+	for _, y := range fields[yearIndex].values {
+		s.years[(y-minYear)/64] |= 1 << ((y - minYear) % 64)
+	}
+	return s
+}
+
+// bitset returns the values as a bitset, or every value from min to max if there are none.
+func bitset(values []int, min, max int) uint64 {
+	var b uint64
+	if len(values) == 0 {
+		for v := min; v <= max; v++ {
+			b |= 1 << v
+		}
+		return b
+	}
+	for _, v := range values {
+		b |= 1 << v
+	}
+	return b
+}
+
+// nextBit returns the lowest bit set in b that is in the range [v, max].
+func nextBit(b uint64, v, max int) (int, bool) {
+	if v > max || v > 63 {
+		return 0, false
+	}
+	b >>= uint(v)
+	if b == 0 {
+		return 0, false
+	}
+	n := v + bits.TrailingZeros64(b)
+	return n, n <= max
+}
+
+// prevBit returns the highest bit set in b that is in the range [min, v].
+func prevBit(b uint64, v, min int) (int, bool) {
+	if v < min {
+		return 0, false
+	}
+	if v > 63 {
+		v = 63
+	}
+	b <<= uint(63 - v)
+	if b == 0 {
+		return 0, false
+	}
+	n := v - bits.LeadingZeros64(b)
+	return n, n >= min
+}
+
+func (s *schedule) hasYear(year int) bool {
+	if s.anyYear {
+		return true
+	}
+	if year < minYear || year > maxYear {
+		return false
+	}
+	return s.years[(year-minYear)/64]&(1<<((year-minYear)%64)) != 0
+}
+
+// nextYear returns the first year of the schedule in the range [year, until].
+func (s *schedule) nextYear(year, until int) (int, bool) {
+	if !s.anyYear {
+		year = max(year, minYear)
+		until = min(until, maxYear)
+	}
+	for ; year <= until; year++ {
+		if s.hasYear(year) {
+			return year, true
+		}
+	}
+	return 0, false
+}
+
+// prevYear returns the last year of the schedule in the range [from, year].
+func (s *schedule) prevYear(year, from int) (int, bool) {
+	if !s.anyYear {
+		year = min(year, maxYear)
+		from = max(from, minYear)
+	}
+	for ; year >= from; year-- {
+		if s.hasYear(year) {
+			return year, true
+		}
+	}
+	return 0, false
+}
+
+// days returns the days of the month that match both day fields as a bitset.
+func (s *schedule) days(year, month int) uint64 {
+	lastDay := maxDays(month, year)
+	inMonth := uint64(1)<<(lastDay+1) - 2
+
+	var dayOfMonth uint64
+	switch {
+	case s.lastDayOfMonth && s.nearestWeekday:
+		dayOfMonth = 1 << nearestWeekday(year, month, lastDay, lastDay)
+	case s.lastDayOfMonth:
+		if d := lastDay - s.lastDayOffset; d >= 1 {
+			dayOfMonth = 1 << d
+		}
+	case s.nearestWeekday:
+		if s.nearestWeekdayTo <= lastDay {
+			dayOfMonth = 1 << nearestWeekday(year, month, s.nearestWeekdayTo, lastDay)
+		}
+	default:
+		dayOfMonth = s.daysOfMonth & inMonth
+	}
+
+	var dayOfWeek uint64
+	first := int(weekday(year, month, 1))
+	for w := 0; w < 7; w++ {
+		if s.daysOfWeek&(1<<w) == 0 {
+			continue
+		}
+		d := 1 + (w-first+7)%7
+		switch {
+		case s.nthDayOfWeek > 0:
+			if d += 7 * (s.nthDayOfWeek - 1); d <= lastDay {
+				dayOfWeek |= 1 << d
+			}
+		case s.lastDayOfWeek:
+			for d+7 <= lastDay {
+				d += 7
+			}
+			dayOfWeek |= 1 << d
+		default:
+			for ; d <= lastDay; d += 7 {
+				dayOfWeek |= 1 << d
+			}
+		}
+	}
+
+	if s.dayOr {
+		return dayOfMonth | dayOfWeek
+	}
+	return dayOfMonth & dayOfWeek
+}
+
+// next finds the first instant after prev whose wall clock time in prev's location matches the schedule.
+// Around daylight saving transitions, a wall clock time can be skipped or repeated:
+//
+//   - A skipped time fires when the clocks have moved forward, shifted by the length of the gap, so a job at 2:30am fires
+//     at 3:30am when the clocks go from 2am to 3am.  Several skipped times that shift onto the same instant fire once.
+//   - A repeated time fires only on its first occurrence, unless the hours field is "*", in which case it fires on both
+//     occurrences, so schedules that run several times an hour keep running while the hour repeats.
+func (s *schedule) next(prev time.Time) (time.Time, bool) {
+	wall := wallClock(prev)
+	for {
+		var ok bool
+		wall, ok = s.nextWallTime(wall)
+		if !ok {
+			return time.Time{}, false
+		}
+
+		next, ok := s.resolveWallTime(wall, prev, false)
+		if !ok {
+			continue
+		}
+
+		// When the clocks go back between prev and next, the wall clock times already passed come around again.
+		if _, end := prev.ZoneBounds(); s.anyHour && !end.IsZero() && !end.After(next) && isFallBack(end) {
+			if again, ok := s.nextWallTime(wallClock(end).Add(-time.Second)); ok {
+				_, offset := end.Zone()
+				if t := time.Unix(again.Unix()-int64(offset), 0).In(prev.Location()); t.After(prev) && t.Before(next) {
+					return t, true
+				}
+			}
+		}
+		return next, true
+	}
+}
+
+// prev finds the last instant before next whose wall clock time in next's location matches the schedule, following
+// the same rules as next, so that s.prev(s.next(t)) is the last fire time at or before t.
+func (s *schedule) prev(next time.Time) (time.Time, bool) {
+	wall := wallClock(next)
+	if next.Nanosecond() > 0 {
+		wall = wall.Add(time.Second)
+	}
+	for {
+		var ok bool
+		wall, ok = s.prevWallTime(wall)
+		if !ok {
+			return time.Time{}, false
+		}
+
+		prev, ok := s.resolveWallTime(wall, next, true)
+		if !ok {
+			continue
+		}
+
+		// When the clocks went back between prev and next, the wall clock times still to come were passed already.
+		if start, _ := next.ZoneBounds(); s.anyHour && !start.IsZero() && start.After(prev) && isFallBack(start) {
+			if again, ok := s.prevWallTime(wallClock(start.Add(-time.Second)).Add(time.Second)); ok {
+				_, offset := start.Add(-time.Second).Zone()
+				if t := time.Unix(again.Unix()-int64(offset), 0).In(next.Location()); t.Before(next) && t.After(prev) {
+					return t, true
+				}
+			}
+		}
+		return prev, true
+	}
+}
+
+// resolveWallTime returns the instant a wall clock time fires at, following the rules of next.  The instant must be
+// after the bound, or before it when searching backwards, in which case the latest valid instant is chosen.  It returns
+// false if there is no valid instant.
+func (s *schedule) resolveWallTime(wall time.Time, bound time.Time, backwards bool) (time.Time, bool) {
+	loc := bound.Location()
+	valid := func(t time.Time) bool {
+		if backwards {
+			return t.Before(bound)
+		}
+		return t.After(bound)
+	}
+	instants, n := localTimes(wall, loc)
+	switch n {
+	case 0:
+		// Skipped: use the offset from before the clocks moved forward, which is the smaller one.
+		_, before := time.Unix(wall.Unix()-maxZoneOffset, 0).In(loc).Zone()
+		_, after := time.Unix(wall.Unix()+maxZoneOffset, 0).In(loc).Zone()
+		t := time.Unix(wall.Unix()-int64(min(before, after)), 0).In(loc)
+		return t, valid(t)
+	case 1:
+		return instants[0], valid(instants[0])
+	}
+
+	// Repeated: only the first occurrence fires, unless any hour matches.
+	if !s.anyHour {
+		return instants[0], valid(instants[0])
+	}
+	if backwards {
+		instants[0], instants[1] = instants[1], instants[0]
+	}
+	if valid(instants[0]) {
+		return instants[0], true
+	}
+	return instants[1], valid(instants[1])
+}
+
+// nextWallTime finds the first wall clock time after the given one that matches the schedule.  Wall clock times are
+// represented in UTC, so they are free of any daylight saving transitions.  The calendar is walked from the most
+// significant field to the least; whenever a field has no matching value left, the next larger field is advanced and
+// the smaller fields start over from their lowest value.
+func (s *schedule) nextWallTime(prev time.Time) (time.Time, bool) {
+	year, m, day := prev.Date()
+	hour, minute, second := prev.Clock()
+	month := int(m)
+	second++
+
+	last := year + searchYears
+	for year <= last {
+		if y, ok := s.nextYear(year, last); !ok {
+			break
+		} else if y > year {
+			year, month, day, hour, minute, second = y, 1, 1, 0, 0, 0
+		}
+
+		if mo, ok := nextBit(s.months, month, 12); !ok {
+			year, month, day, hour, minute, second = year+1, 1, 1, 0, 0, 0
+			continue
+		} else if mo > month {
+			month, day, hour, minute, second = mo, 1, 0, 0, 0
+		}
+
+		if d, ok := nextBit(s.days(year, month), day, 31); !ok {
+			month, day, hour, minute, second = month+1, 1, 0, 0, 0
+			continue
+		} else if d > day {
+			day, hour, minute, second = d, 0, 0, 0
+		}
+
+		if h, ok := nextBit(s.hours, hour, 23); !ok {
+			day, hour, minute, second = day+1, 0, 0, 0
+			continue
+		} else if h > hour {
+			hour, minute, second = h, 0, 0
+		}
+
+		if mi, ok := nextBit(s.minutes, minute, 59); !ok {
+			hour, minute, second = hour+1, 0, 0
+			continue
+		} else if mi > minute {
+			minute, second = mi, 0
+		}
+
+		if sec, ok := nextBit(s.seconds, second, 59); !ok {
+			minute, second = minute+1, 0
+			continue
+		} else {
+			second = sec
+		}
+
+		return time.Date(year, time.Month(month), day, hour, minute, second, 0, time.UTC), true
+	}
+	return time.Time{}, false
+}
+
+// prevWallTime finds the last wall clock time before the given one that matches the schedule, walking the calendar
+// backwards like nextWallTime walks it forwards.
+func (s *schedule) prevWallTime(next time.Time) (time.Time, bool) {
+	year, m, day := next.Date()
+	hour, minute, second := next.Clock()
+	month := int(m)
+	second--
+
+	first := year - searchYears
+	for year >= first {
+		if y, ok := s.prevYear(year, first); !ok {
+			break
+		} else if y < year {
+			year, month, day, hour, minute, second = y, 12, 31, 23, 59, 59
+		}
+
+		if mo, ok := prevBit(s.months, month, 1); !ok {
+			year, month, day, hour, minute, second = year-1, 12, 31, 23, 59, 59
+			continue
+		} else if mo < month {
+			month, day, hour, minute, second = mo, 31, 23, 59, 59
+		}
+
+		if d, ok := prevBit(s.days(year, month), day, 1); !ok {
+			month, day, hour, minute, second = month-1, 31, 23, 59, 59
+			continue
+		} else if d < day {
+			day, hour, minute, second = d, 23, 59, 59
+		}
+
+		if h, ok := prevBit(s.hours, hour, 0); !ok {
+			day, hour, minute, second = day-1, 23, 59, 59
+			continue
+		} else if h < hour {
+			hour, minute, second = h, 59, 59
+		}
+
+		if mi, ok := prevBit(s.minutes, minute, 0); !ok {
+			hour, minute, second = hour-1, 59, 59
+			continue
+		} else if mi < minute {
+			minute, second = mi, 59
+		}
+
+		if sec, ok := prevBit(s.seconds, second, 0); !ok {
+			minute, second = minute-1, 59
+			continue
+		} else {
+			second = sec
+		}
+
+		return time.Date(year, time.Month(month), day, hour, minute, second, 0, time.UTC), true
+	}
+	return time.Time{}, false
+}
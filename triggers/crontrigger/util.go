@@ -1,55 +1,14 @@
 package crontrigger
 
 import (
-	"fmt"
+	"sort"
 	"strconv"
 	"strings"
+	"time"
 
 	"github.com/jswidler/gorun/errors"
 )
 
-func indexes(search []string, target []string) ([]int, error) {
-	searchIndexes := make([]int, 0, len(search))
-	for _, a := range search {
-		index := intVal(target, a)
-		if index == -1 {
-			return nil, fmt.Errorf("invalid cron field: %s", a)
-		}
-		searchIndexes = append(searchIndexes, index)
-	}
-
-	return searchIndexes, nil
-}
-
-func sliceAtoi(sa []string) ([]int, error) {
-	si := make([]int, 0, len(sa))
-	for _, a := range sa {
-		i, err := strconv.Atoi(a)
-		if err != nil {
-			return si, err
-		}
-
-		si = append(si, i)
-	}
-
-	return si, nil
-}
-
-func fillRange(from, to int) ([]int, error) {
-	if to < from {
-		return nil, cronError("fillRange")
-	}
-
-	len := (to - from) + 1
-	arr := make([]int, len)
-
-	for i, j := from, 0; i <= to; i, j = i+1, j+1 {
-		arr[j] = i
-	}
-
-	return arr, nil
-}
-
 func fillStep(from, step, max int) ([]int, error) {
 	if max < from {
 		return nil, cronError("fillStep")
@@ -65,6 +24,18 @@ func fillStep(from, step, max int) ([]int, error) {
 	return arr, nil
 }
 
+// uniq sorts the values and removes duplicates.
+func uniq(values []int) []int {
+	sort.Ints(values)
+	u := values[:0]
+	for _, v := range values {
+		if len(u) == 0 || v != u[len(u)-1] {
+			u = append(u, v)
+		}
+	}
+	return u
+}
+
 func normalize(field string, dict []string) int {
 	i, err := strconv.Atoi(field)
 	if err == nil {
@@ -86,24 +57,6 @@ func cronError(cause string) error {
 	return errors.Newf("invalid cron expression: %s", cause)
 }
 
-// alignDigit justifies single digit values.
-func alignDigit(next int) string {
-	if next < 10 {
-		return "0" + strconv.Itoa(next)
-	}
-
-	return strconv.Itoa(next)
-}
-
-func step(prev, next, max int) int {
-	diff := next - prev
-	if diff < 0 {
-		return diff + max
-	}
-
-	return diff
-}
-
 func intVal(target []string, search string) int {
 	uSearch := strings.ToUpper(search)
 	for i, v := range target {
@@ -115,12 +68,6 @@ func intVal(target []string, search string) int {
 	return -1 // TODO: return error
 }
 
-// atoi implements an unsafe strconv.Atoi.
-func atoi(str string) int {
-	i, _ := strconv.Atoi(str)
-	return i
-}
-
 func maxDays(month, year int) int {
 	if month == 2 && isLeapYear(year) {
 		return 29
@@ -140,3 +87,61 @@ func isLeapYear(year int) bool {
 	}
 	return true
 }
+
+func weekday(year, month, day int) time.Weekday {
+	return time.Date(year, time.Month(month), day, 0, 0, 0, 0, time.UTC).Weekday()
+}
+
+// nearestWeekday returns the weekday closest to the given day without leaving the month.
+func nearestWeekday(year, month, day, lastDay int) int {
+	switch weekday(year, month, day) {
+	case time.Saturday:
+		if day == 1 {
+			return day + 2
+		}
+		return day - 1
+	case time.Sunday:
+		if day == lastDay {
+			return day - 2
+		}
+		return day + 1
+	}
+	return day
+}
+
+// maxZoneOffset is larger than the difference between any two offsets of a location, in seconds.
+const maxZoneOffset = 26 * 60 * 60
+
+// wallClock returns the time shown on a clock in t's location, as a UTC time.
+func wallClock(t time.Time) time.Time {
+	year, month, day := t.Date()
+	hour, minute, second := t.Clock()
+	return time.Date(year, month, day, hour, minute, second, 0, time.UTC)
+}
+
+// localTimes returns the instants at which a clock in loc shows the wall clock time, in order.  There are none if the
+// time is skipped when the clocks move forward, and two if it is repeated when they move back.
+func localTimes(wall time.Time, loc *time.Location) ([2]time.Time, int) {
+	var instants [2]time.Time
+	n := 0
+	for _, probe := range []int64{wall.Unix() - maxZoneOffset, wall.Unix() + maxZoneOffset} {
+		_, offset := time.Unix(probe, 0).In(loc).Zone()
+		t := time.Unix(wall.Unix()-int64(offset), 0).In(loc)
+		if _, actual := t.Zone(); actual != offset || (n == 1 && t.Equal(instants[0])) {
+			continue
+		}
+		instants[n] = t
+		n++
+	}
+	if n == 2 && instants[1].Before(instants[0]) {
+		instants[0], instants[1] = instants[1], instants[0]
+	}
+	return instants, n
+}
+
+// isFallBack checks if the clocks move back at the start of the zone period containing t.
+func isFallBack(t time.Time) bool {
+	_, before := t.Add(-time.Second).Zone()
+	_, after := t.Zone()
+	return after < before
+}
@@ -0,0 +1,76 @@
+package triggers
+
+import (
+	"encoding/json"
+	"strings"
+	"time"
+
+	"github.com/jswidler/gorun/errors"
+)
+
+func init() {
+	RegisterTriggerHandler(&EventTrigger{})
+}
+
+var ErrInvalidEventTrigger = errors.Sentinel("invalid event trigger")
+
+// EventTrigger fires when an application fires its event, rather than on a schedule.
+type EventTrigger struct {
+	Event string
+	// Delay is how long after the event the job runs.
+	Delay time.Duration `json:",omitempty"`
+	// Debounce collapses events into the job that has not started yet, which is moved to Delay after the last event.
+	Debounce bool `json:",omitempty"`
+	// Throttle is the least time between the jobs of the trigger.  Events during that time collapse into one job.
+	Throttle time.Duration `json:",omitempty"`
+}
+
+// Verify EventTrigger satisfies the Trigger interface.
+var _ Trigger = (*EventTrigger)(nil)
+
+// NewEventTrigger returns a new EventTrigger for the named event.
+func NewEventTrigger(event string) *EventTrigger {
+	return &EventTrigger{
+		Event: event,
+	}
+}
+
+func (et *EventTrigger) Type() string {
+	return "event"
+}
+
+// Validate checks the trigger can be used.
+func (et *EventTrigger) Validate() error {
+	if et.Event == "" {
+		return errors.Wrap(ErrInvalidEventTrigger, errors.WithMessage("event name is required"))
+	}
+	if strings.HasPrefix(et.Event, "gorun:") {
+		return errors.Wrap(ErrInvalidEventTrigger, errors.WithMessagef("event names starting with gorun: are reserved, got %s", et.Event))
+	}
+	if et.Delay < 0 || et.Throttle < 0 {
+		return errors.Wrap(ErrInvalidEventTrigger, errors.WithMessagef("event %s has a negative delay or throttle", et.Event))
+	}
+	if et.Debounce && et.Throttle > 0 {
+		return errors.Wrap(ErrInvalidEventTrigger, errors.WithMessagef("event %s can not be both debounced and throttled", et.Event))
+	}
+	return nil
+}
+
+// NextFireTime returns the time at which the job for an event fired at prev runs.
+func (et *EventTrigger) NextFireTime(prev time.Time) (time.Time, error) {
+	return prev.Add(et.Delay), nil
+}
+
+func (et *EventTrigger) Serialize() (string, error) {
+	data, err := json.Marshal(et)
+	return string(data), errors.Wrap(err)
+}
+
+func (et *EventTrigger) Deserialize(data string) (Trigger, error) {
+	trig := EventTrigger{}
+	err := json.Unmarshal([]byte(data), &trig)
+	if err != nil {
+		return nil, errors.Wrap(err)
+	}
+	return &trig, nil
+}
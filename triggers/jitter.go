@@ -0,0 +1,31 @@
+package triggers
+
+import (
+	"hash/fnv"
+	"math/rand/v2"
+	"time"
+)
+
+// JitterMode decides how the offset within a jitter window is chosen.
+type JitterMode string
+
+const (
+	// JitterRandom picks a new random offset for each fire time.
+	JitterRandom JitterMode = "random"
+	// JitterSpread picks the same offset every time for a key, such as a trigger id, so that each trigger keeps a
+	// regular period while triggers with the same schedule are spread over the window.
+	JitterSpread JitterMode = "spread"
+)
+
+// Jitter returns the offset, from zero up to but not including window, to add to a fire time.
+func Jitter(mode JitterMode, window time.Duration, key string) time.Duration {
+	if window <= 0 {
+		return 0
+	}
+	if mode == JitterSpread {
+		h := fnv.New64a()
+		h.Write([]byte(key))
+		return time.Duration(h.Sum64() % uint64(window))
+	}
+	return rand.N(window)
+}
@@ -0,0 +1,27 @@
+package triggers_test
+
+import (
+	"testing"
+	"time"
+
+	"github.com/jswidler/gorun/triggers"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestJitter(t *testing.T) {
+	window := 10 * time.Minute
+	for i := 0; i < 100; i++ {
+		offset := triggers.Jitter(triggers.JitterRandom, window, "trigger")
+		assert.GreaterOrEqual(t, offset, time.Duration(0))
+		assert.Less(t, offset, window)
+	}
+
+	a := triggers.Jitter(triggers.JitterSpread, window, "01HZX3K6Q9TPN0W5V8C2D4F7GA")
+	b := triggers.Jitter(triggers.JitterSpread, window, "01HZX3K6Q9TPN0W5V8C2D4F7GB")
+	assert.Equal(t, a, triggers.Jitter(triggers.JitterSpread, window, "01HZX3K6Q9TPN0W5V8C2D4F7GA"))
+	assert.NotEqual(t, a, b)
+	assert.Less(t, a, window)
+
+	assert.Equal(t, time.Duration(0), triggers.Jitter(triggers.JitterSpread, 0, "trigger"))
+	assert.Equal(t, time.Duration(0), triggers.Jitter(triggers.JitterRandom, -time.Minute, "trigger"))
+}
@@ -0,0 +1,377 @@
+package rruletrigger
+
+import (
+	"encoding/json"
+	"strings"
+	"time"
+
+	"github.com/jswidler/gorun/errors"
+	"github.com/jswidler/gorun/triggers"
+	"github.com/teambition/rrule-go"
+)
+
+func init() {
+	triggers.RegisterTriggerHandler(&RRuleTrigger{})
+}
+
+// RRuleTrigger implements the triggers.Trigger interface for RFC 5545 recurrences.
+//
+// A recurrence is given as lines separated by newlines, the way they appear in an iCalendar event:
+//
+//	DTSTART;TZID=America/New_York:20240102T090000
+//	RRULE:FREQ=MONTHLY;BYDAY=TU;BYSETPOS=2
+//	EXDATE;TZID=America/New_York:20240813T090000
+//	RDATE;TZID=America/New_York:20240815T090000
+//
+// DTSTART is required, since it sets the time of day and phase of the rule, and COUNT is counted from it.  There may
+// be at most one RRULE, and any number of RDATE and EXDATE lines, each with a list of comma separated times.  The
+// "RRULE:" prefix may be left off of the rule.  Times without a TZID or a trailing Z are in the trigger's location.
+//
+// Some examples:
+//
+//	RRULE:FREQ=MONTHLY;BYDAY=2TU                                    The second Tuesday of every month
+//	RRULE:FREQ=MONTHLY;BYMONTH=3,6,9,12;BYDAY=MO,TU,WE,TH,FR;BYSETPOS=-1
+//	                                                                The last business day of every quarter
+//	RRULE:FREQ=WEEKLY;INTERVAL=2;BYDAY=MO,WE;COUNT=10               Every other Monday and Wednesday, ten times
+//	RRULE:FREQ=YEARLY;BYMONTH=11;BYDAY=4TH                          Thanksgiving in the United States
+//
+// Wall clock times skipped by a daylight saving transition fire after the clocks have moved forward, and repeated
+// times fire on their first occurrence.
+type RRuleTrigger struct {
+	recurrence string
+	location   *time.Location
+
+	// wallClock holds the occurrences of the recurrence as wall clock times in zone, represented in UTC, so the
+	// rrule package never has to deal with daylight saving transitions.
+	wallClock *rrule.Set
+	zone      *time.Location
+	// rule is the RRULE of wallClock with the defaults taken from DTSTART filled in, so it can be restarted at a later
+	// period.  It is nil when there is no RRULE, or it has a COUNT, which is counted from DTSTART.
+	rule *rrule.ROption
+}
+
+// Verify RRuleTrigger satisfies the Trigger interface.
+var _ triggers.Trigger = (*RRuleTrigger)(nil)
+var _ triggers.PrevFireTimer = (*RRuleTrigger)(nil)
+var _ triggers.InclusiveStarter = (*RRuleTrigger)(nil)
+
+// New returns a new RRuleTrigger using the UTC location.
+func New(recurrence string) (*RRuleTrigger, error) {
+	return NewWithLoc(recurrence, time.UTC)
+}
+
+// NewWithLoc returns a new RRuleTrigger with the given time.Location for times that do not name a time zone.
+func NewWithLoc(recurrence string, location *time.Location) (*RRuleTrigger, error) {
+	lines, err := splitRecurrence(recurrence)
+	if err != nil {
+		return nil, err
+	}
+	set, err := rrule.StrSliceToRRuleSetInLoc(lines, location)
+	if err != nil {
+		return nil, rruleError(err.Error())
+	}
+	wallClock, err := toWallClock(set)
+	if err != nil {
+		return nil, err
+	}
+	rt := &RRuleTrigger{
+		recurrence: set.String(),
+		location:   location,
+		wallClock:  wallClock,
+		zone:       set.GetDTStart().Location(),
+	}
+	if r := wallClock.GetRRule(); r != nil && r.OrigOptions.Count == 0 {
+		rule := withDefaults(r.OrigOptions)
+		rt.rule = &rule
+	}
+	return rt, nil
+}
+
+// toWallClock converts every time in the set to its wall clock time in the time zone of DTSTART.
+func toWallClock(set *rrule.Set) (*rrule.Set, error) {
+	zone := set.GetDTStart().Location()
+	wallClock := &rrule.Set{}
+	wallClock.DTStart(wallTime(set.GetDTStart(), zone))
+	if r := set.GetRRule(); r != nil {
+		options := r.OrigOptions
+		options.Dtstart = wallTime(options.Dtstart, zone)
+		if !options.Until.IsZero() {
+			options.Until = wallTime(options.Until, zone)
+		}
+		rule, err := rrule.NewRRule(options)
+		if err != nil {
+			return nil, rruleError(err.Error())
+		}
+		wallClock.RRule(rule)
+	}
+	for _, t := range set.GetRDate() {
+		wallClock.RDate(wallTime(t, zone))
+	}
+	for _, t := range set.GetExDate() {
+		wallClock.ExDate(wallTime(t, zone))
+	}
+	return wallClock, nil
+}
+
+// wallTime returns the wall clock time of t in the zone, represented in UTC.
+func wallTime(t time.Time, zone *time.Location) time.Time {
+	t = t.In(zone)
+	return time.Date(t.Year(), t.Month(), t.Day(), t.Hour(), t.Minute(), t.Second(), t.Nanosecond(), time.UTC)
+}
+
+// instant returns the time a wall clock time fires at in the zone.  A wall clock time skipped by a daylight saving
+// transition is shifted forward by the length of the gap, and a repeated one is its first occurrence.  time.Date is
+// not used, since it resolves skipped times by moving them backwards.
+func instant(wall time.Time, zone *time.Location) time.Time {
+	_, before := wall.Add(-24 * time.Hour).In(zone).Zone()
+	_, after := wall.Add(24 * time.Hour).In(zone).Zone()
+	early := wall.Add(-time.Duration(max(before, after)) * time.Second)
+	late := wall.Add(-time.Duration(min(before, after)) * time.Second)
+	if wallTime(early, zone).Equal(wall) {
+		return early.In(zone)
+	}
+	if wallTime(late, zone).Equal(wall) {
+		return late.In(zone)
+	}
+	// The wall clock time was skipped, so the offset from before the transition moves it forward.
+	return wall.Add(-time.Duration(before) * time.Second).In(zone)
+}
+
+// withDefaults returns the options of a rule with the values that the rrule package takes from DTSTART set
+// explicitly, so moving DTSTART does not change the occurrences.
+func withDefaults(options rrule.ROption) rrule.ROption {
+	dtstart := options.Dtstart
+	if len(options.Byweekno) == 0 && len(options.Byyearday) == 0 && len(options.Bymonthday) == 0 &&
+		len(options.Byweekday) == 0 && len(options.Byeaster) == 0 {
+		switch options.Freq {
+		case rrule.YEARLY:
+			if len(options.Bymonth) == 0 {
+				options.Bymonth = []int{int(dtstart.Month())}
+			}
+			options.Bymonthday = []int{dtstart.Day()}
+		case rrule.MONTHLY:
+			options.Bymonthday = []int{dtstart.Day()}
+		case rrule.WEEKLY:
+			options.Byweekday = []rrule.Weekday{weekdays[dtstart.Weekday()]}
+		}
+	}
+	if len(options.Byhour) == 0 && options.Freq < rrule.HOURLY {
+		options.Byhour = []int{dtstart.Hour()}
+	}
+	if len(options.Byminute) == 0 && options.Freq < rrule.MINUTELY {
+		options.Byminute = []int{dtstart.Minute()}
+	}
+	if len(options.Bysecond) == 0 && options.Freq < rrule.SECONDLY {
+		options.Bysecond = []int{dtstart.Second()}
+	}
+	return options
+}
+
+var weekdays = []rrule.Weekday{rrule.SU, rrule.MO, rrule.TU, rrule.WE, rrule.TH, rrule.FR, rrule.SA}
+
+// restart returns the options of the rule with DTSTART moved to the start of a period of the rule that begins at least
+// one interval before t, or false if there is no such period after DTSTART.  The occurrences from the new DTSTART
+// on are the same as those of the rule.
+func restart(options rrule.ROption, t time.Time) (rrule.ROption, bool) {
+	dtstart := options.Dtstart
+	interval := max(options.Interval, 1)
+	var periods int
+	var start func(n int) time.Time
+	switch options.Freq {
+	case rrule.YEARLY:
+		periods = t.Year() - dtstart.Year()
+		start = func(n int) time.Time { return time.Date(dtstart.Year()+n, 1, 1, 0, 0, 0, 0, time.UTC) }
+	case rrule.MONTHLY:
+		periods = (t.Year()-dtstart.Year())*12 + int(t.Month()-dtstart.Month())
+		start = func(n int) time.Time {
+			return time.Date(dtstart.Year(), dtstart.Month()+time.Month(n), 1, 0, 0, 0, 0, time.UTC)
+		}
+	case rrule.WEEKLY:
+		// Weeks begin on WKST, and the first one is the week of DTSTART.
+		weekStart := func(t time.Time) time.Time {
+			weekday := (int(t.Weekday()) + 6) % 7 // Monday is 0, like WKST
+			return t.Truncate(24*time.Hour).AddDate(0, 0, -((weekday - options.Wkst.Day() + 7) % 7))
+		}
+		first := weekStart(dtstart)
+		periods = int(weekStart(t).Sub(first) / (7 * 24 * time.Hour))
+		start = func(n int) time.Time { return first.AddDate(0, 0, 7*n) }
+	default:
+		unit := map[rrule.Frequency]time.Duration{
+			rrule.DAILY:    24 * time.Hour,
+			rrule.HOURLY:   time.Hour,
+			rrule.MINUTELY: time.Minute,
+			rrule.SECONDLY: time.Second,
+		}[options.Freq]
+		if unit == 0 {
+			return options, false
+		}
+		first := dtstart.Truncate(unit)
+		periods = int(t.Sub(first) / unit)
+		start = func(n int) time.Time { return first.Add(time.Duration(n) * unit) }
+	}
+	n := (periods/interval - 1) * interval
+	if n <= 0 {
+		return options, false
+	}
+	options.Dtstart = start(n)
+	return options, true
+}
+
+// iterator returns the wall clock occurrences of the recurrence, starting at a period of the rule shortly before
+// from, so the cost of a search does not grow with the number of occurrences since DTSTART.  Occurrences before
+// start, which is zero when the search starts at DTSTART, may be left out.
+func (rt *RRuleTrigger) iterator(from time.Time) (start time.Time, next rrule.Next) {
+	if rt.rule == nil {
+		return time.Time{}, rt.wallClock.Iterator()
+	}
+	options, ok := restart(*rt.rule, from)
+	if !ok {
+		return time.Time{}, rt.wallClock.Iterator()
+	}
+	rule, err := rrule.NewRRule(options)
+	if err != nil {
+		return time.Time{}, rt.wallClock.Iterator()
+	}
+	set := &rrule.Set{}
+	set.RRule(rule)
+	set.SetRDates(rt.wallClock.GetRDate())
+	set.SetExDates(rt.wallClock.GetExDate())
+	return options.Dtstart, set.Iterator()
+}
+
+// maxZoneShift is larger than the change of any daylight saving transition, so the wall clock time of an occurrence
+// is never further than this from the wall clock time of the instant it fires.
+const maxZoneShift = 3 * time.Hour
+
+// splitRecurrence splits a recurrence into its lines and checks them, with DTSTART as the first line as the rrule
+// package requires.
+func splitRecurrence(recurrence string) ([]string, error) {
+	var dtstart string
+	var lines []string
+	rules := 0
+	for _, line := range strings.Split(recurrence, "\n") {
+		line = strings.TrimSpace(line)
+		if line == "" {
+			continue
+		}
+		if strings.HasPrefix(strings.ToUpper(line), "FREQ=") {
+			line = "RRULE:" + line
+		}
+
+		name, _, ok := strings.Cut(strings.ToUpper(line), ":")
+		if !ok {
+			return nil, rruleError("missing ':' in " + line)
+		}
+		name, _, _ = strings.Cut(name, ";")
+		switch name {
+		case "DTSTART":
+			if dtstart != "" {
+				return nil, rruleError("DTSTART was set twice")
+			}
+			dtstart = line
+		case "RRULE":
+			rules++
+			if rules > 1 {
+				return nil, rruleError("only one RRULE is supported")
+			}
+			lines = append(lines, line)
+		case "RDATE", "EXDATE":
+			lines = append(lines, line)
+		default:
+			return nil, rruleError("unsupported property " + name)
+		}
+	}
+	if dtstart == "" {
+		return nil, rruleError("DTSTART is required")
+	}
+	return append([]string{dtstart}, lines...), nil
+}
+
+func rruleError(cause string) error {
+	return errors.Newf("invalid recurrence rule: %s", cause)
+}
+
+// Recurrence returns the normalized recurrence of the trigger.
+func (t RRuleTrigger) Recurrence() string {
+	return t.recurrence
+}
+
+func (t RRuleTrigger) Loc() *time.Location {
+	return t.location
+}
+
+func (rt *RRuleTrigger) Type() string {
+	return "rrule"
+}
+
+// InclusiveStart returns true, since the occurrences of a recurrence do not depend on when it is scheduled.
+func (rt *RRuleTrigger) InclusiveStart() bool {
+	return true
+}
+
+// NextFireTime returns the next time at which the RRuleTrigger is scheduled to fire.
+func (rt *RRuleTrigger) NextFireTime(prev time.Time) (time.Time, error) {
+	from := wallTime(prev, rt.zone).Add(-maxZoneShift)
+	_, next := rt.iterator(from)
+	for wall, ok := next(); ok; wall, ok = next() {
+		if wall.Before(from) {
+			continue
+		}
+		if t := instant(wall, rt.zone); t.After(prev) {
+			return t.UTC(), nil
+		}
+	}
+	return time.Time{}, errors.Wrap(triggers.ErrTriggerExpired, errors.WithMessagef("recurrence has no occurrence after %s", prev))
+}
+
+// PrevFireTime returns the last time before next at which the RRuleTrigger was scheduled to fire.
+func (rt *RRuleTrigger) PrevFireTime(next time.Time) (time.Time, error) {
+	until := wallTime(next, rt.zone).Add(maxZoneShift)
+	from := until
+	for {
+		start, iter := rt.iterator(from)
+		var prev, prevWall time.Time
+		for wall, ok := iter(); ok && !wall.After(until); wall, ok = iter() {
+			if t := instant(wall, rt.zone); t.Before(next) && t.After(prev) {
+				prev, prevWall = t, wall
+			}
+		}
+		if start.IsZero() {
+			if prev.IsZero() {
+				return time.Time{}, errors.Wrap(triggers.ErrTriggerExpired, errors.WithMessagef("recurrence has no occurrence before %s", next))
+			}
+			return prev.UTC(), nil
+		}
+		if !prev.IsZero() && !prevWall.Before(start) {
+			return prev.UTC(), nil
+		}
+		// The last occurrence may be before the search started, so search again from twice as far back.
+		from = start.Add(-until.Sub(start))
+	}
+}
+
+type persistedRRuleTrigger struct {
+	Recurrence string
+	Location   string
+}
+
+func (rt *RRuleTrigger) Serialize() (string, error) {
+	data, err := json.Marshal(persistedRRuleTrigger{
+		Recurrence: rt.recurrence,
+		Location:   rt.location.String(),
+	})
+	return string(data), errors.Wrap(err)
+}
+
+func (rt *RRuleTrigger) Deserialize(data string) (triggers.Trigger, error) {
+	trig := persistedRRuleTrigger{}
+	err := json.Unmarshal([]byte(data), &trig)
+	if err != nil {
+		return nil, errors.Wrap(err)
+	}
+	loc, err := time.LoadLocation(trig.Location)
+	if err != nil {
+		return nil, errors.Wrap(err)
+	}
+	return NewWithLoc(trig.Recurrence, loc)
+}
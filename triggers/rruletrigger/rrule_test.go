@@ -0,0 +1,208 @@
+package rruletrigger_test
+
+import (
+	"testing"
+	"time"
+
+	"github.com/jswidler/gorun/triggers"
+	"github.com/jswidler/gorun/triggers/rruletrigger"
+	"github.com/stretchr/testify/assert"
+	"github.com/teambition/rrule-go"
+)
+
+var zoneDateLayout = "2006-01-02 15:04:05 -0700"
+
+func TestRRuleRecurrences(t *testing.T) {
+	tests := []struct {
+		name       string
+		zone       string
+		recurrence string
+		prev       string
+		expected   []string
+	}{
+		{"second tuesday", "UTC", "DTSTART:20240101T090000Z\nRRULE:FREQ=MONTHLY;BYDAY=2TU", "2024-03-01 00:00:00 +0000",
+			[]string{"2024-03-12 09:00:00 +0000", "2024-04-09 09:00:00 +0000", "2024-05-14 09:00:00 +0000"}},
+		{"last business day of the quarter", "America/New_York",
+			"DTSTART;TZID=America/New_York:20240101T170000\nRRULE:FREQ=MONTHLY;BYMONTH=3,6,9,12;BYDAY=MO,TU,WE,TH,FR;BYSETPOS=-1",
+			"2024-01-15 00:00:00 -0500",
+			[]string{"2024-03-29 17:00:00 -0400", "2024-06-28 17:00:00 -0400", "2024-09-30 17:00:00 -0400", "2024-12-31 17:00:00 -0500"}},
+		{"floating times use the trigger location", "America/New_York", "DTSTART:20240101T090000\nFREQ=WEEKLY;INTERVAL=2;BYDAY=MO,WE",
+			"2024-01-01 00:00:00 -0500",
+			[]string{"2024-01-01 09:00:00 -0500", "2024-01-03 09:00:00 -0500", "2024-01-15 09:00:00 -0500"}},
+		{"exdate and rdate", "UTC", "DTSTART:20240101T120000Z\nRRULE:FREQ=DAILY\nEXDATE:20240102T120000Z,20240103T120000Z\nRDATE:20240102T150000Z",
+			"2024-01-01 12:00:00 +0000",
+			[]string{"2024-01-02 15:00:00 +0000", "2024-01-04 12:00:00 +0000"}},
+		{"dtstart does not have to be first", "UTC", "RRULE:FREQ=YEARLY;BYMONTH=11;BYDAY=4TH\nDTSTART:20200101T000000Z", "2024-01-01 00:00:00 +0000",
+			[]string{"2024-11-28 00:00:00 +0000", "2025-11-27 00:00:00 +0000"}},
+		{"skipped time", "America/New_York", "DTSTART;TZID=America/New_York:20240308T023000\nRRULE:FREQ=DAILY", "2024-03-09 12:00:00 -0500",
+			[]string{"2024-03-10 03:30:00 -0400", "2024-03-11 02:30:00 -0400"}},
+		{"repeated time fires once", "America/New_York", "DTSTART;TZID=America/New_York:20240301T013000\nRRULE:FREQ=DAILY", "2024-11-02 12:00:00 -0400",
+			[]string{"2024-11-03 01:30:00 -0400", "2024-11-04 01:30:00 -0500"}},
+		{"hourly through a repeated hour", "America/New_York", "DTSTART;TZID=America/New_York:20240301T000000\nRRULE:FREQ=HOURLY", "2024-11-03 00:00:00 -0400",
+			[]string{"2024-11-03 01:00:00 -0400", "2024-11-03 02:00:00 -0500"}},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			loc, err := time.LoadLocation(test.zone)
+			if err != nil {
+				t.Fatal(err)
+			}
+			trigger, err := rruletrigger.NewWithLoc(test.recurrence, loc)
+			if err != nil {
+				t.Fatal(err)
+			}
+			next, err := time.Parse(zoneDateLayout, test.prev)
+			if err != nil {
+				t.Fatal(err)
+			}
+			for _, expected := range test.expected {
+				next, err = trigger.NextFireTime(next)
+				if err != nil {
+					t.Fatal(err)
+				}
+				assert.Equal(t, expected, next.In(loc).Format(zoneDateLayout))
+			}
+		})
+	}
+}
+
+func TestRRuleCountAndUntil(t *testing.T) {
+	trigger, err := rruletrigger.New("DTSTART:20240101T000000Z\nRRULE:FREQ=DAILY;COUNT=3")
+	if err != nil {
+		t.Fatal(err)
+	}
+	fireTimes, err := triggers.NextFireTimes(trigger, time.Date(2024, 1, 1, 12, 0, 0, 0, time.UTC), 5)
+	assert.NoError(t, err)
+	assert.Equal(t, []time.Time{
+		time.Date(2024, 1, 2, 0, 0, 0, 0, time.UTC),
+		time.Date(2024, 1, 3, 0, 0, 0, 0, time.UTC),
+	}, fireTimes)
+
+	trigger, err = rruletrigger.New("DTSTART:20240101T000000Z\nRRULE:FREQ=DAILY;UNTIL=20240102T000000Z")
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, err = trigger.NextFireTime(time.Date(2024, 1, 2, 0, 0, 0, 0, time.UTC))
+	assert.ErrorIs(t, err, triggers.ErrTriggerExpired)
+
+	prev, err := trigger.PrevFireTime(time.Date(2024, 1, 5, 0, 0, 0, 0, time.UTC))
+	assert.NoError(t, err)
+	assert.Equal(t, time.Date(2024, 1, 2, 0, 0, 0, 0, time.UTC), prev)
+
+	prev, err = trigger.PrevFireTime(time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC))
+	assert.ErrorIs(t, err, triggers.ErrTriggerExpired)
+}
+
+func TestRRuleLongRunning(t *testing.T) {
+	// Searches start at a period of the rule shortly before the time, and must find the same occurrences as walking
+	// the rule from DTSTART.
+	tests := []struct {
+		recurrence string
+		span       time.Duration
+	}{
+		{"DTSTART:20230101T000030Z\nRRULE:FREQ=MINUTELY;INTERVAL=7", 48 * time.Hour},
+		{"DTSTART:20230101T031000Z\nRRULE:FREQ=HOURLY;INTERVAL=5;BYMINUTE=15,45", 30 * 24 * time.Hour},
+		{"DTSTART:20230101T120000Z\nRRULE:FREQ=DAILY;INTERVAL=3;BYHOUR=9,17", 400 * 24 * time.Hour},
+		{"DTSTART:20230105T080000Z\nRRULE:FREQ=WEEKLY;INTERVAL=2;BYDAY=MO,WE;WKST=SU", 400 * 24 * time.Hour},
+		{"DTSTART:20230105T080000Z\nRRULE:FREQ=WEEKLY;INTERVAL=3", 400 * 24 * time.Hour},
+		{"DTSTART:20230131T080000Z\nRRULE:FREQ=MONTHLY", 3 * 365 * 24 * time.Hour},
+		{"DTSTART:20230115T080000Z\nRRULE:FREQ=MONTHLY;INTERVAL=2;BYDAY=-1FR", 3 * 365 * 24 * time.Hour},
+		{"DTSTART:20230101T080000Z\nRRULE:FREQ=MONTHLY;BYMONTHDAY=1,15;BYSETPOS=-1", 3 * 365 * 24 * time.Hour},
+		{"DTSTART:20000229T000000Z\nRRULE:FREQ=YEARLY;INTERVAL=4", 40 * 365 * 24 * time.Hour},
+		{"DTSTART:20200101T000000Z\nRRULE:FREQ=YEARLY;BYMONTH=11;BYDAY=4TH", 20 * 365 * 24 * time.Hour},
+		{"DTSTART:20230101T000000Z\nRRULE:FREQ=SECONDLY;INTERVAL=45\nEXDATE:20230101T003000Z\nRDATE:20230101T003001Z", 2 * time.Hour},
+		{"DTSTART:20230101T090000Z\nRRULE:FREQ=DAILY;UNTIL=20230601T090000Z", 365 * 24 * time.Hour},
+	}
+
+	for _, test := range tests {
+		t.Run(test.recurrence, func(t *testing.T) {
+			trigger, err := rruletrigger.New(test.recurrence)
+			if err != nil {
+				t.Fatal(err)
+			}
+			set, err := rrule.StrToRRuleSet(test.recurrence)
+			if err != nil {
+				t.Fatal(err)
+			}
+			for i := 0; i < 50; i++ {
+				at := set.GetDTStart().Add(-time.Hour + time.Duration(i)*test.span/50).Truncate(time.Second).Add(7 * time.Second)
+
+				next, err := trigger.NextFireTime(at)
+				if expected := set.After(at, false); expected.IsZero() {
+					assert.ErrorIs(t, err, triggers.ErrTriggerExpired)
+				} else {
+					assert.NoError(t, err)
+					assert.Equal(t, expected, next, "next fire time after %s", at)
+				}
+
+				prev, err := trigger.PrevFireTime(at)
+				if expected := set.Before(at, false); expected.IsZero() {
+					assert.ErrorIs(t, err, triggers.ErrTriggerExpired)
+				} else {
+					assert.NoError(t, err)
+					assert.Equal(t, expected, prev, "previous fire time before %s", at)
+				}
+			}
+		})
+	}
+
+	// Years of occurrences do not have to be walked.
+	trigger, err := rruletrigger.New("DTSTART:20000101T000000Z\nRRULE:FREQ=SECONDLY;INTERVAL=7")
+	if err != nil {
+		t.Fatal(err)
+	}
+	prev := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	fireTimes, err := triggers.NextFireTimes(trigger, prev, triggers.MaxFireTimes)
+	assert.NoError(t, err)
+	assert.Equal(t, time.Date(2024, 1, 1, 0, 0, 2, 0, time.UTC), fireTimes[0])
+	assert.Equal(t, 7*time.Second, fireTimes[1].Sub(fireTimes[0]))
+	last, err := trigger.PrevFireTime(prev)
+	assert.NoError(t, err)
+	assert.Equal(t, time.Date(2023, 12, 31, 23, 59, 55, 0, time.UTC), last)
+}
+
+func TestRRuleSerialize(t *testing.T) {
+	loc, err := time.LoadLocation("Europe/Berlin")
+	if err != nil {
+		t.Fatal(err)
+	}
+	trigger, err := rruletrigger.NewWithLoc("DTSTART:20240101T080000\nRRULE:FREQ=WEEKLY;BYDAY=MO\nEXDATE:20240108T080000", loc)
+	if err != nil {
+		t.Fatal(err)
+	}
+	data, err := trigger.Serialize()
+	if err != nil {
+		t.Fatal(err)
+	}
+	loaded, err := triggers.LoadTrigger("rrule", data)
+	if err != nil {
+		t.Fatal(err)
+	}
+	assert.Equal(t, trigger.Recurrence(), loaded.(*rruletrigger.RRuleTrigger).Recurrence())
+
+	prev := time.Date(2024, 1, 1, 12, 0, 0, 0, time.UTC)
+	expected, _ := triggers.NextFireTimes(trigger, prev, 3)
+	actual, _ := triggers.NextFireTimes(loaded, prev, 3)
+	assert.Equal(t, expected, actual)
+	assert.Equal(t, time.Date(2024, 1, 15, 7, 0, 0, 0, time.UTC), actual[0])
+}
+
+func TestRRuleInvalidRecurrences(t *testing.T) {
+	recurrences := []string{
+		"",
+		"RRULE:FREQ=DAILY",
+		"DTSTART:20240101T000000Z\nRRULE:FREQ=FORTNIGHTLY",
+		"DTSTART:20240101T000000Z\nRRULE:BYDAY=MO",
+		"DTSTART:20240101T000000Z\nRRULE:FREQ=DAILY\nRRULE:FREQ=WEEKLY",
+		"DTSTART:20240101T000000Z\nDTSTART:20240102T000000Z\nRRULE:FREQ=DAILY",
+		"DTSTART:20240101T000000Z\nSUMMARY:Standup",
+		"DTSTART:2024-01-01\nRRULE:FREQ=DAILY",
+		"DTSTART;TZID=Mars/Olympus_Mons:20240101T000000\nRRULE:FREQ=DAILY",
+		"DTSTART:20240101T000000Z\nEXDATE:tomorrow",
+	}
+	for _, recurrence := range recurrences {
+		_, err := rruletrigger.New(recurrence)
+		assert.Error(t, err, "%q should fail", recurrence)
+	}
+}
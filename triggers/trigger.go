@@ -18,9 +18,35 @@ type Trigger interface {
 	Deserialize(data string) (Trigger, error)
 }
 
+// PrevFireTimer is implemented by triggers that can also search backwards in time.
+type PrevFireTimer interface {
+	// PrevFireTime returns the last time before next at which the Trigger was scheduled to fire.
+	PrevFireTime(next time.Time) (time.Time, error)
+}
+
+// InclusiveStarter is implemented by triggers that fire at fixed times, such as cron and rrule triggers, rather than
+// at times that depend on when they were scheduled.  A BoundedTrigger of such a trigger fires at StartAt when StartAt
+// is one of its fire times, where other triggers are scheduled from StartAt.
+type InclusiveStarter interface {
+	InclusiveStart() bool
+}
+
+// inclusiveStart returns whether the trigger is an InclusiveStarter that includes its start.
+func inclusiveStart(trigger Trigger) bool {
+	starter, ok := trigger.(InclusiveStarter)
+	return ok && starter.InclusiveStart()
+}
+
 var triggerHandlers = map[string]Trigger{}
 
-var ErrInvalidTriggerType = errors.Sentinel("invalid trigger type")
+var (
+	ErrInvalidTriggerType = errors.Sentinel("invalid trigger type")
+	ErrTriggerExpired     = errors.Sentinel("trigger will not fire again")
+	ErrTooManyFireTimes   = errors.Sentinel("too many fire times")
+)
+
+// MaxFireTimes is the most fire times that NextFireTimes returns.
+const MaxFireTimes = 1000
 
 func RegisterTriggerHandler(handlers ...Trigger) {
 	for i := range handlers {
@@ -35,3 +61,25 @@ func LoadTrigger(triggerType string, data string) (Trigger, error) {
 	}
 	return handler.Deserialize(data)
 }
+
+// NextFireTimes returns the next n times at which the Trigger is scheduled to fire after prev.  Fewer times are
+// returned if the trigger expires first.  n can not be more than MaxFireTimes.
+func NextFireTimes(trigger Trigger, prev time.Time, n int) ([]time.Time, error) {
+	if n > MaxFireTimes {
+		return nil, errors.Wrap(ErrTooManyFireTimes, errors.WithMessagef("can not return %d fire times, the most is %d", n, MaxFireTimes))
+	}
+	fireTimes := make([]time.Time, 0, max(n, 0))
+	next := prev
+	for len(fireTimes) < n {
+		var err error
+		next, err = trigger.NextFireTime(next)
+		if err != nil {
+			if errors.Is(err, ErrTriggerExpired) {
+				break
+			}
+			return nil, err
+		}
+		fireTimes = append(fireTimes, next)
+	}
+	return fireTimes, nil
+}
@@ -13,6 +13,9 @@ func init() {
 
 type RepeatTrigger struct {
 	Interval time.Duration
+	// Anchor, when set, aligns the trigger to fire at the anchor plus a whole number of intervals, rather than one
+	// interval after the previous fire time.
+	Anchor *time.Time `json:",omitempty"`
 }
 
 // Verify RepeatTrigger satisfies the Trigger interface.
@@ -29,9 +32,31 @@ func (t *RepeatTrigger) Type() string {
 	return "repeat"
 }
 
+// NewAnchoredRepeatTrigger returns a new RepeatTrigger that fires at anchor plus a whole number of intervals, so its
+// phase does not depend on when it was scheduled.  For example, a 15 minute interval anchored at midnight in a location
+// fires at :00, :15, :30 and :45 there.  Intervals are exact durations, so across a daylight saving change a daily
+// interval moves by an hour in local time; use a cron trigger to fire at the same local time each day.
+func NewAnchoredRepeatTrigger(interval time.Duration, anchor time.Time) *RepeatTrigger {
+	return &RepeatTrigger{
+		Interval: interval,
+		Anchor:   &anchor,
+	}
+}
+
 // NextFireTime returns the next time at which the RepeatTrigger is scheduled to fire.
 func (st *RepeatTrigger) NextFireTime(prev time.Time) (time.Time, error) {
-	next := prev.Add(st.Interval)
+	if st.Anchor == nil || st.Interval <= 0 {
+		return prev.Add(st.Interval), nil
+	}
+	// The first multiple of the interval from the anchor that is after prev.
+	n := prev.Sub(*st.Anchor) / st.Interval
+	next := st.Anchor.Add(n * st.Interval)
+	for !next.After(prev) {
+		next = next.Add(st.Interval)
+	}
+	for next.Add(-st.Interval).After(prev) {
+		next = next.Add(-st.Interval)
+	}
 	return next, nil
 }
 
@@ -80,7 +105,7 @@ func (ot *RunOnceTrigger) NextFireTime(prev time.Time) (time.Time, error) {
 		return next, nil
 	}
 
-	return time.Time{}, errors.New("RunOnce trigger is expired")
+	return time.Time{}, errors.Wrap(ErrTriggerExpired, errors.WithMessage("RunOnce trigger is expired"))
 }
 
 func (ot *RunOnceTrigger) Serialize() (string, error) {
@@ -0,0 +1,98 @@
+package triggers_test
+
+import (
+	"testing"
+	"time"
+
+	"github.com/jswidler/gorun/triggers"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestAnchoredRepeatTrigger(t *testing.T) {
+	loc, err := time.LoadLocation("Asia/Kolkata")
+	if err != nil {
+		t.Fatal(err)
+	}
+	anchor := time.Date(2024, 1, 1, 0, 0, 0, 0, loc)
+	trigger := triggers.NewAnchoredRepeatTrigger(15*time.Minute, anchor)
+
+	tests := []struct {
+		prev     time.Time
+		expected time.Time
+	}{
+		{time.Date(2024, 6, 3, 10, 7, 12, 0, loc), time.Date(2024, 6, 3, 10, 15, 0, 0, loc)},
+		{time.Date(2024, 6, 3, 10, 15, 0, 0, loc), time.Date(2024, 6, 3, 10, 30, 0, 0, loc)},
+		{time.Date(2024, 6, 3, 23, 59, 0, 0, loc), time.Date(2024, 6, 4, 0, 0, 0, 0, loc)},
+		{time.Date(2023, 12, 31, 23, 31, 0, 0, loc), time.Date(2023, 12, 31, 23, 45, 0, 0, loc)},
+	}
+	for _, test := range tests {
+		next, err := trigger.NextFireTime(test.prev)
+		assert.NoError(t, err)
+		assert.True(t, test.expected.Equal(next), "after %s expected %s, got %s", test.prev, test.expected, next.In(loc))
+	}
+
+	data, err := trigger.Serialize()
+	if err != nil {
+		t.Fatal(err)
+	}
+	loaded, err := triggers.LoadTrigger("repeat", data)
+	if err != nil {
+		t.Fatal(err)
+	}
+	prev := time.Date(2024, 6, 3, 10, 7, 0, 0, time.UTC)
+	expected, _ := triggers.NextFireTimes(trigger, prev, 3)
+	actual, _ := triggers.NextFireTimes(loaded, prev, 3)
+	assert.Equal(t, len(expected), len(actual))
+	for i := range expected {
+		assert.True(t, expected[i].Equal(actual[i]))
+	}
+
+	// Repeat triggers saved before anchors were added still load and repeat from the previous fire time.
+	loaded, err = triggers.LoadTrigger("repeat", `{"Interval":60000000000}`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	next, err := loaded.NextFireTime(prev)
+	assert.NoError(t, err)
+	assert.Equal(t, prev.Add(time.Minute), next)
+}
+
+func TestEventTrigger(t *testing.T) {
+	trigger := triggers.NewEventTrigger("product.updated")
+	trigger.Delay = 30 * time.Second
+	trigger.Debounce = true
+	assert.NoError(t, trigger.Validate())
+
+	fired := time.Date(2024, 6, 3, 10, 0, 0, 0, time.UTC)
+	next, err := trigger.NextFireTime(fired)
+	assert.NoError(t, err)
+	assert.Equal(t, fired.Add(30*time.Second), next)
+
+	data, err := trigger.Serialize()
+	if err != nil {
+		t.Fatal(err)
+	}
+	loaded, err := triggers.LoadTrigger("event", data)
+	assert.NoError(t, err)
+	assert.Equal(t, trigger, loaded)
+
+	invalid := []*triggers.EventTrigger{
+		{},
+		{Event: "product.updated", Delay: -time.Second},
+		{Event: "product.updated", Debounce: true, Throttle: time.Minute},
+	}
+	for _, trigger := range invalid {
+		assert.ErrorIs(t, trigger.Validate(), triggers.ErrInvalidEventTrigger)
+	}
+}
+
+func TestChainTrigger(t *testing.T) {
+	trigger := triggers.NewChainTrigger("import", "", false)
+	assert.NoError(t, trigger.Validate())
+	assert.Equal(t, triggers.JobFinishedEvent("import"), trigger.Event())
+	assert.Equal(t, triggers.TriggerFinishedEvent("nightly"), triggers.NewChainTrigger("", "nightly", true).Event())
+
+	assert.ErrorIs(t, triggers.NewChainTrigger("", "", false).Validate(), triggers.ErrInvalidChainTrigger)
+	assert.ErrorIs(t, triggers.NewChainTrigger("import", "nightly", false).Validate(), triggers.ErrInvalidChainTrigger)
+	assert.ErrorIs(t, triggers.NewEventTrigger(trigger.Event()).Validate(), triggers.ErrInvalidEventTrigger)
+}